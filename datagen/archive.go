@@ -0,0 +1,170 @@
+package datagen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"hash/crc64"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// collectionMetadata is the content of the metadata.json file written by mongodump
+// for each collection, and used by mongorestore to recreate the collection
+type collectionMetadata struct {
+	Options        bson.D          `bson:"options"`
+	Indexes        []bson.D        `bson:"indexes"`
+	CollectionName string          `bson:"collectionName"`
+	Type           string          `bson:"type"`
+	ShardConfig    *ShardingConfig `bson:"shardConfig,omitempty"`
+}
+
+// metadata returns the collection metadata as extended JSON, with the configured
+// indexes, the collection options and the sharding info if any. The shard info is
+// not used by mongorestore, but is kept so the collection can be sharded the same way
+func (coll *Collection) metadata() ([]byte, error) {
+
+	m := collectionMetadata{
+		Options:        bson.D{},
+		CollectionName: coll.Name,
+		Type:           "collection",
+		Indexes: []bson.D{{
+			{Key: "v", Value: int32(2)},
+			{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}},
+			{Key: "name", Value: "_id_"},
+		}},
+	}
+	if coll.CompressionLevel != "" {
+		m.Options = append(m.Options, bson.E{Key: "storageEngine", Value: bson.M{"wiredTiger": bson.M{"configString": "block_compressor=" + coll.CompressionLevel}}})
+	}
+	for i := range coll.Indexes {
+		m.Indexes = append(m.Indexes, coll.Indexes[i].metadata())
+	}
+	if coll.ShardConfig.ShardCollection != "" {
+		m.ShardConfig = &coll.ShardConfig
+	}
+	return bson.MarshalExtJSON(m, true, false)
+}
+
+// The archive format is the one used by 'mongodump --archive'. An archive is
+// made of:
+//
+//   - a magic number
+//   - a prelude, with a header document and a document holding the metadata
+//     of each collection, followed by a terminator
+//   - for each collection, a namespace header followed by the documents and
+//     a terminator, and then a namespace header marking the end of the collection,
+//     with the CRC of its documents, followed by a terminator
+const (
+	archiveMagicNumber   uint32 = 0x8199e26d
+	archiveVersion              = "0.1"
+	archiveServerVersion        = "5.0.6"
+	archiveToolVersion          = "mgodatagen"
+)
+
+var archiveTerminator = []byte{0xFF, 0xFF, 0xFF, 0xFF}
+
+type archiveHeader struct {
+	ConcurrentCollections int32  `bson:"concurrent_collections"`
+	FormatVersion         string `bson:"version"`
+	ServerVersion         string `bson:"server_version"`
+	ToolVersion           string `bson:"tool_version"`
+}
+
+type archiveCollectionMetadata struct {
+	Database   string `bson:"db"`
+	Collection string `bson:"collection"`
+	Metadata   string `bson:"metadata"`
+	Size       int32  `bson:"size"`
+	Type       string `bson:"type"`
+}
+
+type archiveNamespaceHeader struct {
+	Database   string `bson:"db"`
+	Collection string `bson:"collection"`
+	EOF        bool   `bson:"EOF"`
+	CRC        int64  `bson:"CRC"`
+}
+
+// writeArchivePrelude writes the magic number and the prelude of an archive
+// holding collections
+func writeArchivePrelude(out io.Writer, collections []Collection) error {
+
+	var buffer bytes.Buffer
+	binary.Write(&buffer, binary.LittleEndian, archiveMagicNumber)
+
+	header, err := bson.Marshal(archiveHeader{
+		ConcurrentCollections: 1,
+		FormatVersion:         archiveVersion,
+		ServerVersion:         archiveServerVersion,
+		ToolVersion:           archiveToolVersion,
+	})
+	if err != nil {
+		return err
+	}
+	buffer.Write(header)
+
+	for i := range collections {
+		metadata, err := collections[i].metadata()
+		if err != nil {
+			return err
+		}
+		doc, err := bson.Marshal(archiveCollectionMetadata{
+			Database:   collections[i].DB,
+			Collection: collections[i].Name,
+			Metadata:   string(metadata),
+			Type:       "collection",
+		})
+		if err != nil {
+			return err
+		}
+		buffer.Write(doc)
+	}
+	buffer.Write(archiveTerminator)
+
+	_, err = out.Write(buffer.Bytes())
+	return err
+}
+
+// archiveEncoder writes the documents of a collection in the body
+// of an archive, in a single block
+type archiveEncoder struct {
+	db         string
+	collection string
+	crc        hash.Hash64
+}
+
+func (e *archiveEncoder) begin(buffer *bytes.Buffer, coll *Collection) {
+	e.db, e.collection = coll.DB, coll.Name
+	e.crc = crc64.New(crc64.MakeTable(crc64.ECMA))
+	e.writeNamespaceHeader(buffer, false)
+}
+
+func (e *archiveEncoder) encode(buffer *bytes.Buffer, doc bson.Raw) {
+	buffer.Write(doc)
+	e.crc.Write(doc)
+}
+
+func (e *archiveEncoder) end(buffer *bytes.Buffer) {
+	buffer.Write(archiveTerminator)
+	e.writeNamespaceHeader(buffer, true)
+	buffer.Write(archiveTerminator)
+}
+
+func (e *archiveEncoder) writeNamespaceHeader(buffer *bytes.Buffer, eof bool) {
+	header := archiveNamespaceHeader{
+		Database:   e.db,
+		Collection: e.collection,
+		EOF:        eof,
+	}
+	if eof {
+		header.CRC = int64(e.crc.Sum64())
+	}
+	doc, err := bson.Marshal(header)
+	if err != nil {
+		// a namespace header is always a valid document, this should never happen
+		panic(err)
+	}
+	buffer.Write(doc)
+}
@@ -20,8 +20,15 @@ type Collection struct {
 	Name string `json:"collection"`
 	// Number of documents to insert in the collection
 	Count int `json:"count"`
-	// Schema of the documents for this collection
-	Content map[string]generators.Config `json:"content"`
+	// Schema of the documents for this collection. Fields are generated
+	// in the order in which they are declared
+	Content generators.Content `json:"content"`
+	// Shapes of the documents for collections holding documents with different
+	// schemas. Each document holds the fields of 'Content', followed by the fields
+	// of one of the variants
+	Variants []generators.Variant `json:"variants"`
+	// Field holding the version of the variant a document was created from
+	VersionField string `json:"versionField"`
 	// Compression level for a collection. Available for `WiredTiger` only.
 	// can be none|snappy|zlib. Default is "snappy"
 	CompressionLevel string `json:"compressionLevel"`
@@ -29,9 +36,17 @@ type Collection struct {
 	Indexes []Index `json:"indexes"`
 	// Sharding information for sharded collection
 	ShardConfig ShardingConfig `json:"shardConfig"`
+	// Tuples of top level fields whose combination of values has to be
+	// unique, like the fields of a compound unique index
+	UniqueFields [][]string `json:"uniqueFields"`
 
-	docGenerator *generators.DocumentGenerator
-	aggregators  []generators.Aggregator
+	collInfo      *generators.CollInfo
+	docGenerators []*generators.DocumentGenerator
+	uniqueFilter  *generators.UniqueFieldsFilter
+	aggregators   []generators.Aggregator
+	// aggregators of other collections running on this collection,
+	// used to compute aggregations without a database
+	indexedBy []generators.Aggregator
 }
 
 // ShardingConfig struct that holds information to shard the collection
@@ -0,0 +1,101 @@
+package datagen
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressionFromFileName returns the compression matching the
+// extension of a file name, or noCompression
+func compressionFromFileName(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		return gzipCompression
+	case strings.HasSuffix(name, ".zst"), strings.HasSuffix(name, ".zstd"):
+		return zstdCompression
+	default:
+		return noCompression
+	}
+}
+
+// compressionExtension returns the extension to append to the name of the
+// files created for bson output, ie '.gz' for gzip, as expected by 'mongorestore --gzip'
+func compressionExtension(compression string) string {
+	switch compression {
+	case gzipCompression:
+		return ".gz"
+	case zstdCompression:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// compressWriter compresses the data written to it in a separate goroutine,
+// so compression runs concurrently with the encoding of the documents.
+//
+// Close has to be called to flush the remaining data, it returns the first
+// error encountered while compressing or writing the data
+type compressWriter struct {
+	buffers chan []byte
+	pool    sync.Pool
+	done    chan error
+}
+
+func newCompressWriter(out io.Writer, compression string) (*compressWriter, error) {
+
+	var zw io.WriteCloser
+	switch compression {
+	case gzipCompression:
+		zw = gzip.NewWriter(out)
+	case zstdCompression:
+		enc, err := zstd.NewWriter(out)
+		if err != nil {
+			return nil, fmt.Errorf("fail to create zstd encoder: %v", err)
+		}
+		zw = enc
+	default:
+		return nil, fmt.Errorf("invalid compression '%s'", compression)
+	}
+
+	w := &compressWriter{
+		buffers: make(chan []byte, 8),
+		done:    make(chan error, 1),
+	}
+	go w.compress(zw)
+	return w, nil
+}
+
+func (w *compressWriter) compress(zw io.WriteCloser) {
+
+	var err error
+	for b := range w.buffers {
+		if err == nil {
+			_, err = zw.Write(b)
+		}
+		w.pool.Put(b[:0])
+	}
+	closeErr := zw.Close()
+	if err == nil {
+		err = closeErr
+	}
+	w.done <- err
+}
+
+// Write copies p, as the caller may reuse it once Write returns,
+// and sends it to the compressing goroutine
+func (w *compressWriter) Write(p []byte) (int, error) {
+	b, _ := w.pool.Get().([]byte)
+	w.buffers <- append(b, p...)
+	return len(p), nil
+}
+
+func (w *compressWriter) Close() error {
+	close(w.buffers)
+	return <-w.done
+}
@@ -0,0 +1,247 @@
+package datagen
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/feliixx/mgodatagen/datagen/generators"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// csvEncoder writes documents as csv or tsv. Embedded documents are flattened,
+// using dotted column names like 'field.subfield'.
+//
+// The columns are computed from the content of the collection, so the header
+// is the same for all documents, even if some fields are missing
+type csvEncoder struct {
+	comma     rune
+	arrayMode string
+
+	w       *csv.Writer
+	columns []string
+	// index of each column in a row
+	index map[string]int
+	// paths of the embedded documents or arrays that are flattened
+	containers map[string]bool
+	// paths of the arrays that are exploded
+	exploded map[string]bool
+}
+
+func (e *csvEncoder) begin(buffer *bytes.Buffer, coll *Collection) {
+
+	e.columns = nil
+	e.index = make(map[string]int)
+	e.containers = make(map[string]bool)
+	e.exploded = make(map[string]bool)
+
+	for i := range coll.Content {
+		e.addColumns(coll.Content[i].Name, &coll.Content[i].Config, e.arrayMode)
+	}
+	if coll.VersionField != "" {
+		e.addColumns(coll.VersionField, &generators.Config{Type: generators.TypeConstant}, e.arrayMode)
+	}
+	// fields of the variants are written in the same column when they share a name
+	for _, v := range coll.Variants {
+		for i := range v.Content {
+			e.addColumns(v.Content[i].Name, &v.Content[i].Config, e.arrayMode)
+		}
+	}
+
+	e.w = csv.NewWriter(buffer)
+	e.w.Comma = e.comma
+	e.w.Write(e.columns)
+	e.w.Flush()
+}
+
+func (e *csvEncoder) addColumns(path string, config *generators.Config, arrayMode string) {
+
+	switch config.Type {
+
+	case generators.TypeObject:
+		e.containers[path] = true
+		for i := range config.ObjectContent {
+			e.addColumns(path+"."+config.ObjectContent[i].Name, &config.ObjectContent[i].Config, arrayMode)
+		}
+		return
+
+	case generators.TypeArray, generators.TypeCoordinates, generators.TypePosition:
+
+		content := &generators.Config{Type: generators.TypeDouble}
+		maxLength := 2
+		if config.Type == generators.TypeArray {
+			content = config.ArrayContent
+			maxLength = arrayMaxLength(config)
+		}
+
+		switch arrayMode {
+		case explodeArrayMode:
+			// coordinates are a single value, they don't make sense once exploded
+			if config.Type != generators.TypeArray {
+				break
+			}
+			// only the outermost array is exploded, nested arrays are written as json
+			e.containers[path] = true
+			e.exploded[path] = true
+			e.addColumns(path, content, jsonArrayMode)
+			return
+		case indexArrayMode:
+			e.containers[path] = true
+			for i := 0; i < maxLength; i++ {
+				e.addColumns(path+"."+strconv.Itoa(i), content, arrayMode)
+			}
+			return
+		}
+	}
+
+	if _, ok := e.index[path]; !ok {
+		e.index[path] = len(e.columns)
+		e.columns = append(e.columns, path)
+	}
+}
+
+// arrayMaxLength returns the maximum length of the arrays created
+// by an array generator
+func arrayMaxLength(config *generators.Config) int {
+	// same defaults as the array generator
+	if config.MinLength == "" && config.MaxLength == "" && config.Size > 0 {
+		return config.Size
+	}
+	if config.MaxLength == "" {
+		return 3
+	}
+	max, err := strconv.Atoi(string(config.MaxLength))
+	if err != nil {
+		return 0
+	}
+	return max
+}
+
+func (e *csvEncoder) encode(buffer *bytes.Buffer, doc bson.Raw) {
+
+	rows := [][]string{make([]string, len(e.columns))}
+
+	elements, _ := doc.Elements()
+	for _, elem := range elements {
+		rows = e.flatten(rows, elem.Key(), elem.Value())
+	}
+
+	e.w.WriteAll(rows)
+}
+
+// flatten writes the value at path in rows. If the value is an exploded array,
+// the rows are duplicated for each element of the array
+func (e *csvEncoder) flatten(rows [][]string, path string, value bson.RawValue) [][]string {
+	if e.exploded[path] {
+		return e.explode(rows, path, value)
+	}
+	return e.flattenValue(rows, path, value)
+}
+
+func (e *csvEncoder) flattenValue(rows [][]string, path string, value bson.RawValue) [][]string {
+
+	if i, ok := e.index[path]; ok {
+		for _, row := range rows {
+			row[i] = csvValue(value)
+		}
+		return rows
+	}
+
+	if !e.containers[path] {
+		return rows
+	}
+	elements := subElements(value)
+	for _, elem := range elements {
+		rows = e.flatten(rows, path+"."+elem.Key(), elem.Value())
+	}
+	return rows
+}
+
+// explode returns a copy of rows for each element of the array, holding
+// the value of the element. The path of an element is the path of the array
+func (e *csvEncoder) explode(rows [][]string, path string, value bson.RawValue) [][]string {
+
+	elements := subElements(value)
+	// an empty array gives a single row with empty values,
+	// so the other fields of the document are still written
+	if len(elements) == 0 {
+		return rows
+	}
+
+	exploded := make([][]string, 0, len(rows)*len(elements))
+	for _, elem := range elements {
+		elemRows := make([][]string, len(rows))
+		for i := range rows {
+			elemRows[i] = append([]string(nil), rows[i]...)
+		}
+		exploded = append(exploded, e.flattenValue(elemRows, path, elem.Value())...)
+	}
+	return exploded
+}
+
+// subElements returns the elements of an embedded document or of an array,
+// and nil for any other type
+func subElements(value bson.RawValue) []bson.RawElement {
+	var doc bson.Raw
+	switch value.Type {
+	case bson.TypeEmbeddedDocument:
+		doc = value.Document()
+	case bson.TypeArray:
+		doc = bson.Raw(value.Array())
+	default:
+		return nil
+	}
+	elements, _ := doc.Elements()
+	return elements
+}
+
+func (e *csvEncoder) end(buffer *bytes.Buffer) {
+	e.w.Flush()
+}
+
+// csvValue returns a bson value as a string. Embedded documents, arrays and
+// types without a natural string representation are written as relaxed extended JSON
+func csvValue(value bson.RawValue) string {
+
+	switch value.Type {
+	case bson.TypeString:
+		return value.StringValue()
+	case bson.TypeInt32:
+		return strconv.FormatInt(int64(value.Int32()), 10)
+	case bson.TypeInt64:
+		return strconv.FormatInt(value.Int64(), 10)
+	case bson.TypeDouble:
+		return strconv.FormatFloat(value.Double(), 'g', -1, 64)
+	case bson.TypeDecimal128:
+		return value.Decimal128().String()
+	case bson.TypeBoolean:
+		return strconv.FormatBool(value.Boolean())
+	case bson.TypeObjectID:
+		return value.ObjectID().Hex()
+	case bson.TypeDateTime:
+		return time.UnixMilli(value.DateTime()).UTC().Format("2006-01-02T15:04:05.000Z")
+	case bson.TypeNull, bson.TypeUndefined:
+		return ""
+	case bson.TypeBinary:
+		subtype, data := value.Binary()
+		if subtype == bson.TypeBinaryUUID {
+			if u, err := uuid.FromBytes(data); err == nil {
+				return u.String()
+			}
+		}
+		return base64.StdEncoding.EncodeToString(data)
+	}
+
+	b, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: value}}, false, false)
+	if err != nil {
+		return value.String()
+	}
+	// remove the wrapping document, ie '{"v":' and '}'
+	s := string(b)
+	return strings.TrimSuffix(strings.TrimPrefix(s, `{"v":`), "}")
+}
@@ -0,0 +1,113 @@
+package datagen
+
+import (
+	"bytes"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// encoder writes the documents of a collection in a specific format
+type encoder interface {
+	// begin is called before the first document of the collection
+	begin(buffer *bytes.Buffer, coll *Collection)
+	// encode writes a single document
+	encode(buffer *bytes.Buffer, doc bson.Raw)
+	// end is called after the last document of the collection
+	end(buffer *bytes.Buffer)
+}
+
+// writeExtJSON writes doc as canonical or relaxed extended JSON
+func writeExtJSON(buffer *bytes.Buffer, doc bson.Raw, relaxed bool) {
+	if !relaxed {
+		buffer.WriteString(doc.String())
+		return
+	}
+	b, err := bson.MarshalExtJSON(doc, false, false)
+	if err != nil {
+		// documents are always valid bson, this should never happen
+		panic(err)
+	}
+	buffer.Write(b)
+}
+
+// jsonEncoder writes the documents of a collection as a single
+// json object:
+//
+//	{
+//	  "dbName.collectionName": [
+//	    {...},
+//	    {...},
+//	    {...}
+//	  ]
+//	}
+type jsonEncoder struct {
+	relaxed     bool
+	prettyPrint bool
+	first       bool
+	tmp         bytes.Buffer
+}
+
+const jsonPrefix = "    "
+
+func (e *jsonEncoder) begin(buffer *bytes.Buffer, coll *Collection) {
+	e.first = true
+	buffer.WriteByte('{')
+	buffer.WriteByte('\n')
+	fmt.Fprintf(buffer, `  "%s.%s": [`, coll.DB, coll.Name)
+	buffer.WriteByte('\n')
+}
+
+func (e *jsonEncoder) encode(buffer *bytes.Buffer, doc bson.Raw) {
+
+	if !e.first {
+		buffer.WriteByte(',')
+		buffer.WriteByte('\n')
+	}
+	e.first = false
+
+	buffer.WriteString(jsonPrefix)
+	if !e.prettyPrint {
+		writeExtJSON(buffer, doc, e.relaxed)
+		return
+	}
+	e.tmp.Reset()
+	writeExtJSON(&e.tmp, doc, e.relaxed)
+	bson.IndentExtJSON(buffer, e.tmp.Bytes(), jsonPrefix, "  ")
+}
+
+func (e *jsonEncoder) end(buffer *bytes.Buffer) {
+	buffer.WriteByte('\n')
+	buffer.WriteByte(' ')
+	buffer.WriteByte(' ')
+	buffer.WriteByte(']')
+	buffer.WriteByte('\n')
+	buffer.WriteByte('}')
+}
+
+// ndjsonEncoder writes one document per line, which is the format
+// expected by mongoimport
+type ndjsonEncoder struct {
+	relaxed bool
+}
+
+func (e *ndjsonEncoder) begin(buffer *bytes.Buffer, coll *Collection) {}
+
+func (e *ndjsonEncoder) encode(buffer *bytes.Buffer, doc bson.Raw) {
+	writeExtJSON(buffer, doc, e.relaxed)
+	buffer.WriteByte('\n')
+}
+
+func (e *ndjsonEncoder) end(buffer *bytes.Buffer) {}
+
+// bsonEncoder writes the raw bson documents, like in the .bson files
+// written by mongodump
+type bsonEncoder struct{}
+
+func (e *bsonEncoder) begin(buffer *bytes.Buffer, coll *Collection) {}
+
+func (e *bsonEncoder) encode(buffer *bytes.Buffer, doc bson.Raw) {
+	buffer.Write(doc)
+}
+
+func (e *bsonEncoder) end(buffer *bytes.Buffer) {}
@@ -3,10 +3,11 @@ package datagen
 import (
 	"bytes"
 	"context"
-	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,27 +16,40 @@ import (
 	"github.com/gosuri/uiprogress"
 	"github.com/gosuri/uiprogress/util/strutil"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/bsontype"
 )
 
 type fileWriter struct {
 	*baseWriter
 
+	output       string
+	format       string
 	prettyPrint  bool
+	relaxed      bool
+	archive      bool
+	arrayMode    string
+	compression  string
 	out          io.Writer
 	showProgress bool
 }
 
+// newFileWriter returns a writer writing documents to out. If out is nil, each
+// collection is written to its own file, see fileWriter.fileName()
 func newFileWriter(options *Options, logger, out io.Writer) writer {
 
 	w := &fileWriter{
 		baseWriter: &baseWriter{
-			batchSize:  1000,
-			mapRef:     make(map[int][][]byte),
-			mapRefType: make(map[int]bsontype.Type),
-			logger:     logger,
+			batchSize:    1000,
+			numGenerator: options.NumGenerator,
+			references:   make(generators.References),
+			logger:       logger,
 		},
+		output:      options.Output,
+		format:      options.OutputFormat,
 		prettyPrint: options.PrettyPrint,
+		relaxed:     options.JSONFormat == relaxedJSON,
+		archive:     options.Archive,
+		arrayMode:   options.ArrayMode,
+		compression: options.Compress,
 		out:         out,
 	}
 
@@ -45,38 +59,218 @@ func newFileWriter(options *Options, logger, out io.Writer) writer {
 	return w
 }
 
+// hasFileNamePlaceholder returns true if output is a file name containing
+// a collection placeholder, ie if a file has to be created for each collection
+func hasFileNamePlaceholder(output string) bool {
+	return strings.Contains(output, dbPlaceholder) || strings.Contains(output, collectionPlaceholder)
+}
+
 func (w *fileWriter) write(collections []Collection, seed uint64) (err error) {
 
 	for i := 0; i < len(collections); i++ {
 
-		ci := generators.NewCollInfo(collections[i].Count, []int{5, 0, 6}, seed, w.mapRef, w.mapRefType)
+		ci := generators.NewCollInfo(collections[i].Count, []int{5, 0, 6}, seed, w.references)
+		ci.Namespace = collections[i].DB + "." + collections[i].Name
 
 		// as the document is not inserted in mongodb, the "_id" won't be autogenerated
 		// if not present, so add an objectId generator if user hasn't specified one
-		_, hasId := collections[i].Content["_id"]
-		if !hasId {
-			collections[i].Content["_id"] = generators.Config{
-				Type: generators.TypeObjectID,
-			}
+		addIDGeneratorIfMissing(&collections[i])
+
+		collections[i].collInfo = ci
+		collections[i].docGenerators, err = w.newDocumentGenerators(ci, &collections[i])
+		if err != nil {
+			return fmt.Errorf("fail to create DocumentGenerator for collection '%s'\n%v", collections[i].Name, err)
 		}
-		collections[i].docGenerator, err = ci.NewDocumentGenerator(collections[i].Content)
+		collections[i].uniqueFilter, err = ci.NewUniqueFieldsFilter(collections[i].Content, collections[i].UniqueFields)
 		if err != nil {
 			return fmt.Errorf("fail to create DocumentGenerator for collection '%s'\n%v", collections[i].Name, err)
 		}
 
-		aggs, err := ci.NewAggregatorSlice(collections[i].Content)
-		if len(aggs) > 0 || err != nil {
-			return errors.New("aggregators are not supported for stdout or file output")
+		collections[i].aggregators, err = ci.NewAggregatorSlice(collections[i].Content)
+		if err != nil {
+			return fmt.Errorf("fail to create Aggregator for collection '%s'\n%v", collections[i].Name, err)
+		}
+		err = prepareAggregators(collections, i)
+		if err != nil {
+			return fmt.Errorf("fail to create Aggregator for collection '%s'\n%v", collections[i].Name, err)
+		}
+	}
+
+	outs, closeOutputs, err := w.openOutputs(collections)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// compressed data is flushed when the outputs are closed
+		closeErr := closeOutputs()
+		if err == nil && closeErr != nil {
+			err = fmt.Errorf("fail to write to output: %v", closeErr)
 		}
+	}()
+
+	switch {
+	case w.archive:
+		err = writeArchivePrelude(outs[0], collections)
+	case w.format == bsonFormat && w.out == nil:
+		err = w.writeMetadataFiles(collections)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to write collection metadata: %v", err)
 	}
 
 	for i := 0; i < len(collections); i++ {
-		w.generate(&collections[i])
+		err = w.generate(&collections[i], outs[i])
+		if err != nil {
+			return err
+		}
+	}
+	w.printGraphStats(collections)
+	return nil
+}
+
+// prepareAggregators makes sure that the aggregators of collections[i] can be
+// computed in-process. As there is no database to query, the collection an aggregator
+// runs on has to be declared before, so its documents are indexed while they are written
+func prepareAggregators(collections []Collection, i int) error {
+
+	for _, a := range collections[i].aggregators {
+
+		err := a.PrepareIndex()
+		if err != nil {
+			return fmt.Errorf("invalid generator for field '%s'\n  cause: %v", a.Key(), err)
+		}
+
+		db, name := a.Source()
+		j := i - 1
+		for j >= 0 && (collections[j].DB != db || collections[j].Name != name) {
+			j--
+		}
+		if j < 0 {
+			return fmt.Errorf("invalid generator for field '%s'\n  cause: collection '%s.%s' has to be declared before collection '%s' for stdout or file output", a.Key(), db, name, collections[i].Name)
+		}
+		collections[j].indexedBy = append(collections[j].indexedBy, a)
 	}
 	return nil
 }
 
-func (w *fileWriter) generate(coll *Collection) {
+// openOutputs returns the writer to use for each collection. Collections whose
+// file name is the same share the same file. If the output is compressed, each
+// file is wrapped in a compressWriter.
+//
+// closeOutputs flushes the compressed data and closes the files created here
+func (w *fileWriter) openOutputs(collections []Collection) (outs []io.Writer, closeOutputs func() error, err error) {
+
+	outs = make([]io.Writer, len(collections))
+	opened := make(map[string]io.Writer)
+	// closed in reverse order, so a compressWriter is closed before its file
+	var closers []io.Closer
+
+	closeOutputs = func() error {
+		var err error
+		for i := len(closers) - 1; i >= 0; i-- {
+			closeErr := closers[i].Close()
+			if err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}
+
+	for i, coll := range collections {
+
+		name := ""
+		if w.out == nil {
+			name = w.fileName(&coll, ".bson")
+		}
+		out, ok := opened[name]
+		if !ok {
+			out = w.out
+			if w.out == nil {
+				f, err := createFileAndDirs(name)
+				if err != nil {
+					closeOutputs()
+					return nil, nil, err
+				}
+				closers = append(closers, f)
+				out = f
+			}
+			if w.compression != noCompression {
+				cw, err := newCompressWriter(out, w.compression)
+				if err != nil {
+					closeOutputs()
+					return nil, nil, err
+				}
+				closers = append(closers, cw)
+				out = cw
+			}
+			opened[name] = out
+		}
+		outs[i] = out
+	}
+	return outs, closeOutputs, nil
+}
+
+// fileName returns the name of the file where the collection is written.
+//
+// For bson format, the output is a directory with the same layout as with
+// mongodump, ie <output>/<db>/<collection><ext>, followed by '.gz' if the output
+// is compressed with gzip. Otherwise, the placeholders {db} and {collection} of
+// the output are replaced by the collection info
+func (w *fileWriter) fileName(coll *Collection, ext string) string {
+	if w.format == bsonFormat {
+		return filepath.Join(w.output, coll.DB, coll.Name+ext+compressionExtension(w.compression))
+	}
+	return strings.NewReplacer(dbPlaceholder, coll.DB, collectionPlaceholder, coll.Name).Replace(w.output)
+}
+
+// writeMetadataFiles writes the metadata.json file of each collection, used by
+// mongorestore to create the collection with its options and indexes
+func (w *fileWriter) writeMetadataFiles(collections []Collection) error {
+	for i := range collections {
+		metadata, err := collections[i].metadata()
+		if err != nil {
+			return err
+		}
+		f, err := createFileAndDirs(w.fileName(&collections[i], ".metadata.json"))
+		if err != nil {
+			return err
+		}
+		err = writeAndClose(f, metadata, w.compression)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeAndClose writes data to f, compressed if needed, and closes f
+func writeAndClose(f *os.File, data []byte, compression string) error {
+
+	defer f.Close()
+
+	if compression == noCompression {
+		_, err := f.Write(data)
+		return err
+	}
+	cw, err := newCompressWriter(f, compression)
+	if err != nil {
+		return err
+	}
+	cw.Write(data)
+	return cw.Close()
+}
+
+// createFileAndDirs creates the missing parent directories of
+// a file before creating it
+func createFileAndDirs(name string) (*os.File, error) {
+	err := os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("could not create directory: %v", err)
+	}
+	return tryToCreateFile(name)
+}
+
+func (w *fileWriter) generate(coll *Collection, out io.Writer) error {
 
 	if w.showProgress {
 
@@ -99,69 +293,69 @@ func (w *fileWriter) generate(coll *Collection) {
 
 	var wg sync.WaitGroup
 	wg.Add(1)
-	go w.writeToStdout(&wg, coll, tasks)
+	go w.writeDocuments(&wg, coll, out, tasks)
 
-	w.generateDocument(context.Background(), tasks, coll.Count, coll.docGenerator)
+	err := w.generateDocument(context.Background(), tasks, coll)
 
 	wg.Wait()
+	return err
 }
 
-func (w *fileWriter) writeToStdout(wg *sync.WaitGroup, coll *Collection, tasks <-chan *rawChunk) {
+func (w *fileWriter) newEncoder() encoder {
+	switch w.format {
+	case ndjsonFormat:
+		return &ndjsonEncoder{relaxed: w.relaxed}
+	case bsonFormat:
+		if w.archive {
+			return &archiveEncoder{}
+		}
+		return &bsonEncoder{}
+	case csvFormat:
+		return &csvEncoder{comma: ',', arrayMode: w.arrayMode}
+	case tsvFormat:
+		return &csvEncoder{comma: '\t', arrayMode: w.arrayMode}
+	default:
+		return &jsonEncoder{relaxed: w.relaxed, prettyPrint: w.prettyPrint}
+	}
+}
+
+func (w *fileWriter) writeDocuments(wg *sync.WaitGroup, coll *Collection, out io.Writer, tasks <-chan *rawChunk) {
 
 	defer wg.Done()
 
 	buffer := bytes.NewBuffer(make([]byte, 0, 64000))
 
-	// format is :
-	//
-	// {
-	//   "dbName.collectionName": [
-	//	   {...},
-	//	   {...},
-	//	   {...}
-	//   ]
-	// }
-	buffer.WriteByte('{')
-	buffer.WriteByte('\n')
-	fmt.Fprintf(buffer, `  "%s.%s": [`, coll.DB, coll.Name)
-	buffer.WriteByte('\n')
-
-	prefix := "    "
+	enc := w.newEncoder()
+	enc.begin(buffer, coll)
+
+	// buffer holding a document with its aggregated fields
+	var aggregated []byte
 
 	for t := range tasks {
 
 		for _, doc := range t.documents[:t.nbToInsert] {
 
-			raw := bson.Raw(doc)
-
-			buffer.WriteString(prefix)
-			if w.prettyPrint {
-				bson.IndentExtJSON(buffer, []byte(raw.String()), prefix, "  ")
-			} else {
-				buffer.WriteString(raw.String())
+			for _, a := range coll.indexedBy {
+				a.Index(doc)
+			}
+			if len(coll.aggregators) > 0 {
+				aggregated = generators.AppendAggregations(aggregated, doc, coll.aggregators)
+				doc = aggregated
 			}
-			buffer.WriteByte(',')
-			buffer.WriteByte('\n')
+
+			enc.encode(buffer, bson.Raw(doc))
 
 			if buffer.Len() > 64000 {
-				w.out.Write(buffer.Bytes())
+				out.Write(buffer.Bytes())
 				buffer.Reset()
 			}
 		}
 		pool.Put(t)
 	}
 
-	// remove the last trailing comma
-	buffer.Truncate(buffer.Len() - 2)
-
-	buffer.WriteByte('\n')
-	buffer.WriteByte(' ')
-	buffer.WriteByte(' ')
-	buffer.WriteByte(']')
-	buffer.WriteByte('\n')
-	buffer.WriteByte('}')
+	enc.end(buffer)
 
 	if buffer.Len() > 0 {
-		w.out.Write(buffer.Bytes())
+		out.Write(buffer.Bytes())
 	}
 }
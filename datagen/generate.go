@@ -43,6 +43,49 @@ Actual collection count may not match the 'count' specified in config file
 	if options.Output == "" {
 		options.Output = mongodbOutput
 	}
+	if options.Archive {
+		if options.OutputFormat != "" && options.OutputFormat != bsonFormat {
+			return fmt.Errorf("--archive can't be used with --outputFormat=%s", options.OutputFormat)
+		}
+		options.OutputFormat = bsonFormat
+	}
+	switch options.OutputFormat {
+	case "":
+		options.OutputFormat = jsonFormat
+	case jsonFormat, ndjsonFormat, bsonFormat, csvFormat, tsvFormat:
+	default:
+		return fmt.Errorf("invalid value for --outputFormat: '%s'. Must be one of ['%s', '%s', '%s', '%s', '%s']", options.OutputFormat, jsonFormat, ndjsonFormat, bsonFormat, csvFormat, tsvFormat)
+	}
+	if options.Output == mongodbOutput && options.OutputFormat != jsonFormat {
+		return errors.New("--outputFormat and --archive can only be used with stdout or file output")
+	}
+	switch options.JSONFormat {
+	case "":
+		options.JSONFormat = canonicalJSON
+	case canonicalJSON, relaxedJSON:
+	default:
+		return fmt.Errorf("invalid value for --jsonFormat: '%s'. Must be one of ['%s', '%s']", options.JSONFormat, canonicalJSON, relaxedJSON)
+	}
+	switch options.ArrayMode {
+	case "":
+		options.ArrayMode = jsonArrayMode
+	case jsonArrayMode, explodeArrayMode, indexArrayMode:
+	default:
+		return fmt.Errorf("invalid value for --arrayMode: '%s'. Must be one of ['%s', '%s', '%s']", options.ArrayMode, jsonArrayMode, explodeArrayMode, indexArrayMode)
+	}
+	switch options.Compress {
+	case "":
+		options.Compress = compressionFromFileName(options.Output)
+	case noCompression, gzipCompression, zstdCompression:
+		if options.Output == mongodbOutput && options.Compress != noCompression {
+			return errors.New("--compress can only be used with stdout or file output")
+		}
+	default:
+		return fmt.Errorf("invalid value for --compress: '%s'. Must be one of ['%s', '%s', '%s']", options.Compress, gzipCompression, zstdCompression, noCompression)
+	}
+	if options.PrettyPrint && options.OutputFormat != jsonFormat {
+		return fmt.Errorf("--prettyprint can't be used with --outputFormat=%s", options.OutputFormat)
+	}
 	// if docs are written to stdout, do not pollute the output with logs
 	if options.Output == stdoutOutput {
 		logger = io.Discard
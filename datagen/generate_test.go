@@ -2,12 +2,16 @@ package datagen_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
+	"reflect"
 	"regexp"
 	"sort"
 	"strings"
@@ -17,8 +21,8 @@ import (
 	"github.com/feliixx/mgodatagen/datagen"
 	"github.com/feliixx/mgodatagen/datagen/generators"
 
+	"github.com/klauspost/compress/zstd"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/bsontype"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -347,6 +351,707 @@ func TestIndentJSONOutputToFile(t *testing.T) {
 	}
 }
 
+func TestNDJSONOutputToFilePerCollection(t *testing.T) {
+
+	outputFileName := "/tmp/mgodatagen_test.test.ndjson"
+
+	defer os.Remove(outputFileName)
+
+	outputTests := []struct {
+		name       string
+		jsonFormat string
+		want       string
+	}{
+		{
+			name:       "canonical",
+			jsonFormat: "canonical",
+			want: `{"_id": {"$numberInt":"0"}}
+{"_id": {"$numberInt":"1"}}
+{"_id": {"$numberInt":"2"}}
+`,
+		},
+		{
+			name:       "relaxed",
+			jsonFormat: "relaxed",
+			want: `{"_id":0}
+{"_id":1}
+{"_id":2}
+`,
+		},
+	}
+
+	for _, tt := range outputTests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			os.Remove(outputFileName)
+
+			opts := datagen.Options{
+				Configuration: datagen.Configuration{
+					ConfigFile:   "testdata/only_id.json",
+					BatchSize:    1000,
+					Output:       "/tmp/{db}.{collection}.ndjson",
+					OutputFormat: "ndjson",
+					JSONFormat:   tt.jsonFormat,
+					Seed:         123456789,
+				},
+			}
+
+			err := datagen.Generate(&opts, io.Discard)
+			if err != nil {
+				t.Errorf("fail to write to file: %v", err)
+			}
+
+			got, err := os.ReadFile(outputFileName)
+			if err != nil {
+				t.Errorf("fail to read from %s: %v", outputFileName, err)
+			}
+			if tt.want != string(got) {
+				t.Errorf("expected\n\n'%s'\n\nbut got\n\n'%s'", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCSVOutputToFile(t *testing.T) {
+
+	outputFileName := "/tmp/mgodatagen_test.csv"
+
+	defer os.Remove(outputFileName)
+
+	outputTests := []struct {
+		name      string
+		format    string
+		arrayMode string
+		want      string
+	}{
+		{
+			name:      "csv json",
+			format:    "csv",
+			arrayMode: "json",
+			want: `_id,name,address.city,tags
+0,,Paris,"[""x""]"
+1,,Paris,"[""y""]"
+2,,Paris,"[""x""]"
+3,"a,b",Paris,"[""y"",""x""]"
+`,
+		},
+		{
+			name:      "csv index",
+			format:    "csv",
+			arrayMode: "index",
+			want: `_id,name,address.city,tags.0,tags.1
+0,,Paris,x,
+1,,Paris,y,
+2,,Paris,x,
+3,"a,b",Paris,y,x
+`,
+		},
+		{
+			name:      "csv explode",
+			format:    "csv",
+			arrayMode: "explode",
+			want: `_id,name,address.city,tags
+0,,Paris,x
+1,,Paris,y
+2,,Paris,x
+3,"a,b",Paris,y
+3,"a,b",Paris,x
+`,
+		},
+		{
+			name:      "tsv index",
+			format:    "tsv",
+			arrayMode: "index",
+			want: "_id\tname\taddress.city\ttags.0\ttags.1\n" +
+				"0\t\tParis\tx\t\n" +
+				"1\t\tParis\ty\t\n" +
+				"2\t\tParis\tx\t\n" +
+				"3\ta,b\tParis\ty\tx\n",
+		},
+	}
+
+	for _, tt := range outputTests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			os.Remove(outputFileName)
+
+			opts := datagen.Options{
+				Configuration: datagen.Configuration{
+					ConfigFile:   "testdata/csv.json",
+					BatchSize:    1000,
+					Output:       outputFileName,
+					OutputFormat: tt.format,
+					ArrayMode:    tt.arrayMode,
+					Seed:         5,
+				},
+			}
+
+			err := datagen.Generate(&opts, io.Discard)
+			if err != nil {
+				t.Errorf("fail to write to file: %v", err)
+			}
+
+			got, err := os.ReadFile(outputFileName)
+			if err != nil {
+				t.Errorf("fail to read from %s: %v", outputFileName, err)
+			}
+			if tt.want != string(got) {
+				t.Errorf("expected\n\n'%s'\n\nbut got\n\n'%s'", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCompressedOutputToFile(t *testing.T) {
+
+	want := `{"_id":0}
+{"_id":1}
+{"_id":2}
+`
+
+	outputTests := []struct {
+		name       string
+		output     string
+		compress   string
+		decompress func(r io.Reader) (io.Reader, error)
+	}{
+		{
+			name:   "gzip from extension",
+			output: "/tmp/mgodatagen_test.ndjson.gz",
+			decompress: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+		},
+		{
+			name:   "zstd from extension",
+			output: "/tmp/mgodatagen_test.ndjson.zst",
+			decompress: func(r io.Reader) (io.Reader, error) {
+				return zstd.NewReader(r)
+			},
+		},
+		{
+			name:     "gzip from flag",
+			output:   "/tmp/mgodatagen_test.ndjson",
+			compress: "gzip",
+			decompress: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+		},
+		{
+			name:     "disabled",
+			output:   "/tmp/mgodatagen_test.ndjson.gz",
+			compress: "none",
+			decompress: func(r io.Reader) (io.Reader, error) {
+				return r, nil
+			},
+		},
+	}
+
+	for _, tt := range outputTests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			defer os.Remove(tt.output)
+
+			opts := datagen.Options{
+				Configuration: datagen.Configuration{
+					ConfigFile:   "testdata/only_id.json",
+					BatchSize:    1000,
+					Output:       tt.output,
+					OutputFormat: "ndjson",
+					JSONFormat:   "relaxed",
+					Compress:     tt.compress,
+					Seed:         123456789,
+				},
+			}
+
+			err := datagen.Generate(&opts, io.Discard)
+			if err != nil {
+				t.Errorf("fail to write to file: %v", err)
+			}
+
+			f, err := os.Open(tt.output)
+			if err != nil {
+				t.Fatalf("fail to open %s: %v", tt.output, err)
+			}
+			defer f.Close()
+
+			r, err := tt.decompress(f)
+			if err != nil {
+				t.Fatalf("fail to decompress %s: %v", tt.output, err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Errorf("fail to read from %s: %v", tt.output, err)
+			}
+			if want != string(got) {
+				t.Errorf("expected\n\n'%s'\n\nbut got\n\n'%s'", want, got)
+			}
+		})
+	}
+}
+
+func TestBSONOutputToDirectory(t *testing.T) {
+
+	outputDir := "/tmp/mgodatagen_dump"
+
+	defer os.RemoveAll(outputDir)
+
+	opts := datagen.Options{
+		Configuration: datagen.Configuration{
+			ConfigFile:   "testdata/only_id.json",
+			BatchSize:    1000,
+			Output:       outputDir,
+			OutputFormat: "bson",
+			Seed:         123456789,
+		},
+	}
+
+	err := datagen.Generate(&opts, io.Discard)
+	if err != nil {
+		t.Errorf("fail to write to directory: %v", err)
+	}
+
+	b, err := os.ReadFile(outputDir + "/mgodatagen_test/test.bson")
+	if err != nil {
+		t.Error(err)
+	}
+	for i := int32(0); i < 3; i++ {
+		var doc bson.Raw
+		doc, b = nextDocument(b)
+		if got := doc.Lookup("_id").Int32(); got != i {
+			t.Errorf("expected _id %d but got %d", i, got)
+		}
+	}
+	if len(b) != 0 {
+		t.Errorf("expected only 3 documents, but got %d remaining bytes", len(b))
+	}
+
+	metadata, err := os.ReadFile(outputDir + "/mgodatagen_test/test.metadata.json")
+	if err != nil {
+		t.Error(err)
+	}
+	want := `{"options":{},"indexes":[{"v":{"$numberInt":"2"},"key":{"_id":{"$numberInt":"1"}},"name":"_id_"}],"collectionName":"test","type":"collection"}`
+	if want != string(metadata) {
+		t.Errorf("expected\n\n'%s'\n\nbut got\n\n'%s'", want, metadata)
+	}
+}
+
+func TestBSONOutputMetadataWithCollation(t *testing.T) {
+
+	outputDir := "/tmp/mgodatagen_dump_collation"
+
+	defer os.RemoveAll(outputDir)
+
+	opts := datagen.Options{
+		Configuration: datagen.Configuration{
+			ConfigFile:   "testdata/index_collation.json",
+			BatchSize:    1000,
+			Output:       outputDir,
+			OutputFormat: "bson",
+			Seed:         123456789,
+		},
+	}
+
+	err := datagen.Generate(&opts, io.Discard)
+	if err != nil {
+		t.Errorf("fail to write to directory: %v", err)
+	}
+
+	metadata, err := os.ReadFile(outputDir + "/mgodatagen_test/index_collation.metadata.json")
+	if err != nil {
+		t.Error(err)
+	}
+	// keys of the collation have to be in camel case, as expected by mongorestore
+	want := `"collation":{"locale":"fr","strength":{"$numberInt":"3"},"alternate":"shifted"}`
+	if !strings.Contains(string(metadata), want) {
+		t.Errorf("expected metadata to contain\n\n'%s'\n\nbut got\n\n'%s'", want, metadata)
+	}
+}
+
+func TestArchiveOutputToFile(t *testing.T) {
+
+	outputFileName := "/tmp/mgodatagen.archive"
+
+	defer os.Remove(outputFileName)
+
+	opts := datagen.Options{
+		Configuration: datagen.Configuration{
+			ConfigFile: "testdata/only_id.json",
+			BatchSize:  1000,
+			Output:     outputFileName,
+			Archive:    true,
+			Seed:       123456789,
+		},
+	}
+
+	err := datagen.Generate(&opts, io.Discard)
+	if err != nil {
+		t.Errorf("fail to write to file: %v", err)
+	}
+
+	b, err := os.ReadFile(outputFileName)
+	if err != nil {
+		t.Error(err)
+	}
+	if want, got := []byte{0x6d, 0xe2, 0x99, 0x81}, b[:4]; !bytes.Equal(want, got) {
+		t.Errorf("expected magic number %x but got %x", want, got)
+	}
+
+	// split the archive in blocks of documents separated by terminators
+	var blocks [][]bson.Raw
+	var block []bson.Raw
+	for b = b[4:]; len(b) > 0; {
+		if bytes.HasPrefix(b, []byte{0xFF, 0xFF, 0xFF, 0xFF}) {
+			blocks = append(blocks, block)
+			block = nil
+			b = b[4:]
+			continue
+		}
+		var doc bson.Raw
+		doc, b = nextDocument(b)
+		block = append(block, doc)
+	}
+
+	// prelude, documents, end of collection
+	if len(blocks) != 3 {
+		t.Fatalf("expected 3 blocks but got %d", len(blocks))
+	}
+	if got := blocks[0][1].Lookup("collection").StringValue(); got != "test" {
+		t.Errorf("expected collection 'test' in prelude, but got '%s'", got)
+	}
+	if got := len(blocks[1]); got != 4 {
+		t.Errorf("expected a namespace header and 3 documents, but got %d documents", got)
+	}
+	if eof := blocks[2][0].Lookup("EOF").Boolean(); !eof {
+		t.Error("expected last namespace header to have EOF set to true")
+	}
+}
+
+// nextDocument returns the first bson document of b and the remaining bytes
+func nextDocument(b []byte) (bson.Raw, []byte) {
+	n := binary.LittleEndian.Uint32(b)
+	return bson.Raw(b[:n]), b[n:]
+}
+
+func TestOutputDoesNotDependOnNumGenerator(t *testing.T) {
+
+	generate := func(numGenerator int) []byte {
+
+		outputFileName := fmt.Sprintf("/tmp/parallel_%d.json", numGenerator)
+		defer os.Remove(outputFileName)
+
+		opts := datagen.Options{
+			Configuration: datagen.Configuration{
+				ConfigFile:   "testdata/parallel.json",
+				BatchSize:    1000,
+				NumGenerator: numGenerator,
+				Output:       outputFileName,
+				Seed:         123456789,
+			},
+		}
+		err := datagen.Generate(&opts, io.Discard)
+		if err != nil {
+			t.Errorf("fail to write to file: %v", err)
+		}
+		got, err := os.ReadFile(outputFileName)
+		if err != nil {
+			t.Errorf("fail to read from %s: %v", outputFileName, err)
+		}
+		return got
+	}
+
+	want := generate(1)
+	for _, numGenerator := range []int{2, 3, 8} {
+		if got := generate(numGenerator); !bytes.Equal(want, got) {
+			t.Errorf("output with %d generators differs from the output with a single generator", numGenerator)
+		}
+	}
+}
+
+func TestUniqueFieldsOutputToFile(t *testing.T) {
+
+	generate := func(numGenerator int) []byte {
+
+		outputFileName := fmt.Sprintf("/tmp/unique_fields_%d.ndjson", numGenerator)
+		defer os.Remove(outputFileName)
+
+		opts := datagen.Options{
+			Configuration: datagen.Configuration{
+				ConfigFile:   "testdata/unique_fields.json",
+				BatchSize:    1000,
+				NumGenerator: numGenerator,
+				Output:       outputFileName,
+				OutputFormat: "ndjson",
+				JSONFormat:   "relaxed",
+				Seed:         123456789,
+			},
+		}
+		err := datagen.Generate(&opts, io.Discard)
+		if err != nil {
+			t.Errorf("fail to write to file: %v", err)
+		}
+		got, err := os.ReadFile(outputFileName)
+		if err != nil {
+			t.Errorf("fail to read from %s: %v", outputFileName, err)
+		}
+		return got
+	}
+
+	want := generate(1)
+
+	lines := bytes.Split(bytes.TrimSpace(want), []byte("\n"))
+	if len(lines) != 3000 {
+		t.Errorf("expected 3000 documents, but got %d", len(lines))
+	}
+	combinations := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		var doc map[string]any
+		if err := json.Unmarshal(line, &doc); err != nil {
+			t.Fatal(err)
+		}
+		// a missing lastName is considered as null
+		key := fmt.Sprint(doc["firstName"], "-", doc["lastName"])
+		if combinations[key] {
+			t.Errorf("combination %s is not unique", key)
+		}
+		combinations[key] = true
+	}
+
+	if got := generate(4); !bytes.Equal(want, got) {
+		t.Errorf("output with 4 generators differs from the output with a single generator")
+	}
+}
+
+func TestVariantsOutputToFile(t *testing.T) {
+
+	generate := func(numGenerator int) []byte {
+
+		outputFileName := fmt.Sprintf("/tmp/variants_%d.ndjson", numGenerator)
+		defer os.Remove(outputFileName)
+
+		opts := datagen.Options{
+			Configuration: datagen.Configuration{
+				ConfigFile:   "testdata/variants.json",
+				BatchSize:    1000,
+				NumGenerator: numGenerator,
+				Output:       outputFileName,
+				OutputFormat: "ndjson",
+				JSONFormat:   "relaxed",
+				Seed:         123456789,
+			},
+		}
+		err := datagen.Generate(&opts, io.Discard)
+		if err != nil {
+			t.Errorf("fail to write to file: %v", err)
+		}
+		got, err := os.ReadFile(outputFileName)
+		if err != nil {
+			t.Errorf("fail to read from %s: %v", outputFileName, err)
+		}
+		return got
+	}
+
+	want := generate(1)
+
+	lines := bytes.Split(bytes.TrimSpace(want), []byte("\n"))
+	if len(lines) != 3000 {
+		t.Errorf("expected 3000 documents, but got %d", len(lines))
+	}
+	versions := map[float64]int{}
+	for i, line := range lines {
+		var doc map[string]any
+		if err := json.Unmarshal(line, &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc["_id"] != float64(i) {
+			t.Errorf("expected _id %d, but got %v", i, doc["_id"])
+		}
+		version, _ := doc["schemaVersion"].(float64)
+		versions[version]++
+		switch version {
+		case 1:
+			if _, ok := doc["name"].(string); !ok {
+				t.Errorf("expected a string name for version 1, but got %v", doc["name"])
+			}
+		case 2:
+			if _, ok := doc["name"].(map[string]any); !ok {
+				t.Errorf("expected an object name for version 2, but got %v", doc["name"])
+			}
+		default:
+			t.Errorf("unexpected schemaVersion %v", doc["schemaVersion"])
+		}
+	}
+	if versions[1] < 1700 || versions[1] > 1900 {
+		t.Errorf("expected around 1800 documents of version 1, but got %d", versions[1])
+	}
+
+	if got := generate(4); !bytes.Equal(want, got) {
+		t.Errorf("output with 4 generators differs from the output with a single generator")
+	}
+}
+
+func TestReferenceCardinalityOutputToFile(t *testing.T) {
+
+	generate := func(numGenerator int) []byte {
+
+		outputFileName := fmt.Sprintf("/tmp/reference_cardinality_%d.ndjson", numGenerator)
+		defer os.Remove(outputFileName)
+
+		opts := datagen.Options{
+			Configuration: datagen.Configuration{
+				ConfigFile:   "testdata/reference_cardinality.json",
+				BatchSize:    1000,
+				NumGenerator: numGenerator,
+				Output:       outputFileName,
+				OutputFormat: "ndjson",
+				JSONFormat:   "relaxed",
+				Seed:         123456789,
+			},
+		}
+		err := datagen.Generate(&opts, io.Discard)
+		if err != nil {
+			t.Errorf("fail to write to file: %v", err)
+		}
+		got, err := os.ReadFile(outputFileName)
+		if err != nil {
+			t.Errorf("fail to read from %s: %v", outputFileName, err)
+		}
+		return got
+	}
+
+	want := generate(1)
+
+	lines := bytes.Split(bytes.TrimSpace(want), []byte("\n"))
+	if len(lines) != 200+2500 {
+		t.Errorf("expected 2700 documents, but got %d", len(lines))
+	}
+	orders := map[float64]int{}
+	names := map[float64]any{}
+	for _, line := range lines {
+		var doc map[string]any
+		if err := json.Unmarshal(line, &doc); err != nil {
+			t.Fatal(err)
+		}
+		customerID, ok := doc["customerId"].(float64)
+		if !ok {
+			names[doc["_id"].(float64)] = doc["name"]
+			continue
+		}
+		orders[customerID]++
+		// customers are written before orders
+		customer, _ := doc["customer"].(map[string]any)
+		if customer["_id"] != customerID || customer["name"] != names[customerID] {
+			t.Errorf("expected a copy of customer %v, but got %v", customerID, customer)
+		}
+	}
+	for customerID := 0; customerID < 200; customerID++ {
+		if n := orders[float64(customerID)]; n < 1 || n > 20 {
+			t.Errorf("expected between 1 and 20 orders for customer %d, but got %d", customerID, n)
+		}
+	}
+
+	if got := generate(4); !bytes.Equal(want, got) {
+		t.Errorf("output with 4 generators differs from the output with a single generator")
+	}
+}
+
+func TestGraphOutputToFile(t *testing.T) {
+
+	generate := func(numGenerator int) ([]byte, string) {
+
+		outputFileName := fmt.Sprintf("/tmp/graph_%d.ndjson", numGenerator)
+		defer os.Remove(outputFileName)
+
+		opts := datagen.Options{
+			Configuration: datagen.Configuration{
+				ConfigFile:   "testdata/graph.json",
+				BatchSize:    1000,
+				NumGenerator: numGenerator,
+				Output:       outputFileName,
+				OutputFormat: "ndjson",
+				JSONFormat:   "relaxed",
+				Seed:         123456789,
+			},
+		}
+		var buffer bytes.Buffer
+		err := datagen.Generate(&opts, &buffer)
+		if err != nil {
+			t.Errorf("fail to write to file: %v", err)
+		}
+		got, err := os.ReadFile(outputFileName)
+		if err != nil {
+			t.Errorf("fail to read from %s: %v", outputFileName, err)
+		}
+		return got, buffer.String()
+	}
+
+	want, summary := generate(1)
+
+	lines := bytes.Split(bytes.TrimSpace(want), []byte("\n"))
+	if len(lines) != 1500+6000 {
+		t.Errorf("expected 7500 documents, but got %d", len(lines))
+	}
+	type edge struct{ from, to float64 }
+	edges := map[edge]bool{}
+	names := map[float64]any{}
+	outDegrees := map[float64]int{}
+	for _, line := range lines {
+		var doc map[string]any
+		if err := json.Unmarshal(line, &doc); err != nil {
+			t.Fatal(err)
+		}
+		from, ok := doc["from"].(float64)
+		if !ok {
+			names[doc["_id"].(float64)] = doc["name"]
+			continue
+		}
+		to := doc["to"].(float64)
+		if from == to {
+			t.Errorf("unexpected self-loop %v", doc)
+		}
+		if edges[edge{from, to}] {
+			t.Errorf("duplicated edge %v", doc)
+		}
+		edges[edge{from, to}] = true
+		outDegrees[from]++
+		// users are written before follows
+		if _, ok := names[to]; !ok {
+			t.Errorf("edge to an unknown user %v", doc)
+		}
+		if followed, _ := doc["followed"].(map[string]any); followed["name"] != names[to] {
+			t.Errorf("expected a copy of user %v, but got %v", to, followed)
+		}
+	}
+	reciprocal := 0
+	for e := range edges {
+		if edges[edge{e.to, e.from}] {
+			reciprocal++
+		}
+	}
+	if reciprocal != 2400 {
+		t.Errorf("expected 40%% of reciprocal edges, but got %d out of %d", reciprocal, len(edges))
+	}
+	if len(outDegrees) != 1500 {
+		t.Errorf("expected all the %d users to follow other users, but only %d do", 1500, len(outDegrees))
+	}
+
+	maxDegree := 0
+	for _, n := range outDegrees {
+		if n > maxDegree {
+			maxDegree = n
+		}
+	}
+	for _, s := range []string{"follows", "0.40", fmt.Sprintf("max  %d ", maxDegree)} {
+		if !strings.Contains(summary, s) {
+			t.Errorf("expected '%s' in the degree statistics of the summary, but got\n%s", s, summary)
+		}
+	}
+
+	if got, _ := generate(4); !bytes.Equal(want, got) {
+		t.Errorf("output with 4 generators differs from the output with a single generator")
+	}
+}
+
 func TestCollectionContent(t *testing.T) {
 
 	configFile := "generators/testdata/full-bson.json"
@@ -515,10 +1220,12 @@ func TestCollectionContent(t *testing.T) {
 		t.Errorf("doc nb with int64 == null should be 75 < count < 125 (2.5percent) but was %d", count)
 	}
 
+	nameConfig, _ := collections[0].Content.Get("name")
+
 	// we expect fixed values for those keys
 	maxDistinctValuesTests := map[string]int{
 		// test maxDistinctValues option
-		"name": int(collections[0].Content["name"].MaxDistinctValue),
+		"name": int(nameConfig.MaxDistinctValue),
 		// test unique option
 		"object.k1": 1000,
 		// test value distribution
@@ -652,6 +1359,99 @@ func TestCollectionContentWithAggregation(t *testing.T) {
 	}
 }
 
+func TestAggregationOutputToFile(t *testing.T) {
+
+	outputFileName := "/tmp/mgodatagen_test.{collection}.ndjson"
+	defer os.Remove("/tmp/mgodatagen_test.test.ndjson")
+	defer os.Remove("/tmp/mgodatagen_test.test_bson.ndjson")
+
+	opts := datagen.Options{
+		Configuration: datagen.Configuration{
+			ConfigFile:   "generators/testdata/full-aggregation.json",
+			BatchSize:    1000,
+			Output:       outputFileName,
+			OutputFormat: "ndjson",
+			JSONFormat:   "relaxed",
+			Seed:         123456789,
+		},
+	}
+	err := datagen.Generate(&opts, io.Discard)
+	if err != nil {
+		t.Fatalf("fail to write to file: %v", err)
+	}
+
+	// compute the expected aggregations from the generated source collection
+	type aggregation struct {
+		count    int64
+		min, max int32
+		values   []string
+	}
+	want := make(map[int32]*aggregation)
+
+	for _, line := range readLines(t, "/tmp/mgodatagen_test.test.ndjson") {
+		var doc struct {
+			Link   int32
+			Field  *string
+			Field1 *int32
+		}
+		bson.UnmarshalExtJSON(line, false, &doc)
+
+		a, ok := want[doc.Link]
+		if !ok {
+			a = &aggregation{min: math.MaxInt32, max: math.MinInt32}
+			want[doc.Link] = a
+		}
+		a.count++
+		if doc.Field1 != nil {
+			if *doc.Field1 < a.min {
+				a.min = *doc.Field1
+			}
+			if *doc.Field1 > a.max {
+				a.max = *doc.Field1
+			}
+		}
+		if doc.Field != nil {
+			i := sort.SearchStrings(a.values, *doc.Field)
+			if i == len(a.values) || a.values[i] != *doc.Field {
+				a.values = append(a.values[:i], append([]string{*doc.Field}, a.values[i:]...)...)
+			}
+		}
+	}
+
+	lines := readLines(t, "/tmp/mgodatagen_test.test_bson.ndjson")
+	if len(lines) != 6 {
+		t.Fatalf("expected 6 documents, but got %d", len(lines))
+	}
+	for _, line := range lines {
+		var doc struct {
+			ID    int32 `bson:"_id"`
+			Count int64 `bson:"AG-FI"`
+			Bound struct {
+				Min int32 `bson:"m"`
+				Max int32 `bson:"M"`
+			} `bson:"AG-CI"`
+			Values []string `bson:"AG-VA"`
+		}
+		err := bson.UnmarshalExtJSON(line, false, &doc)
+		if err != nil {
+			t.Fatalf("fail to decode document %s: %v", line, err)
+		}
+		w := want[doc.ID]
+		got := aggregation{count: doc.Count, min: doc.Bound.Min, max: doc.Bound.Max, values: doc.Values}
+		if !reflect.DeepEqual(*w, got) {
+			t.Errorf("for _id %d, expected %v but got %v", doc.ID, *w, got)
+		}
+	}
+}
+
+func readLines(t *testing.T, fileName string) [][]byte {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("fail to read from %s: %v", fileName, err)
+	}
+	return bytes.Split(bytes.TrimSuffix(content, []byte("\n")), []byte("\n"))
+}
+
 func TestCollectionCompression(t *testing.T) {
 
 	createCollectionTests := []struct {
@@ -1120,17 +1920,132 @@ func TestGenerate(t *testing.T) {
 			expectedNbDoc: 0,
 		},
 		{
-			name: "stdout output with aggregators",
+			name: "invalid output format",
+			options: datagen.Options{
+				Configuration: datagen.Configuration{
+					ConfigFile:   "testdata/only_id.json",
+					BatchSize:    1000,
+					Output:       "stdout",
+					OutputFormat: "xml",
+				},
+				General: defaultGeneralOpts,
+			},
+			correct:     false,
+			errMsgRegex: regexp.MustCompile("^invalid value for --outputFormat.*"),
+		},
+		{
+			name: "invalid json format",
+			options: datagen.Options{
+				Configuration: datagen.Configuration{
+					ConfigFile: "testdata/only_id.json",
+					BatchSize:  1000,
+					Output:     "stdout",
+					JSONFormat: "compact",
+				},
+				General: defaultGeneralOpts,
+			},
+			correct:     false,
+			errMsgRegex: regexp.MustCompile("^invalid value for --jsonFormat.*"),
+		},
+		{
+			name: "invalid array mode",
+			options: datagen.Options{
+				Configuration: datagen.Configuration{
+					ConfigFile:   "testdata/only_id.json",
+					BatchSize:    1000,
+					Output:       "stdout",
+					OutputFormat: "csv",
+					ArrayMode:    "flatten",
+				},
+				General: defaultGeneralOpts,
+			},
+			correct:     false,
+			errMsgRegex: regexp.MustCompile("^invalid value for --arrayMode.*"),
+		},
+		{
+			name: "invalid compression",
+			options: datagen.Options{
+				Configuration: datagen.Configuration{
+					ConfigFile: "testdata/only_id.json",
+					BatchSize:  1000,
+					Output:     "stdout",
+					Compress:   "lz4",
+				},
+				General: defaultGeneralOpts,
+			},
+			correct:     false,
+			errMsgRegex: regexp.MustCompile("^invalid value for --compress.*"),
+		},
+		{
+			name: "compression with mongodb output",
+			options: datagen.Options{
+				Configuration: datagen.Configuration{
+					ConfigFile: "testdata/only_id.json",
+					BatchSize:  1000,
+					Compress:   "gzip",
+				},
+				Connection: defaultConnOpts,
+				General:    defaultGeneralOpts,
+			},
+			correct:     false,
+			errMsgRegex: regexp.MustCompile("^--compress can only be used with stdout or file output.*"),
+		},
+		{
+			name: "bson format with mongodb output",
+			options: datagen.Options{
+				Connection: defaultConnOpts,
+				Configuration: datagen.Configuration{
+					ConfigFile:   "testdata/only_id.json",
+					BatchSize:    1000,
+					OutputFormat: "bson",
+				},
+				General: defaultGeneralOpts,
+			},
+			correct:     false,
+			errMsgRegex: regexp.MustCompile("^--outputFormat and --archive can only be used with stdout or file output.*"),
+		},
+		{
+			name: "archive with json format",
+			options: datagen.Options{
+				Configuration: datagen.Configuration{
+					ConfigFile:   "testdata/only_id.json",
+					BatchSize:    1000,
+					Output:       "stdout",
+					OutputFormat: "json",
+					Archive:      true,
+				},
+				General: defaultGeneralOpts,
+			},
+			correct:     false,
+			errMsgRegex: regexp.MustCompile("^--archive can't be used with --outputFormat=json.*"),
+		},
+		{
+			name: "ndjson with prettyprint",
+			options: datagen.Options{
+				Configuration: datagen.Configuration{
+					ConfigFile:   "testdata/only_id.json",
+					BatchSize:    1000,
+					Output:       "stdout",
+					OutputFormat: "ndjson",
+					PrettyPrint:  true,
+				},
+				General: defaultGeneralOpts,
+			},
+			correct:     false,
+			errMsgRegex: regexp.MustCompile("^--prettyprint can't be used with --outputFormat=ndjson.*"),
+		},
+		{
+			name: "stdout output with aggregators on a collection declared after",
 			options: datagen.Options{
 				Configuration: datagen.Configuration{
-					ConfigFile: "generators/testdata/full-aggregation.json",
+					ConfigFile: "testdata/invalid/aggregator-order.json",
 					BatchSize:  1000,
 					Output:     "stdout",
 				},
 				General: defaultGeneralOpts,
 			},
 			correct:     false,
-			errMsgRegex: regexp.MustCompile("^aggregators are not supported for stdout or file output.*"),
+			errMsgRegex: regexp.MustCompile("^fail to create Aggregator for collection 'test_bson'\ninvalid generator for field 'count'\n  cause: collection 'mgodatagen_test.test' has to be declared before.*"),
 		},
 	}
 
@@ -1328,12 +2243,11 @@ func FuzzDocGenerator(f *testing.F) {
 			return
 		}
 
-		mapRef := make(map[int][][]byte)
-		mapRefType := make(map[int]bsontype.Type)
+		references := make(generators.References)
 
 		for i := 0; i < len(collections); i++ {
 
-			ci := generators.NewCollInfo(collections[i].Count, []int{5, 0, 5}, uint64(0), mapRef, mapRefType)
+			ci := generators.NewCollInfo(collections[i].Count, []int{5, 0, 5}, uint64(0), references)
 
 			docGen, err := ci.NewDocumentGenerator(collections[i].Content)
 			if err != nil {
@@ -3,11 +3,15 @@ package generators
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
 )
 
 // Aggregator is a type of generator that use another collection
@@ -24,6 +28,24 @@ type Aggregator interface {
 	//
 	//  { "_id": 1 }, { "$set": { "newField": ["a", "c", "f"] } }
 	Update(session *mongo.Client, value any) ([2]bson.M, error)
+
+	// returns the key of the aggregated field
+	Key() string
+	// returns the database and the collection to run the aggregation on
+	Source() (database, collection string)
+
+	// The following methods compute the aggregation in-process, without a
+	// database. All the documents of the source collection have to be indexed
+	// before calling AppendValue()
+
+	// makes sure the aggregation can be computed in-process, ie that
+	// the query only holds equality conditions
+	PrepareIndex() error
+	// adds a document of the source collection to the aggregation
+	Index(doc bson.Raw)
+	// appends the aggregated field of doc to dst, using the value of
+	// the local field of doc. Nothing is appended if doc has no local field
+	AppendValue(dst []byte, doc bson.Raw) []byte
 }
 
 type baseAggregator struct {
@@ -32,10 +54,118 @@ type baseAggregator struct {
 	collection string
 	database   string
 	localVar   string
+
+	// used to compute the aggregation in-process
+	localField  []string
+	constFields []constCondition
+	entries     map[string]*aggregationEntry
+}
+
+// constCondition is a condition of the query that doesn't depend on the
+// local field, like { "status": "active" }
+type constCondition struct {
+	path  []string
+	value bson.RawValue
+}
+
+// aggregationEntry holds the aggregated values of the documents
+// of the source collection sharing the same local value
+type aggregationEntry struct {
+	count    int64
+	values   []bson.RawValue
+	seen     map[string]bool
+	min, max bson.RawValue
+	// values are sorted the first time they are appended to a document,
+	// once all the documents of the source collection are indexed
+	sorted bool
 }
 
-func (a baseAggregator) Query() bson.M    { return a.query }
-func (a baseAggregator) LocalVar() string { return a.localVar }
+func (a baseAggregator) Query() bson.M                         { return a.query }
+func (a baseAggregator) LocalVar() string                      { return a.localVar }
+func (a baseAggregator) Key() string                           { return a.key }
+func (a baseAggregator) Source() (database, collection string) { return a.database, a.collection }
+
+func (a *baseAggregator) PrepareIndex() error {
+
+	a.entries = make(map[string]*aggregationEntry)
+	a.localField, a.constFields = nil, nil
+
+	for k, v := range a.query {
+		if s := fmt.Sprintf("%v", v); strings.Contains(s, "$$") {
+			a.localField = strings.Split(k, ".")
+			continue
+		}
+		t, b, err := bson.MarshalValue(v)
+		if err != nil {
+			return fmt.Errorf("invalid value for field '%s' in query: %v", k, err)
+		}
+		value := bson.RawValue{Type: t, Value: b}
+		if value.Type == bson.TypeEmbeddedDocument {
+			elements, _ := value.Document().Elements()
+			if len(elements) > 0 && strings.HasPrefix(elements[0].Key(), "$") {
+				return fmt.Errorf("operator '%s' in query of field '%s' is not supported for stdout or file output, only equality conditions are", elements[0].Key(), a.key)
+			}
+		}
+		a.constFields = append(a.constFields, constCondition{path: strings.Split(k, "."), value: value})
+	}
+	// make sure the order of the conditions doesn't depend on the map iteration
+	sort.Slice(a.constFields, func(i, j int) bool {
+		return strings.Join(a.constFields[i].path, ".") < strings.Join(a.constFields[j].path, ".")
+	})
+	return nil
+}
+
+// matchingEntries returns the entries a document of the source collection
+// belongs to. A document belongs to several entries if its local field is
+// an array, and to none if it doesn't match the query
+func (a *baseAggregator) matchingEntries(doc bson.Raw) []*aggregationEntry {
+
+	for _, c := range a.constFields {
+		if !matches(doc, c.path, c.value) {
+			return nil
+		}
+	}
+	// without local field, all documents share the same entry
+	if a.localField == nil {
+		return []*aggregationEntry{a.entry("")}
+	}
+	value, err := doc.LookupErr(a.localField...)
+	if err != nil {
+		return nil
+	}
+	var entries []*aggregationEntry
+	for _, v := range unwind(value) {
+		entries = append(entries, a.entry(valueKey(v)))
+	}
+	return entries
+}
+
+func (a *baseAggregator) entry(key string) *aggregationEntry {
+	e, ok := a.entries[key]
+	if !ok {
+		e = &aggregationEntry{seen: make(map[string]bool)}
+		a.entries[key] = e
+	}
+	return e
+}
+
+// lookupEntry returns the entry of a document of the aggregated collection,
+// or false if the document has no local field
+func (a *baseAggregator) lookupEntry(doc bson.Raw) (*aggregationEntry, bool) {
+	value, err := doc.LookupErr(strings.Split(a.localVar, ".")...)
+	if err != nil {
+		return nil, false
+	}
+	key := ""
+	if a.localField != nil {
+		key = valueKey(value)
+	}
+	e, ok := a.entries[key]
+	if !ok {
+		e = &aggregationEntry{}
+	}
+	return e, true
+}
 
 type countAggregator struct {
 	baseAggregator
@@ -54,6 +184,20 @@ func (a *countAggregator) Update(session *mongo.Client, value any) ([2]bson.M, e
 	return [2]bson.M{{a.localVar: value}, {"$set": bson.M{a.key: count}}}, nil
 }
 
+func (a *countAggregator) Index(doc bson.Raw) {
+	for _, e := range a.matchingEntries(doc) {
+		e.count++
+	}
+}
+
+func (a *countAggregator) AppendValue(dst []byte, doc bson.Raw) []byte {
+	e, ok := a.lookupEntry(doc)
+	if !ok {
+		return dst
+	}
+	return bsoncore.AppendInt64Element(dst, a.key, e.count)
+}
+
 type valueAggregator struct {
 	baseAggregator
 	field string
@@ -79,6 +223,45 @@ func (a *valueAggregator) Update(session *mongo.Client, value any) ([2]bson.M, e
 	return [2]bson.M{{a.localVar: value}, {"$set": bson.M{a.key: distinct.Values}}}, nil
 }
 
+func (a *valueAggregator) Index(doc bson.Raw) {
+	entries := a.matchingEntries(doc)
+	if len(entries) == 0 {
+		return
+	}
+	value, err := doc.LookupErr(strings.Split(a.field, ".")...)
+	if err != nil {
+		return
+	}
+	for _, v := range unwind(value) {
+		key := valueKey(v)
+		for _, e := range entries {
+			if !e.seen[key] {
+				e.seen[key] = true
+				e.values = append(e.values, copyValue(v))
+			}
+		}
+	}
+}
+
+func (a *valueAggregator) AppendValue(dst []byte, doc bson.Raw) []byte {
+	e, ok := a.lookupEntry(doc)
+	if !ok {
+		return dst
+	}
+	if !e.sorted {
+		sort.SliceStable(e.values, func(i, j int) bool {
+			return compareValues(e.values[i], e.values[j]) < 0
+		})
+		e.sorted = true
+	}
+	idx, dst := bsoncore.AppendArrayElementStart(dst, a.key)
+	for i, v := range e.values {
+		dst = bsoncore.AppendValueElement(dst, strconv.Itoa(i), bsoncore.Value{Type: v.Type, Data: v.Value})
+	}
+	dst, _ = bsoncore.AppendArrayEnd(dst, idx)
+	return dst
+}
+
 type boundAggregator struct {
 	baseAggregator
 	field string
@@ -121,6 +304,51 @@ func (a *boundAggregator) Update(session *mongo.Client, value any) ([2]bson.M, e
 	return [2]bson.M{{a.localVar: value}, {"$set": bson.M{a.key: bound}}}, nil
 }
 
+func (a *boundAggregator) Index(doc bson.Raw) {
+	entries := a.matchingEntries(doc)
+	if len(entries) == 0 {
+		return
+	}
+	value, err := doc.LookupErr(strings.Split(a.field, ".")...)
+	if err != nil {
+		return
+	}
+	for _, v := range unwind(value) {
+		if v.Type == bson.TypeNull || v.Type == bson.TypeUndefined {
+			continue
+		}
+		for _, e := range entries {
+			if e.min.Type == 0 || compareValues(v, e.min) < 0 {
+				e.min = copyValue(v)
+			}
+			if e.max.Type == 0 || compareValues(v, e.max) > 0 {
+				e.max = copyValue(v)
+			}
+		}
+	}
+}
+
+func (a *boundAggregator) AppendValue(dst []byte, doc bson.Raw) []byte {
+	e, ok := a.lookupEntry(doc)
+	if !ok {
+		return dst
+	}
+	idx, dst := bsoncore.AppendDocumentElementStart(dst, a.key)
+	dst = appendBound(dst, "m", e.min)
+	dst = appendBound(dst, "M", e.max)
+	dst, _ = bsoncore.AppendDocumentEnd(dst, idx)
+	return dst
+}
+
+// appendBound appends a bound to dst, or null if
+// no document matched the query
+func appendBound(dst []byte, key string, v bson.RawValue) []byte {
+	if v.Type == 0 {
+		return bsoncore.AppendNullElement(dst, key)
+	}
+	return bsoncore.AppendValueElement(dst, key, bsoncore.Value{Type: v.Type, Data: v.Value})
+}
+
 func createQuery(formatQuery bson.M, value any) bson.M {
 	q := bson.M{}
 	for k, v := range formatQuery {
@@ -132,3 +360,171 @@ func createQuery(formatQuery bson.M, value any) bson.M {
 	}
 	return q
 }
+
+// AppendAggregations returns doc with the fields computed by aggregators appended
+// at the end, like with a '$set' update. dst is used as a buffer for the new document
+func AppendAggregations(dst []byte, doc bson.Raw, aggregators []Aggregator) []byte {
+	// copy the document without its terminating byte
+	dst = append(dst[:0], doc[:len(doc)-1]...)
+	for _, a := range aggregators {
+		dst = a.AppendValue(dst, doc)
+	}
+	dst = append(dst, 0)
+	return bsoncore.UpdateLength(dst, 0, int32(len(dst)))
+}
+
+// matches returns true if the value of doc at path is equal to value. Like
+// in mongodb, an array matches if one of its elements is equal to value
+func matches(doc bson.Raw, path []string, value bson.RawValue) bool {
+	v, err := doc.LookupErr(path...)
+	if err != nil {
+		return value.Type == bson.TypeNull
+	}
+	key := valueKey(value)
+	if valueKey(v) == key {
+		return true
+	}
+	for _, elem := range unwind(v) {
+		if valueKey(elem) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// copyValue returns a copy of v, as the indexed documents
+// are reused once written
+func copyValue(v bson.RawValue) bson.RawValue {
+	return bson.RawValue{Type: v.Type, Value: append([]byte(nil), v.Value...)}
+}
+
+// unwind returns the elements of an array, or the value itself if
+// it's not an array
+func unwind(value bson.RawValue) []bson.RawValue {
+	if value.Type != bson.TypeArray {
+		return []bson.RawValue{value}
+	}
+	values, _ := value.Array().Values()
+	return values
+}
+
+// valueKey returns a key identifying a value. Like in mongodb, numbers
+// of different types holding the same value are equal
+func valueKey(v bson.RawValue) string {
+	switch v.Type {
+	case bson.TypeInt32:
+		return "n" + strconv.FormatInt(int64(v.Int32()), 10)
+	case bson.TypeInt64:
+		return "n" + strconv.FormatInt(v.Int64(), 10)
+	case bson.TypeDouble:
+		f := v.Double()
+		if f == float64(int64(f)) {
+			return "n" + strconv.FormatInt(int64(f), 10)
+		}
+		return "n" + strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return string(rune(v.Type)) + string(v.Value)
+}
+
+// typeOrder gives the order of bson types used by mongodb
+// when comparing values of different types
+var typeOrder = map[bsontype.Type]int{
+	bson.TypeMinKey:           0,
+	bson.TypeUndefined:        1,
+	bson.TypeNull:             1,
+	bson.TypeInt32:            2,
+	bson.TypeInt64:            2,
+	bson.TypeDouble:           2,
+	bson.TypeDecimal128:       2,
+	bson.TypeSymbol:           3,
+	bson.TypeString:           3,
+	bson.TypeEmbeddedDocument: 4,
+	bson.TypeArray:            5,
+	bson.TypeBinary:           6,
+	bson.TypeObjectID:         7,
+	bson.TypeBoolean:          8,
+	bson.TypeDateTime:         9,
+	bson.TypeTimestamp:        10,
+	bson.TypeRegex:            11,
+	bson.TypeMaxKey:           12,
+}
+
+// compareValues compares two bson values, returning -1, 0 or +1. Values
+// of different types are ordered like in mongodb
+func compareValues(a, b bson.RawValue) int {
+
+	if oa, ob := typeOrder[a.Type], typeOrder[b.Type]; oa != ob {
+		return compareInts(int64(oa), int64(ob))
+	}
+
+	switch a.Type {
+	case bson.TypeInt32, bson.TypeInt64, bson.TypeDouble, bson.TypeDecimal128:
+		if isInteger(a) && isInteger(b) {
+			return compareInts(numberAsInt(a), numberAsInt(b))
+		}
+		fa, fb := numberAsFloat(a), numberAsFloat(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	case bson.TypeString, bson.TypeSymbol:
+		return strings.Compare(stringOf(a), stringOf(b))
+	case bson.TypeBoolean:
+		return compareInts(boolAsInt(a.Boolean()), boolAsInt(b.Boolean()))
+	case bson.TypeDateTime:
+		return compareInts(a.DateTime(), b.DateTime())
+	}
+	return strings.Compare(string(a.Value), string(b.Value))
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolAsInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// stringOf returns the value of a string or of a symbol
+func stringOf(v bson.RawValue) string {
+	if v.Type == bson.TypeSymbol {
+		return v.Symbol()
+	}
+	return v.StringValue()
+}
+
+func isInteger(v bson.RawValue) bool {
+	return v.Type == bson.TypeInt32 || v.Type == bson.TypeInt64
+}
+
+func numberAsInt(v bson.RawValue) int64 {
+	if v.Type == bson.TypeInt32 {
+		return int64(v.Int32())
+	}
+	return v.Int64()
+}
+
+func numberAsFloat(v bson.RawValue) float64 {
+	switch v.Type {
+	case bson.TypeInt32:
+		return float64(v.Int32())
+	case bson.TypeInt64:
+		return float64(v.Int64())
+	case bson.TypeDecimal128:
+		f, _ := strconv.ParseFloat(v.Decimal128().String(), 64)
+		return f
+	}
+	return v.Double()
+}
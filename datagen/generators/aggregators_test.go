@@ -1,6 +1,7 @@
 package generators_test
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"reflect"
@@ -78,12 +79,12 @@ func TestNewAggregatorCond(t *testing.T) {
 		},
 	}
 
-	ci := generators.NewCollInfo(1, []int{3, 4}, defaultSeed, nil, nil)
+	ci := generators.NewCollInfo(1, []int{3, 4}, defaultSeed, nil)
 
 	for _, tt := range newAggregatorTests {
 		t.Run(tt.name, func(t *testing.T) {
-			var content = map[string]generators.Config{
-				"k": tt.config,
+			var content = generators.Content{
+				{Name: "k", Config: tt.config},
 			}
 			_, err := ci.NewAggregatorSlice(content)
 			if tt.correct && err != nil {
@@ -101,17 +102,17 @@ func TestNewAggregatorFromMap(t *testing.T) {
 
 	documentAggregatorTests := []struct {
 		name          string
-		config        map[string]generators.Config
+		config        generators.Content
 		correct       bool
 		nBaggregators int
 	}{
 		{
 			name: "empty collection name",
-			config: map[string]generators.Config{
-				"key": {
+			config: generators.Content{
+				{Name: "key", Config: generators.Config{
 					Type:       generators.TypeValueAggregator,
 					Collection: "",
-				},
+				}},
 			},
 			correct:       false,
 			nBaggregators: 0,
@@ -128,7 +129,7 @@ func TestNewAggregatorFromMap(t *testing.T) {
 		},
 	}
 
-	ci := generators.NewCollInfo(1, []int{3, 4}, defaultSeed, nil, nil)
+	ci := generators.NewCollInfo(1, []int{3, 4}, defaultSeed, nil)
 
 	for _, tt := range documentAggregatorTests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -236,7 +237,7 @@ func TestAggregatorUpdate(t *testing.T) {
 		},
 	}
 
-	ci := generators.NewCollInfo(1, []int{3, 4}, defaultSeed, nil, nil)
+	ci := generators.NewCollInfo(1, []int{3, 4}, defaultSeed, nil)
 	session, err := mongo.Connect(context.Background(), options.Client().
 		ApplyURI("mongodb://127.0.0.1:27017").
 		SetRetryWrites(false))
@@ -267,9 +268,167 @@ func TestAggregatorUpdate(t *testing.T) {
 	}
 }
 
+func TestAggregatorIndex(t *testing.T) {
+
+	aggregatorIndexTests := []struct {
+		name     string
+		baseDoc  []any
+		config   generators.Config
+		doc      bson.M
+		expected bson.D
+	}{
+		{
+			name: "countAggregator",
+			baseDoc: []any{
+				bson.M{"_id": 1, "local": 1},
+				bson.M{"_id": 2, "local": 2},
+				bson.M{"_id": 3, "local": int64(1)},
+			},
+			config: generators.Config{
+				Type:       generators.TypeCountAggregator,
+				Collection: "test",
+				Database:   "mgodatagen_test",
+				Query: bson.M{
+					"local": "$$_id",
+				},
+			},
+			doc:      bson.M{"_id": 1},
+			expected: bson.D{{Key: "_id", Value: int32(1)}, {Key: "key", Value: int64(2)}},
+		},
+		{
+			name: "countAggregator no matching document",
+			baseDoc: []any{
+				bson.M{"_id": 1, "local": 2},
+			},
+			config: generators.Config{
+				Type:       generators.TypeCountAggregator,
+				Collection: "test",
+				Database:   "mgodatagen_test",
+				Query: bson.M{
+					"local": "$$_id",
+				},
+			},
+			doc:      bson.M{"_id": 1},
+			expected: bson.D{{Key: "_id", Value: int32(1)}, {Key: "key", Value: int64(0)}},
+		},
+		{
+			name: "countAggregator no local field",
+			baseDoc: []any{
+				bson.M{"_id": 1, "field": 1},
+				bson.M{"_id": 2, "field": 2},
+				bson.M{"_id": 3, "field": []int{1, 3}},
+			},
+			config: generators.Config{
+				Type:       generators.TypeCountAggregator,
+				Collection: "test",
+				Database:   "mgodatagen_test",
+				Query: bson.M{
+					"field": 1,
+				},
+			},
+			doc:      bson.M{"_id": 1},
+			expected: bson.D{{Key: "_id", Value: int32(1)}, {Key: "key", Value: int64(2)}},
+		},
+		{
+			name: "valueAggregator",
+			baseDoc: []any{
+				bson.M{"_id": 3, "local": 1},
+				bson.M{"_id": 1, "local": 1},
+				bson.M{"_id": 2, "local": 2},
+				bson.M{"_id": 1, "local": 1},
+			},
+			config: generators.Config{
+				Type:       generators.TypeValueAggregator,
+				Collection: "test",
+				Database:   "mgodatagen_test",
+				Field:      "_id",
+				Query: bson.M{
+					"local": "$$_id",
+				},
+			},
+			doc:      bson.M{"_id": 1},
+			expected: bson.D{{Key: "_id", Value: int32(1)}, {Key: "key", Value: bson.A{int32(1), int32(3)}}},
+		},
+		{
+			name: "boundAggregator",
+			baseDoc: []any{
+				bson.M{"_id": 1, "local": 2},
+				bson.M{"_id": 2, "local": 1},
+				bson.M{"_id": 3, "local": 1},
+				bson.M{"_id": nil, "local": 1},
+			},
+			config: generators.Config{
+				Type:       generators.TypeBoundAggregator,
+				Collection: "test",
+				Database:   "mgodatagen_test",
+				Field:      "_id",
+				Query: bson.M{
+					"local": "$$_id",
+				},
+			},
+			doc:      bson.M{"_id": 1},
+			expected: bson.D{{Key: "_id", Value: int32(1)}, {Key: "key", Value: bson.D{{Key: "m", Value: int32(2)}, {Key: "M", Value: int32(3)}}}},
+		},
+		{
+			name:    "missing local field",
+			baseDoc: []any{bson.M{"_id": 1, "local": 1}},
+			config: generators.Config{
+				Type:       generators.TypeCountAggregator,
+				Collection: "test",
+				Database:   "mgodatagen_test",
+				Query: bson.M{
+					"local": "$$other",
+				},
+			},
+			doc:      bson.M{"_id": 1},
+			expected: bson.D{{Key: "_id", Value: int32(1)}},
+		},
+	}
+
+	ci := generators.NewCollInfo(1, []int{3, 4}, defaultSeed, nil)
+
+	for _, tt := range aggregatorIndexTests {
+		t.Run(tt.name, func(t *testing.T) {
+			aggregator := newAggregator(t, ci, tt.config)
+			err := aggregator.PrepareIndex()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range tt.baseDoc {
+				b, _ := bson.Marshal(d)
+				aggregator.Index(b)
+			}
+
+			doc, _ := bson.Marshal(tt.doc)
+			got := generators.AppendAggregations(nil, doc, []generators.Aggregator{aggregator})
+			want, _ := bson.Marshal(tt.expected)
+			if !bytes.Equal(want, got) {
+				t.Errorf("expected %v, got %v", bson.Raw(want), bson.Raw(got))
+			}
+		})
+	}
+}
+
+func TestAggregatorIndexWithOperator(t *testing.T) {
+
+	ci := generators.NewCollInfo(1, []int{3, 4}, defaultSeed, nil)
+	aggregator := newAggregator(t, ci, generators.Config{
+		Type:       generators.TypeCountAggregator,
+		Collection: "test",
+		Database:   "mgodatagen_test",
+		Query: bson.M{
+			"local": "$$_id",
+			"n":     bson.M{"$gt": 1},
+		},
+	})
+	if err := aggregator.PrepareIndex(); err == nil {
+		t.Error("expected an error for a query with an operator but got none")
+	}
+}
+
 func newAggregator(t *testing.T, ci *generators.CollInfo, config generators.Config) generators.Aggregator {
-	var content = map[string]generators.Config{
-		"key": config,
+	var content = generators.Content{
+		{Name: "key", Config: config},
 	}
 	aggregators, err := ci.NewAggregatorSlice(content)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/bsontype"
 )
 
@@ -13,10 +14,12 @@ type arrayGenerator struct {
 	base
 	minLength uint32
 	maxLength uint32
-	generator Generator
+	// probability that an element after the first 'minLength' ones is missing
+	missingPercentage uint32
+	generator         Generator
 }
 
-func newArrayGenerator(config *Config, base base, ci *CollInfo, buffer *DocBuffer) (h Generator, err error) {
+func newArrayGenerator(config *Config, base base, ci *CollInfo, buffer *DocBuffer, path string) (h Generator, err error) {
 
 	min, max := uint64(0), uint64(3)
 
@@ -49,11 +52,73 @@ func newArrayGenerator(config *Config, base base, ci *CollInfo, buffer *DocBuffe
 		return nil, errors.New("'arrayContent' can't be null")
 	}
 
-	g, err := ci.newGenerator(buffer, "", config.ArrayContent)
+	// missing elements are removed from the array, so the array can't get shorter
+	// than 'minLength'. 'nullPercentage' is ignored for the content of an array, as
+	// it was before 'missingPercentage' was added
+	content := *config.ArrayContent
+	missingPercentage := uint32(content.MissingPercentage) * 10
+	content.NullPercentage, content.MissingPercentage = 0, 0
+	if min > 0 && mayBeMissing(&content) {
+		return nil, errors.New("the content of an array with a 'minLength' can't be missing, so it can't have a 'condition', or be a 'switch' without 'default' or with generators that may be missing")
+	}
+
+	// the content of the array is generated up to 'maxLength' times per document
+	valuesPerDoc, variableValues := ci.valuesPerDoc, ci.variableValues
+	ci.valuesPerDoc = saturatingMul(valuesPerDoc, int(max))
+	ci.variableValues = variableValues || min != max || missingPercentage != 0
+	g, err := ci.newGenerator(buffer, "", path+".$", &content)
+	ci.valuesPerDoc, ci.variableValues = valuesPerDoc, variableValues
 	if err != nil {
 		return nil, err
 	}
 
+	removeKey(g)
+
+	return &arrayGenerator{
+		base:              base,
+		minLength:         uint32(min),
+		maxLength:         uint32(max),
+		missingPercentage: missingPercentage,
+		generator:         g,
+	}, nil
+}
+
+// mayBeMissing returns true if a value of config can be missing without a
+// 'missingPercentage', like a value with a 'condition'
+func mayBeMissing(config *Config) bool {
+	if config.Condition != nil {
+		return true
+	}
+	var choices []Config
+	switch config.Type {
+	case TypeOneOf:
+		choices = config.Generators
+	case TypeSwitch:
+		if config.Default == nil {
+			return true
+		}
+		choices = append(choices, *config.Default)
+		for _, c := range config.Cases {
+			choices = append(choices, c.Generator)
+		}
+	}
+	for i := range choices {
+		if choices[i].NullPercentage != 0 || choices[i].MissingPercentage != 0 || mayBeMissing(&choices[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeKey removes the type and the key from the values of generators
+// writing them, as the key of an element of an array is its index
+func removeKey(g Generator) {
+	if s, ok := g.(selector); ok {
+		for _, gen := range s.choices() {
+			removeKey(gen)
+		}
+		return
+	}
 	// if the generator is of type FromArrayGenerator,
 	// use the type of the first Element as global type
 	// for the generator
@@ -61,15 +126,12 @@ func newArrayGenerator(config *Config, base base, ci *CollInfo, buffer *DocBuffe
 	// the same type, otherwise bson object will be incorrect
 	switch g := g.(type) {
 	case *fromArrayGenerator:
-		// if array is generated with preGenerate(), this step is not needed
-		if !g.doNotTruncate {
-			g.bsonType = bsontype.Type(g.bsonArray[0][0])
-			// do not write first 3 bytes, ie
-			// bson type, byte("k"), byte(0) to avoid conflict with
-			// array index, because index is the key
-			for i := range g.bsonArray {
-				g.bsonArray[i] = g.bsonArray[i][3:]
-			}
+		g.bsonType = bsontype.Type(g.bsonArray[0][0])
+		// do not write first 3 bytes, ie
+		// bson type, byte("k"), byte(0) to avoid conflict with
+		// array index, because index is the key
+		for i := range g.bsonArray {
+			g.bsonArray[i] = g.bsonArray[i][3:]
 		}
 	case *constGenerator:
 		g.bsonType = bsontype.Type(g.bsonVal[0])
@@ -77,13 +139,6 @@ func newArrayGenerator(config *Config, base base, ci *CollInfo, buffer *DocBuffe
 		g.bsonVal = g.bsonVal[2+len(g.Key()):]
 	default:
 	}
-
-	return &arrayGenerator{
-		base:      base,
-		minLength: uint32(min),
-		maxLength: uint32(max),
-		generator: g,
-	}, nil
 }
 
 // precomputed index. Most of the array
@@ -115,21 +170,53 @@ func (g *arrayGenerator) EncodeValue() {
 	g.buffer.Reserve()
 	// array looks like this:
 	// size (byte(index) byte(0) value)... byte(0)
-	// where index is a string: ["1", "2", "3"...]
-	for i := 0; i < int(length); i++ {
-		g.buffer.WriteSingleByte(byte(g.generator.Type()))
+	// where index is a string: ["1", "2", "3"...]. Missing
+	// elements are skipped, so the array gets shorter
+	i := 0
+	for n := 0; n < int(length); n++ {
+		if n >= int(g.minLength) && g.missingPercentage != 0 && g.pcg32.Random()>>22 < g.missingPercentage {
+			continue
+		}
+		gen, exists := elementGenerator(g.generator)
+		if !exists {
+			continue
+		}
+		if gen.IsNull() {
+			g.buffer.WriteSingleByte(byte(bson.TypeNull))
+		} else {
+			g.buffer.WriteSingleByte(byte(gen.Type()))
+		}
 		if i < 10 {
 			g.buffer.WriteSingleByte(indexesBytes[i])
 		} else {
 			g.buffer.WriteString(strconv.Itoa(i))
 		}
 		g.buffer.WriteSingleByte(byte(0))
-		g.generator.EncodeValue()
+		if !gen.IsNull() {
+			gen.EncodeValue()
+		}
+		i++
 	}
 	g.buffer.WriteSingleByte(byte(0))
 	g.buffer.WriteAt(current, int32Bytes(int32(g.buffer.Len()-current)))
 }
 
+// elementGenerator returns the generator to use for the next element of
+// the array, and false if the element is missing. Elements of a oneOf or
+// switch generator can have a different type
+func elementGenerator(g Generator) (Generator, bool) {
+	for {
+		if !g.Exists() {
+			return nil, false
+		}
+		s, ok := g.(selector)
+		if !ok || g.IsNull() {
+			return g, true
+		}
+		g = s.selected()
+	}
+}
+
 func (g *arrayGenerator) EncodeValueAsString() {
 	length := g.minLength
 	if g.minLength != g.maxLength {
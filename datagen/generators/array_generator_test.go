@@ -9,16 +9,16 @@ import (
 
 func TestBigArray(t *testing.T) {
 
-	ci := generators.NewCollInfo(-1, []int{3, 6, 4}, defaultSeed, nil, nil)
-	docGenerator, err := ci.NewDocumentGenerator(map[string]generators.Config{
-		"key": {
+	ci := generators.NewCollInfo(-1, []int{3, 6, 4}, defaultSeed, nil)
+	docGenerator, err := ci.NewDocumentGenerator(generators.Content{
+		{Name: "key", Config: generators.Config{
 			Type:      generators.TypeArray,
 			MinLength: "15",
 			MaxLength: "18",
 			ArrayContent: &generators.Config{
 				Type: generators.TypeBoolean,
 			},
-		},
+		}},
 	})
 	if err != nil {
 		t.Error(err)
@@ -40,15 +40,15 @@ func TestBigArray(t *testing.T) {
 
 func TestOldSizeAttributeCompat(t *testing.T) {
 
-	ci := generators.NewCollInfo(-1, []int{3, 6, 4}, defaultSeed, nil, nil)
-	docGenerator, err := ci.NewDocumentGenerator(map[string]generators.Config{
-		"key": {
+	ci := generators.NewCollInfo(-1, []int{3, 6, 4}, defaultSeed, nil)
+	docGenerator, err := ci.NewDocumentGenerator(generators.Content{
+		{Name: "key", Config: generators.Config{
 			Type: generators.TypeArray,
 			Size: 2,
 			ArrayContent: &generators.Config{
 				Type: generators.TypeUUID,
 			},
-		},
+		}},
 	})
 	if err != nil {
 		t.Error(err)
@@ -2,6 +2,7 @@ package generators
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -10,10 +11,14 @@ import (
 // Generator for creating auto-incremented int32
 type autoIncrementIntGenerator struct {
 	base
+	start   int32
 	counter int32
+	// number of values generated per document, each chunk of documents
+	// starts at start + chunk * ChunkSize * valuesPerDoc
+	valuesPerDoc int
 }
 
-func newAutoIncrementIntGenerator(config *Config, base base) (g Generator, err error) {
+func newAutoIncrementIntGenerator(config *Config, base base, count, valuesPerDoc int) (g Generator, err error) {
 	base.bsonType = bson.TypeInt32
 
 	start := int64(0)
@@ -23,10 +28,15 @@ func newAutoIncrementIntGenerator(config *Config, base base) (g Generator, err e
 			return nil, fmt.Errorf("can't parse number '%s' as an int:\n%w", config.Start, err)
 		}
 	}
+	if nb := saturatingMul(count, valuesPerDoc); nb > 0 && start > math.MaxInt32-int64(nb-1) {
+		return nil, fmt.Errorf("%d values starting at %d don't fit in an int, use 'autoType' 'long'", nb, start)
+	}
 
 	return &autoIncrementIntGenerator{
-		base:    base,
-		counter: int32(start),
+		base:         base,
+		start:        int32(start),
+		counter:      int32(start),
+		valuesPerDoc: valuesPerDoc,
 	}, nil
 }
 
@@ -40,3 +50,9 @@ func (g *autoIncrementIntGenerator) EncodeValueAsString() {
 	g.buffer.WriteString(val)
 	g.counter++
 }
+
+// seek sets the counter to the value of the first value of document n. The range
+// of the values is checked when the generator is created, so this can't overflow
+func (g *autoIncrementIntGenerator) seek(n int) {
+	g.counter = g.start + int32(n*g.valuesPerDoc)
+}
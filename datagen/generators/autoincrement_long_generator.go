@@ -2,6 +2,7 @@ package generators
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -10,10 +11,14 @@ import (
 // Generator for creating auto-incremented int64
 type autoIncrementLongGenerator struct {
 	base
+	start   int64
 	counter int64
+	// number of values generated per document, each chunk of documents
+	// starts at start + chunk * ChunkSize * valuesPerDoc
+	valuesPerDoc int
 }
 
-func newAutoIncrementLongGenerator(config *Config, base base) (g Generator, err error) {
+func newAutoIncrementLongGenerator(config *Config, base base, count, valuesPerDoc int) (g Generator, err error) {
 	base.bsonType = bson.TypeInt64
 
 	start := int64(0)
@@ -23,9 +28,14 @@ func newAutoIncrementLongGenerator(config *Config, base base) (g Generator, err
 			return nil, fmt.Errorf("can't parse number '%s' as a long:\n%w", config.Start, err)
 		}
 	}
+	if nb := saturatingMul(count, valuesPerDoc); nb > 0 && (nb == math.MaxInt || start > math.MaxInt64-int64(nb-1)) {
+		return nil, fmt.Errorf("%d values starting at %d don't fit in a long", nb, start)
+	}
 	return &autoIncrementLongGenerator{
-		base:    base,
-		counter: start,
+		base:         base,
+		start:        start,
+		counter:      start,
+		valuesPerDoc: valuesPerDoc,
 	}, nil
 }
 
@@ -39,3 +49,9 @@ func (g *autoIncrementLongGenerator) EncodeValueAsString() {
 	g.buffer.WriteString(val)
 	g.counter++
 }
+
+// seek sets the counter to the value of the first value of document n. The range
+// of the values is checked when the generator is created, so this can't overflow
+func (g *autoIncrementLongGenerator) seek(n int) {
+	g.counter = g.start + int64(n*g.valuesPerDoc)
+}
@@ -3,7 +3,10 @@ package generators
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
+
+	"github.com/MichaelTJones/pcg"
 )
 
 // Generator for creating random binary data
@@ -13,7 +16,7 @@ type binaryDataGenerator struct {
 	maxLength uint32
 }
 
-func newBinaryGenerator(config *Config, base base) (g Generator, err error) {
+func newBinaryGenerator(config *Config, base base, pcg64 *pcg.PCG64, count int) (g Generator, err error) {
 
 	min, max := uint64(0), uint64(10)
 
@@ -35,11 +38,15 @@ func newBinaryGenerator(config *Config, base base) (g Generator, err error) {
 		return nil, errors.New("make sure that 'minLength' < 'maxLength'")
 	}
 
-	return &binaryDataGenerator{
+	binary := &binaryDataGenerator{
 		base:      base,
 		minLength: uint32(min),
 		maxLength: uint32(max),
-	}, nil
+	}
+	if config.Unique {
+		return newUniqueBinaryGenerator(binary, pcg64, count)
+	}
+	return binary, nil
 }
 
 // legacy type binary instead of 0x05
@@ -63,3 +70,49 @@ func (g *binaryDataGenerator) EncodeValue() {
 }
 
 func (g *binaryDataGenerator) EncodeValueAsString() {}
+
+// Generator for creating unique binary data. The first bytes of each
+// value, up to 8, hold a unique number, and the remaining bytes are random
+type uniqueBinaryGenerator struct {
+	*binaryDataGenerator
+	*uniqueSequence
+	prefixLength int
+}
+
+func newUniqueBinaryGenerator(g *binaryDataGenerator, pcg64 *pcg.PCG64, count int) (Generator, error) {
+	prefixLength := int(g.minLength)
+	if prefixLength > 8 {
+		prefixLength = 8
+	}
+	size := uint64(1) << (8 * prefixLength)
+	if prefixLength == 8 {
+		size = math.MaxUint64
+	}
+	seq, err := newUniqueSequence(size, count, 1, pcg64)
+	if err != nil {
+		return nil, err
+	}
+	return &uniqueBinaryGenerator{
+		binaryDataGenerator: g,
+		uniqueSequence:      seq,
+		prefixLength:        prefixLength,
+	}, nil
+}
+
+func (g *uniqueBinaryGenerator) EncodeValue() {
+	length := g.minLength
+	if g.minLength != g.maxLength {
+		length = g.pcg32.Bounded(g.maxLength-g.minLength+1) + g.minLength
+	}
+	g.buffer.Write(uint32Bytes(length))
+	g.buffer.WriteSingleByte(genericBinaryType)
+
+	// big endian, so values are sorted like the numbers they hold
+	v := g.next()
+	for i := g.prefixLength - 1; i >= 0; i-- {
+		g.buffer.WriteSingleByte(byte(v >> (8 * i)))
+	}
+	for i := g.prefixLength; i < int(length); i++ {
+		g.buffer.WriteSingleByte(byte(g.pcg32.Random()))
+	}
+}
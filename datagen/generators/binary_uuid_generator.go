@@ -1,30 +1,31 @@
 package generators
 
 import (
-	"github.com/google/uuid"
+	"github.com/MichaelTJones/pcg"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
 type binaryUUIDGenerator struct {
 	base
+	pcg64 *pcg.PCG64
 }
 
-func newBinaryUUIDGenerator(base base) (Generator, error) {
+func newBinaryUUIDGenerator(base base, pcg64 *pcg.PCG64) (Generator, error) {
 	base.bsonType = bson.TypeBinary
-	return &binaryUUIDGenerator{base: base}, nil
+	return &binaryUUIDGenerator{base: base, pcg64: pcg64}, nil
 }
 
 // see https://bsonspec.org/spec.html
 const binaryUUIDSubtype = 0x04
 
 func (g *binaryUUIDGenerator) EncodeValue() {
-	b, _ := uuid.New().MarshalBinary()
+	b := randomUUID(g.pcg64)
 
 	g.buffer.Write(int32Bytes(int32(len(b))))
 	g.buffer.WriteSingleByte(binaryUUIDSubtype)
-	g.buffer.Write(b)
+	g.buffer.Write(b[:])
 }
 
 func (g *binaryUUIDGenerator) EncodeValueAsString() {
-	g.buffer.WriteString(uuid.NewString())
+	g.buffer.WriteString(randomUUID(g.pcg64).String())
 }
@@ -1,13 +1,13 @@
 package generators
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"strings"
 	"time"
 
-	"github.com/MichaelTJones/pcg"
 	"github.com/brianvoe/gofakeit/v6"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/bsontype"
@@ -21,41 +21,92 @@ type CollInfo struct {
 	Version []int
 	// seed for random generation
 	Seed uint64
-	// map holding references values when using a reference generator
-	mapRef map[int][][]byte
-	// map holding references types when using a reference generator
-	mapRefType map[int]bsontype.Type
-	pcg32      *pcg.PCG32
-	pcg64      *pcg.PCG64
+	// Namespace of the collection, like 'db.collection'. ObjectIds depend
+	// on it, so two collections generated with the same seed don't get
+	// the same ObjectIds
+	Namespace string
+	// values of the references, shared by the CollInfo of all the collections
+	references References
+	// streams and seekers of the generators created for the
+	// DocumentGenerator being built
+	streams []*stream
+	seekers []seeker
+	// maximum number of values generated per document by the generator being
+	// created, ie the product of the 'maxLength' of the arrays holding it
+	valuesPerDoc int
+	// true if the generator being created isn't used the same number of times
+	// in every document, like a field with a 'missingPercentage', or the content
+	// of an array of variable length
+	variableValues bool
+	// number of children per parent of the references using the values of another
+	// field, by reference id. They are shared by all the DocumentGenerators created
+	// from this CollInfo
+	cardinalities map[int]*cardinality
+	// shape of the trees built by the tree fields, by reference id
+	trees map[int]*tree
+	// edges of the graphs whose nodes are the values of a reference, by reference id
+	edges map[int]*edges
+	// top level fields generated before the field being created
+	generatedBefore []string
+	// paths of the generators already created for the top level
+	// field being created, like 'field.nested', without pathPrefix
+	created    map[string]bool
+	pathPrefix string
 }
 
 // NewCollInfo returns a new CollInfo.
-// mapRef is a map holding bson-encoded values for references fields.
-// mapRefType is a map holding bson type for references fields.
-func NewCollInfo(count int, version []int, seed uint64, mapRef map[int][][]byte, mapRefType map[int]bsontype.Type) *CollInfo {
+// references holds the values of the references, and has to be shared by the
+// CollInfo of all the collections. If nil, a new one is created
+func NewCollInfo(count int, version []int, seed uint64, references References) *CollInfo {
 	if count <= 0 {
 		count = 1
 	}
+	if references == nil {
+		references = make(References)
+	}
 	return &CollInfo{
-		Count:      count,
-		Version:    version,
-		Seed:       seed,
-		mapRef:     mapRef,
-		mapRefType: mapRefType,
-		pcg32:      pcg.NewPCG32().Seed(seed, seed),
-		pcg64:      pcg.NewPCG64().Seed(seed, seed, seed, seed),
+		Count:         count,
+		Version:       version,
+		Seed:          seed,
+		references:    references,
+		valuesPerDoc:  1,
+		cardinalities: make(map[int]*cardinality),
+		trees:         make(map[int]*tree),
+		edges:         make(map[int]*edges),
 	}
 }
 
+// newStream returns the random streams used by the generator of the field at path.
+// They only depend on the seed and on the path of the field, so adding or removing
+// a field doesn't change the values generated for the other fields
+func (ci *CollInfo) newStream(path string) *stream {
+	s := newStream(ci.Seed, pathSequence(path))
+	ci.streams = append(ci.streams, s)
+	return s
+}
+
+// pathSequence returns the sequence of the random streams of path
+func pathSequence(path string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	return h.Sum64()
+}
+
 // Config struct containing all possible options
 type Config struct {
 	// Type of object to generate, required
 	Type string `json:"type"`
-	// Percentage of documents that won't contains this field, optional
+	// Percentage of documents that won't contains this field, optional. Same
+	// as 'MissingPercentage', kept for backward compatibility
 	NullPercentage int `json:"nullPercentage"`
+	// Percentage of documents that won't contains this field, optional
+	MissingPercentage int `json:"missingPercentage"`
+	// Percentage of documents where this field is present with a null value, optional
+	NullValuePercentage int `json:"nullValuePercentage"`
 	// Maximum number of distinct value for this field, optional
 	MaxDistinctValue int `json:"maxDistinctValue"`
-	// For `string` type only. If set to 'true', string will be unique
+	// For `string`, `int`, `long`, `double`, `date`, `binary` and `objectId` type only.
+	// If set to 'true', the values of this field will be unique
 	Unique bool `json:"unique"`
 	// For `string` and `binary` type only. Specify the Min length of the object to generate
 	MinLength json.Number `json:"minLength"`
@@ -65,17 +116,32 @@ type Config struct {
 	Min json.Number `json:"min"`
 	// For `int`, `long` or `double` only. Higher bound for number to generate
 	Max json.Number `json:"max"`
+	// For `int`, `long`, `double`, `date` and `reference` only. Distribution of the values, or of
+	// the number of children per parent for a reference, must be one of
+	// [ 'uniform', 'normal', 'logNormal', 'exponential', 'zipf', 'poisson' ]. Default is 'uniform'
+	Distribution string `json:"distribution"`
+	// For `normal` and `logNormal` distributions only. Mean of the distribution
+	Mean json.Number `json:"mean"`
+	// For `normal` and `logNormal` distributions only. Standard deviation of the distribution
+	StdDev json.Number `json:"stdDev"`
+	// For `exponential` and `poisson` distributions only. Rate of the distribution
+	Lambda json.Number `json:"lambda"`
+	// For `zipf` distribution only. Exponent of the distribution, has to be > 1
+	Exponent json.Number `json:"exponent"`
 	// For `array` only. Config to fill the array. Need to
 	// pass a pointer here to avoid 'invalid recursive type' error
 	ArrayContent *Config `json:"arrayContent"`
 	// For `object` only. List of GeneratorJSON to generate the content
 	// of the object
-	ObjectContent map[string]Config `json:"objectContent"`
+	ObjectContent Content `json:"objectContent"`
 	// For `enum` ( formerly `fromArray`) only. If specified, the generator
 	// pick one of the item of the array
 	Values []any
 	// For `fromArray` only. If set to true, items are picked from the array in random order
 	RandomOrder bool `json:"randomOrder"`
+	// For `enum` and `oneOf` only. Relative weight of each item of 'Values' or of
+	// 'Generators'. Items are picked with a probability proportional to their weight
+	Weights []float64 `json:"weights"`
 	// For `date` only. Lower bound for the date to generate
 	StartDate time.Time `json:"startDate"`
 	// For `date` only. Higher bound for the date to generate
@@ -90,13 +156,69 @@ type Config struct {
 	Method string `json:"method"`
 	// for `stringFromParts` type only. Generators used to create the string
 	Parts []Config `json:"parts"`
+	// For `expression` type only. Formula computing the value from the fields
+	// generated before in the document
+	Expression string `json:"expression"`
+	// For `expression` and `template` types only. Date returned by now(), optional. Default
+	// is 2024-01-01T00:00:00Z
+	ReferenceDate time.Time `json:"referenceDate"`
+	// For `template` type only. String whose placeholders, like '{{firstName}}',
+	// are replaced by the value of an expression
+	Template string `json:"template"`
+	// For `template` type only. Generators available in the placeholders of the
+	// template, the generator 'name' being used with '{{$name}}'
+	Variables map[string]Config `json:"variables"`
+	// For `oneOf` type only. Generators used to create the value, one of them is
+	// picked for each value, with a probability given by 'Weights'
+	Generators []Config `json:"generators"`
+	// For `tree` type only. Value of the node to create, must be one of [ 'parent',
+	// 'ancestors', 'children', 'path', 'depth', 'left', 'right' ]
+	Node string `json:"node"`
+	// For `tree` type only. Number of children of each node
+	FanOut int `json:"fanOut"`
+	// For `tree` type only. Maximum depth of the trees, optional. If all the documents
+	// don't fit in a single tree, they are split into several trees
+	Depth int `json:"depth"`
+	// For `tree` type only, with 'node' 'path'. String separating the ids of the
+	// ancestors, optional. Default is ','
+	Separator string `json:"separator"`
+	// Condition on the fields generated before in the document, like a MongoDB
+	// query. If set, the field only exists in documents matching the condition
+	Condition bson.M `json:"condition"`
+	// For `switch` type only. The value is created by the generator of the first
+	// case whose condition matches the document
+	Cases []Case `json:"cases"`
+	// For `switch` type only. Generator used when no condition matches. If not
+	// set, the field is missing when no condition matches
+	Default *Config `json:"default"`
 	// For `reference` type only. Used to retrieve the array storing the value
 	// for this field
 	ID int `json:"id"`
 	// For `reference` type only. generator for the field
 	RefContent *Config `json:"refContent"`
+	// For `reference` type only, without 'refContent'. If set to true, the values of the
+	// reference are used in turn instead of being picked at random
+	InOrder bool `json:"inOrder"`
+	// For `reference` type only, without 'refContent'. Minimum number of documents
+	// of the collection referencing each value of the reference
+	MinChildren int `json:"minChildren"`
+	// For `reference` type only, without 'refContent'. Maximum number of documents
+	// of the collection referencing each value of the reference
+	MaxChildren int `json:"maxChildren"`
+	// For `reference` type only, without 'refContent'. Paths of the fields of the
+	// referenced document to copy. If set, the value is an embedded document
+	// holding these fields instead of the value of the reference
+	Fields []string `json:"fields"`
+	// For `reference` type only, without 'refContent'. End of the edge of a graph whose
+	// nodes are the values of the reference, must be one of [ 'from', 'to' ]
+	Edge string `json:"edge"`
+	// For `reference` type only, with 'edge'. Fraction of the edges whose reverse
+	// edge is also in the collection, between 0 and 1. Default is 0
+	Reciprocity json.Number `json:"reciprocity"`
 	// For `uuid` type only. Type of the field, must be one of [ 'string', 'binary' ]
 	UUIDFormat string `json:"format"`
+	// For `regex` type only. Flags of the regular expression, like 'im'
+	Flags string `json:"flags"`
 	// For `countAggregator`, `boundAggregator` and `valueAggregator` only
 	Collection string `json:"collection"`
 	// For `countAggregator`, `boundAggregator` and `valueAggregator` only
@@ -149,6 +271,19 @@ const (
 	TypeUUID            = "uuid"
 	TypeFaker           = "faker"
 	TypeStringFromParts = "stringFromParts"
+	TypeTimestamp       = "timestamp"
+	TypeRegex           = "regex"
+	TypeJavascript      = "javascript"
+	TypeSymbol          = "symbol"
+	TypeDBPointer       = "dbPointer"
+	TypeMinKey          = "minKey"
+	TypeMaxKey          = "maxKey"
+	TypeNull            = "null"
+	TypeOneOf           = "oneOf"
+	TypeSwitch          = "switch"
+	TypeExpression      = "expression"
+	TypeTemplate        = "template"
+	TypeTree            = "tree"
 
 	// deprecated. Use 'TypeCoordinates' instead
 	TypePosition = "position"
@@ -287,13 +422,26 @@ var mapTypes = map[string]bsontype.Type{
 	TypeUUID:            bson.TypeNull, // type String or Binary
 	TypeFaker:           bson.TypeString,
 	TypeStringFromParts: bson.TypeString,
+	TypeTimestamp:       bson.TypeTimestamp,
+	TypeRegex:           bson.TypeRegex,
+	TypeJavascript:      bson.TypeJavaScript,
+	TypeSymbol:          bson.TypeSymbol,
+	TypeDBPointer:       bson.TypeDBPointer,
+	TypeMinKey:          bson.TypeMinKey,
+	TypeMaxKey:          bson.TypeMaxKey,
+	TypeNull:            bson.TypeNull, // written like a constant null value
+	TypeOneOf:           bson.TypeNull, // can be of any bson type
+	TypeSwitch:          bson.TypeNull, // can be of any bson type
+	TypeExpression:      bson.TypeNull, // depends on the result of the expression
+	TypeTemplate:        bson.TypeString,
+	TypeTree:            bson.TypeNull,
 
 	TypeCountAggregator: bson.TypeNull,
 	TypeValueAggregator: bson.TypeNull,
 	TypeBoundAggregator: bson.TypeNull,
 }
 
-var fakerMethods = map[string]func() string{
+var fakerMethods = map[string]func(*gofakeit.Faker) string{
 
 	// Old manrevu/faker values are kept, but non documented
 	// in order to avoid breaking previous working config
@@ -317,168 +465,241 @@ var fakerMethods = map[string]func() string{
 	// "StreetSuffix"
 	// "URL"
 
-	"CellPhoneNumber":    gofakeit.Phone,
-	"CityPrefix":         gofakeit.City,
-	"CitySuffix":         gofakeit.City,
-	"CompanyBs":          gofakeit.BS,
-	"CompanyCatchPhrase": gofakeit.HackerPhrase,
-	"CompanyName":        gofakeit.Company,
-	"DomainWord":         gofakeit.DomainName,
-	"FreeEmail":          gofakeit.Email,
-	"PhoneNumber":        gofakeit.Phone,
-	"PostCode":           gofakeit.Zip,
-	"SafeEmail":          gofakeit.Email,
-	"SecondaryAddress":   gofakeit.StreetName,
-	"StateAbbr":          gofakeit.StateAbr,
-	"StreetAddress":      gofakeit.StreetName,
-	"UserName":           gofakeit.Username,
+	"CellPhoneNumber":    (*gofakeit.Faker).Phone,
+	"CityPrefix":         (*gofakeit.Faker).City,
+	"CitySuffix":         (*gofakeit.Faker).City,
+	"CompanyBs":          (*gofakeit.Faker).BS,
+	"CompanyCatchPhrase": (*gofakeit.Faker).HackerPhrase,
+	"CompanyName":        (*gofakeit.Faker).Company,
+	"DomainWord":         (*gofakeit.Faker).DomainName,
+	"FreeEmail":          (*gofakeit.Faker).Email,
+	"PhoneNumber":        (*gofakeit.Faker).Phone,
+	"PostCode":           (*gofakeit.Faker).Zip,
+	"SafeEmail":          (*gofakeit.Faker).Email,
+	"SecondaryAddress":   (*gofakeit.Faker).StreetName,
+	"StateAbbr":          (*gofakeit.Faker).StateAbr,
+	"StreetAddress":      (*gofakeit.Faker).StreetName,
+	"UserName":           (*gofakeit.Faker).Username,
 
 	// old gofakeit method, kept for backawrd compatibility
 	// but undocuemented
 
-	"MimeType":             gofakeit.FileMimeType,
-	"Extension":            gofakeit.FileExtension,
-	"FuelType":             gofakeit.CarFuelType,
-	"HackerIngverb":        gofakeit.HackerVerb,
-	"MehtodStreet":         gofakeit.Street,
-	"TransmissionGearType": gofakeit.CarTransmissionType,
-	"VehicleType":          gofakeit.CarType,
+	"MimeType":             (*gofakeit.Faker).FileMimeType,
+	"Extension":            (*gofakeit.Faker).FileExtension,
+	"FuelType":             (*gofakeit.Faker).CarFuelType,
+	"HackerIngverb":        (*gofakeit.Faker).HackerVerb,
+	"MehtodStreet":         (*gofakeit.Faker).Street,
+	"TransmissionGearType": (*gofakeit.Faker).CarTransmissionType,
+	"VehicleType":          (*gofakeit.Faker).CarType,
 
 	// current documented method
 
-	MethodAnimal:                  gofakeit.Animal,
-	MethodAnimalType:              gofakeit.AnimalType,
-	MethodBS:                      gofakeit.BS,
-	MethodBeerAlcohol:             gofakeit.BeerAlcohol,
-	MethodBeerBlg:                 gofakeit.BeerBlg,
-	MethodBeerHop:                 gofakeit.BeerHop,
-	MethodBeerIbu:                 gofakeit.BeerIbu,
-	MethodBeerMalt:                gofakeit.BeerMalt,
-	MethodBeerName:                gofakeit.BeerName,
-	MethodBeerStyle:               gofakeit.BeerStyle,
-	MethodBeerYeast:               gofakeit.BeerYeast,
-	MethodBuzzWord:                gofakeit.BuzzWord,
-	MethodCarMaker:                gofakeit.CarMaker,
-	MethodCarModel:                gofakeit.CarModel,
-	MethodCat:                     gofakeit.Cat,
-	MethodChromeUserAgent:         gofakeit.ChromeUserAgent,
-	MethodColor:                   gofakeit.Color,
-	MethodCity:                    gofakeit.City,
-	MethodCompany:                 gofakeit.Company,
-	MethodCompanySuffix:           gofakeit.CompanySuffix,
-	MethodCountry:                 gofakeit.Country,
-	MethodCountryAbr:              gofakeit.CountryAbr,
-	MethodCreditCardCvv:           gofakeit.CreditCardCvv,
-	MethodCreditCardExp:           gofakeit.CreditCardExp,
-	MethodCreditCardType:          gofakeit.CreditCardType,
-	MethodCurrencyLong:            gofakeit.CurrencyLong,
-	MethodCurrencyShort:           gofakeit.CurrencyShort,
-	MethodDog:                     gofakeit.Dog,
-	MethodDomainName:              gofakeit.DomainName,
-	MethodDomainSuffix:            gofakeit.DomainSuffix,
-	MethodEmail:                   gofakeit.Email,
-	MethodEmoji:                   gofakeit.Emoji,
-	MethodEmojiAlias:              gofakeit.EmojiAlias,
-	MethodEmojiCategory:           gofakeit.EmojiCategory,
-	MethodEmojiDescription:        gofakeit.EmojiDescription,
-	MethodEmojiTag:                gofakeit.EmojiTag,
-	MethodFileExtension:           gofakeit.FileExtension,
-	MethodFarmAnimal:              gofakeit.FarmAnimal,
-	MethodFirefoxUserAgent:        gofakeit.FirefoxUserAgent,
-	MethodFirstName:               gofakeit.FirstName,
-	MethodCarFuelType:             gofakeit.CarFuelType,
-	MethodGender:                  gofakeit.Gender,
-	MethodHTTPMethod:              gofakeit.HTTPMethod,
-	MethodHackerAbbreviation:      gofakeit.HackerAbbreviation,
-	MethodHackerAdjective:         gofakeit.HackerAdjective,
-	MethodHackeringVerb:           gofakeit.HackeringVerb,
-	MethodHackerNoun:              gofakeit.HackerNoun,
-	MethodHackerPhrase:            gofakeit.HackerPhrase,
-	MethodHackerVerb:              gofakeit.HackerVerb,
-	MethodHexColor:                gofakeit.HexColor,
-	MethodHipsterWord:             gofakeit.HipsterWord,
-	MethodIPv4Address:             gofakeit.IPv4Address,
-	MethodIPv6Address:             gofakeit.IPv6Address,
-	MethodJobDescriptor:           gofakeit.JobDescriptor,
-	MethodJobLevel:                gofakeit.JobLevel,
-	MethodJobTitle:                gofakeit.JobTitle,
-	MethodLanguage:                gofakeit.Language,
-	MethodLanguageAbbreviation:    gofakeit.LanguageAbbreviation,
-	MethodLastName:                gofakeit.LastName,
-	MethodLetter:                  gofakeit.Letter,
-	MethodMacAddress:              gofakeit.MacAddress,
-	MethodFileMimeType:            gofakeit.FileMimeType,
-	MethodMonth:                   gofakeit.Month,
-	MethodName:                    gofakeit.Name,
-	MethodNamePrefix:              gofakeit.NamePrefix,
-	MethodNameSuffix:              gofakeit.NameSuffix,
-	MethodOperaUserAgent:          gofakeit.OperaUserAgent,
-	MethodPetName:                 gofakeit.PetName,
-	MethodPhone:                   gofakeit.Phone,
-	MethodPhoneFormatted:          gofakeit.PhoneFormatted,
-	MethodProgrammingLanguage:     gofakeit.ProgrammingLanguage,
-	MethodProgrammingLanguageBest: gofakeit.ProgrammingLanguageBest,
-	MethodQuestion:                gofakeit.Question,
-	MethodQuote:                   gofakeit.Quote,
-	MethodSSN:                     gofakeit.SSN,
-	MethodSafariUserAgent:         gofakeit.SafariUserAgent,
-	MethodSafeColor:               gofakeit.SafeColor,
-	MethodState:                   gofakeit.State,
-	MethodStateAbr:                gofakeit.StateAbr,
-	MethodStreet:                  gofakeit.Street,
-	MethodStreetName:              gofakeit.StreetName,
-	MethodStreetNumber:            gofakeit.StreetNumber,
-	MethodStreetPrefix:            gofakeit.StreetPrefix,
-	MethodStreetSuffix:            gofakeit.StreetSuffix,
-	MethodTimeZone:                gofakeit.TimeZone,
-	MethodTimeZoneAbv:             gofakeit.TimeZoneAbv,
-	MethodTimeZoneFull:            gofakeit.TimeZoneFull,
-	MethodCarTransmissionType:     gofakeit.CarTransmissionType,
-	MethodURL:                     gofakeit.URL,
-	MethodUserAgent:               gofakeit.UserAgent,
-	MethodUsername:                gofakeit.Username,
-	MethodCarType:                 gofakeit.CarType,
-	MethodWeekDay:                 gofakeit.WeekDay,
-	MethodWord:                    gofakeit.Word,
-	MethodZip:                     gofakeit.Zip,
+	MethodAnimal:                  (*gofakeit.Faker).Animal,
+	MethodAnimalType:              (*gofakeit.Faker).AnimalType,
+	MethodBS:                      (*gofakeit.Faker).BS,
+	MethodBeerAlcohol:             (*gofakeit.Faker).BeerAlcohol,
+	MethodBeerBlg:                 (*gofakeit.Faker).BeerBlg,
+	MethodBeerHop:                 (*gofakeit.Faker).BeerHop,
+	MethodBeerIbu:                 (*gofakeit.Faker).BeerIbu,
+	MethodBeerMalt:                (*gofakeit.Faker).BeerMalt,
+	MethodBeerName:                (*gofakeit.Faker).BeerName,
+	MethodBeerStyle:               (*gofakeit.Faker).BeerStyle,
+	MethodBeerYeast:               (*gofakeit.Faker).BeerYeast,
+	MethodBuzzWord:                (*gofakeit.Faker).BuzzWord,
+	MethodCarMaker:                (*gofakeit.Faker).CarMaker,
+	MethodCarModel:                (*gofakeit.Faker).CarModel,
+	MethodCat:                     (*gofakeit.Faker).Cat,
+	MethodChromeUserAgent:         (*gofakeit.Faker).ChromeUserAgent,
+	MethodColor:                   (*gofakeit.Faker).Color,
+	MethodCity:                    (*gofakeit.Faker).City,
+	MethodCompany:                 (*gofakeit.Faker).Company,
+	MethodCompanySuffix:           (*gofakeit.Faker).CompanySuffix,
+	MethodCountry:                 (*gofakeit.Faker).Country,
+	MethodCountryAbr:              (*gofakeit.Faker).CountryAbr,
+	MethodCreditCardCvv:           (*gofakeit.Faker).CreditCardCvv,
+	MethodCreditCardExp:           (*gofakeit.Faker).CreditCardExp,
+	MethodCreditCardType:          (*gofakeit.Faker).CreditCardType,
+	MethodCurrencyLong:            (*gofakeit.Faker).CurrencyLong,
+	MethodCurrencyShort:           (*gofakeit.Faker).CurrencyShort,
+	MethodDog:                     (*gofakeit.Faker).Dog,
+	MethodDomainName:              (*gofakeit.Faker).DomainName,
+	MethodDomainSuffix:            (*gofakeit.Faker).DomainSuffix,
+	MethodEmail:                   (*gofakeit.Faker).Email,
+	MethodEmoji:                   (*gofakeit.Faker).Emoji,
+	MethodEmojiAlias:              (*gofakeit.Faker).EmojiAlias,
+	MethodEmojiCategory:           (*gofakeit.Faker).EmojiCategory,
+	MethodEmojiDescription:        (*gofakeit.Faker).EmojiDescription,
+	MethodEmojiTag:                (*gofakeit.Faker).EmojiTag,
+	MethodFileExtension:           (*gofakeit.Faker).FileExtension,
+	MethodFarmAnimal:              (*gofakeit.Faker).FarmAnimal,
+	MethodFirefoxUserAgent:        (*gofakeit.Faker).FirefoxUserAgent,
+	MethodFirstName:               (*gofakeit.Faker).FirstName,
+	MethodCarFuelType:             (*gofakeit.Faker).CarFuelType,
+	MethodGender:                  (*gofakeit.Faker).Gender,
+	MethodHTTPMethod:              (*gofakeit.Faker).HTTPMethod,
+	MethodHackerAbbreviation:      (*gofakeit.Faker).HackerAbbreviation,
+	MethodHackerAdjective:         (*gofakeit.Faker).HackerAdjective,
+	MethodHackeringVerb:           (*gofakeit.Faker).HackeringVerb,
+	MethodHackerNoun:              (*gofakeit.Faker).HackerNoun,
+	MethodHackerPhrase:            (*gofakeit.Faker).HackerPhrase,
+	MethodHackerVerb:              (*gofakeit.Faker).HackerVerb,
+	MethodHexColor:                (*gofakeit.Faker).HexColor,
+	MethodHipsterWord:             (*gofakeit.Faker).HipsterWord,
+	MethodIPv4Address:             (*gofakeit.Faker).IPv4Address,
+	MethodIPv6Address:             (*gofakeit.Faker).IPv6Address,
+	MethodJobDescriptor:           (*gofakeit.Faker).JobDescriptor,
+	MethodJobLevel:                (*gofakeit.Faker).JobLevel,
+	MethodJobTitle:                (*gofakeit.Faker).JobTitle,
+	MethodLanguage:                (*gofakeit.Faker).Language,
+	MethodLanguageAbbreviation:    (*gofakeit.Faker).LanguageAbbreviation,
+	MethodLastName:                (*gofakeit.Faker).LastName,
+	MethodLetter:                  (*gofakeit.Faker).Letter,
+	MethodMacAddress:              (*gofakeit.Faker).MacAddress,
+	MethodFileMimeType:            (*gofakeit.Faker).FileMimeType,
+	MethodMonth:                   (*gofakeit.Faker).Month,
+	MethodName:                    (*gofakeit.Faker).Name,
+	MethodNamePrefix:              (*gofakeit.Faker).NamePrefix,
+	MethodNameSuffix:              (*gofakeit.Faker).NameSuffix,
+	MethodOperaUserAgent:          (*gofakeit.Faker).OperaUserAgent,
+	MethodPetName:                 (*gofakeit.Faker).PetName,
+	MethodPhone:                   (*gofakeit.Faker).Phone,
+	MethodPhoneFormatted:          (*gofakeit.Faker).PhoneFormatted,
+	MethodProgrammingLanguage:     (*gofakeit.Faker).ProgrammingLanguage,
+	MethodProgrammingLanguageBest: (*gofakeit.Faker).ProgrammingLanguageBest,
+	MethodQuestion:                (*gofakeit.Faker).Question,
+	MethodQuote:                   (*gofakeit.Faker).Quote,
+	MethodSSN:                     (*gofakeit.Faker).SSN,
+	MethodSafariUserAgent:         (*gofakeit.Faker).SafariUserAgent,
+	MethodSafeColor:               (*gofakeit.Faker).SafeColor,
+	MethodState:                   (*gofakeit.Faker).State,
+	MethodStateAbr:                (*gofakeit.Faker).StateAbr,
+	MethodStreet:                  (*gofakeit.Faker).Street,
+	MethodStreetName:              (*gofakeit.Faker).StreetName,
+	MethodStreetNumber:            (*gofakeit.Faker).StreetNumber,
+	MethodStreetPrefix:            (*gofakeit.Faker).StreetPrefix,
+	MethodStreetSuffix:            (*gofakeit.Faker).StreetSuffix,
+	MethodTimeZone:                (*gofakeit.Faker).TimeZone,
+	MethodTimeZoneAbv:             (*gofakeit.Faker).TimeZoneAbv,
+	MethodTimeZoneFull:            (*gofakeit.Faker).TimeZoneFull,
+	MethodCarTransmissionType:     (*gofakeit.Faker).CarTransmissionType,
+	MethodURL:                     (*gofakeit.Faker).URL,
+	MethodUserAgent:               (*gofakeit.Faker).UserAgent,
+	MethodUsername:                (*gofakeit.Faker).Username,
+	MethodCarType:                 (*gofakeit.Faker).CarType,
+	MethodWeekDay:                 (*gofakeit.Faker).WeekDay,
+	MethodWord:                    (*gofakeit.Faker).Word,
+	MethodZip:                     (*gofakeit.Faker).Zip,
 }
 
-// NewDocumentGenerator creates an object generator to generate valid bson documents
-func (ci *CollInfo) NewDocumentGenerator(content map[string]Config) (*DocumentGenerator, error) {
-	buffer := NewDocBuffer()
+// NewDocumentGenerator creates an object generator to generate valid bson documents.
+// Fields of the documents are generated in the same order as in content
+func (ci *CollInfo) NewDocumentGenerator(content Content) (*DocumentGenerator, error) {
+	d, err := ci.newDocumentGenerator(NewDocBuffer(), content, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	d.streams, d.seekers = ci.streams, ci.seekers
+	ci.streams, ci.seekers = nil, nil
+
+	ci.registerReferencedCollections(content, func() (*DocumentGenerator, error) {
+		return ci.NewDocumentGenerator(content)
+	})
+	return d, nil
+}
+
+// newDocumentGenerator creates the generators of the fields of content, writing to buffer.
+// pathPrefix is added to the path of the fields to derive their random streams, and previous
+// holds the fields generated before the ones of content. Streams and seekers of the generators
+// are left in ci
+func (ci *CollInfo) newDocumentGenerator(buffer *DocBuffer, content Content, pathPrefix string, previous []string) (*DocumentGenerator, error) {
 	d := &DocumentGenerator{
 		Buffer:     buffer,
 		Generators: make([]Generator, 0, len(content)),
+		seed:       ci.Seed,
 	}
+	ci.streams, ci.seekers = nil, nil
 
-	// a field can reference another field in the same collection. As go map are unordered,
-	// we need to make sure that the field with the 'refContent' is initialized first
-	fields := make([]string, 0, len(content))
-	for k, v := range content {
-		if v.Type == "reference" && v.RefContent == nil {
-			fields = append(fields, k)
+	if pathPrefix == "" {
+		if err := ci.assignReferences(content); err != nil {
+			return nil, err
+		}
+	}
+
+	// a field can reference another field declared after it in the same collection,
+	// so we need to make sure that the field with the 'refContent' is initialized first.
+	// This is also true for the fields of a tree, which use the ids of the nodes
+	initOrder := make([]int, 0, len(content))
+	for i, f := range content {
+		if (f.Config.Type == TypeReference || f.Config.Type == TypeRef) && f.Config.RefContent == nil || f.Config.Type == TypeTree {
+			initOrder = append(initOrder, i)
 		} else {
-			fields = append([]string{k}, fields...)
+			initOrder = append([]int{i}, initOrder...)
 		}
 	}
 
-	for _, field := range fields {
+	generators := make([]Generator, len(content))
+	for _, i := range initOrder {
+
+		ci.generatedBefore = previous
+		for _, f := range content[:i] {
+			ci.generatedBefore = append(ci.generatedBefore[:len(ci.generatedBefore):len(ci.generatedBefore)], f.Name)
+		}
+		ci.created, ci.pathPrefix = make(map[string]bool), pathPrefix
 
-		c := content[field]
-		g, err := ci.newGenerator(buffer, field, &c)
+		g, err := ci.newGenerator(buffer, content[i].Name, pathPrefix+content[i].Name, &content[i].Config)
 		if err != nil {
-			return nil, fmt.Errorf("invalid generator for field '%s'\n  cause: %v", field, err)
+			return nil, fmt.Errorf("invalid generator for field '%s'\n  cause: %v", content[i].Name, err)
 		}
+		generators[i] = g
+	}
+
+	ci.generatedBefore, ci.created, ci.pathPrefix = nil, nil, ""
+
+	for _, g := range generators {
 		d.Add(g)
 	}
 	return d, nil
 }
 
-func (ci *CollInfo) newGenerator(buffer *DocBuffer, key string, config *Config) (Generator, error) {
+// newGenerator creates the generator of a field. path is the full path of the field
+// in the document, like 'parent.child', and is used to derive the random streams
+// of the generator
+func (ci *CollInfo) newGenerator(buffer *DocBuffer, key, path string, config *Config) (Generator, error) {
+	// the generators of a oneOf, a switch or a template are only used
+	// in some of the documents, like the fields that may be missing
+	variableValues := ci.variableValues
+	if config.NullPercentage != 0 || config.MissingPercentage != 0 || config.NullValuePercentage != 0 || config.Condition != nil ||
+		config.Type == TypeOneOf || config.Type == TypeSwitch || config.Type == TypeTemplate {
+		ci.variableValues = true
+	}
+	g, err := ci.newGeneratorOfType(buffer, key, path, config)
+	ci.variableValues = variableValues
+	if s, ok := g.(seeker); ok && err == nil {
+		ci.seekers = append(ci.seekers, s)
+	}
+	if ci.created != nil {
+		ci.created[strings.TrimPrefix(path, ci.pathPrefix)] = true
+	}
+	return g, err
+}
+
+func (ci *CollInfo) newGeneratorOfType(buffer *DocBuffer, key, path string, config *Config) (Generator, error) {
 
 	if config.NullPercentage > 100 || config.NullPercentage < 0 {
 		return nil, errors.New("null percentage has to be between 0 and 100")
 	}
+	if config.MissingPercentage > 100 || config.MissingPercentage < 0 {
+		return nil, errors.New("missing percentage has to be between 0 and 100")
+	}
+	if config.NullValuePercentage > 100 || config.NullValuePercentage < 0 {
+		return nil, errors.New("null value percentage has to be between 0 and 100")
+	}
+	if config.NullPercentage != 0 && config.MissingPercentage != 0 {
+		return nil, errors.New("'nullPercentage' and 'missingPercentage' can't be used together, use 'missingPercentage' only")
+	}
+	if config.NullPercentage+config.MissingPercentage+config.NullValuePercentage > 100 {
+		return nil, errors.New("the sum of missing percentage and null value percentage has to be <= 100")
+	}
 	// use a default key of length 1. This can happen for a generator of type fromArray
 	// used as generator of an ArrayGenerator
 	if len(key) == 0 {
@@ -489,82 +710,120 @@ func (ci *CollInfo) newGenerator(buffer *DocBuffer, key string, config *Config)
 	if !ok {
 		return nil, fmt.Errorf("invalid type '%s'", config.Type)
 	}
-	nullPercentage := uint32(config.NullPercentage) * 10
-	base := newBase(key, nullPercentage, bsonType, buffer, ci.pcg32)
+	nullPercentage := uint32(config.NullPercentage+config.MissingPercentage) * 10
+	nullValuePercentage := uint32(config.NullValuePercentage) * 10
+	stream := ci.newStream(path)
+	pcg64 := stream.pcg64
+	base := newBase(key, nullPercentage, nullValuePercentage, bsonType, buffer, stream.pcg32)
+
+	condition, err := newCondition(config.Condition)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'condition': %v", err)
+	}
+	base.condition = condition
+
+	if config.Unique {
+		switch config.Type {
+		case TypeString, TypeInt, TypeLong, TypeDouble, TypeDate, TypeBinary:
+		case TypeObjectID:
+			// ObjectIds are always unique within a collection
+		default:
+			return nil, fmt.Errorf("'unique' can't be used with type '%s'", config.Type)
+		}
+	}
+
+	if config.MaxDistinctValue != 0 && (config.Type == TypeExpression || config.Type == TypeTemplate || config.Type == TypeTree) {
+		return nil, fmt.Errorf("'maxDistinctValue' can't be used with type '%s'", config.Type)
+	}
+	if config.MaxDistinctValue != 0 && (config.Type == TypeReference || config.Type == TypeRef) && (hasCardinality(config) || config.Fields != nil || config.Edge != "") {
+		return nil, errors.New("'maxDistinctValue' can't be used with 'minChildren', 'maxChildren', 'distribution', 'fields' or 'edge'")
+	}
 
 	if config.MaxDistinctValue != 0 {
 		// there is no point in having a maxDistinctValue
 		// greater than the number of doc to generate, since
-		// it use a fromArrayGenerator with randomOrder=false
+		// values are used in turn
 		size := config.MaxDistinctValue
 		if size > ci.Count {
 			size = ci.Count
 		}
-		// set to 0 to avoid infinite loop when calling ci.preGenerate()
-		config.MaxDistinctValue = 0
+		// set to 0 to avoid infinite loop when creating the generator of the
+		// values. Work on a copy, as the config may be used to create several
+		// generators
+		valuesConfig := *config
+		valuesConfig.MaxDistinctValue = 0
 
-		values, bsonType, err := ci.preGenerate(key, config, size)
+		source, err := ci.newValueSource(key, path, &valuesConfig, size)
 		if err != nil {
 			return nil, err
 		}
-		base.bsonType = bsonType
+		return newValuesGenerator(base, source)
+	}
 
-		return newFromArrayGeneratorWithPregeneratedValues(base, values, false)
+	// unique values are given in the order of the documents, so
+	// a document can't hold several of them
+	if config.Unique && config.Type != TypeString && (strings.HasSuffix(path, ".$") || strings.Contains(path, ".$.")) {
+		return nil, errors.New("'unique' can't be used for a field inside an array")
 	}
 
 	switch config.Type {
 
 	case TypeString:
-		return newStringGenerator(config, base, ci.Count)
+		return newStringGenerator(config, base, pcg64, ci.Count, ci.valuesPerDoc)
 
 	case TypeInt:
-		return newIntGenerator(config, base)
+		return newIntGenerator(config, base, pcg64, ci.Count)
 
 	case TypeLong:
-		return newLongGenerator(config, base, ci.pcg64)
+		return newLongGenerator(config, base, pcg64, ci.Count)
 
 	case TypeDouble:
-		return newDoubleGenerator(config, base, ci.pcg64)
+		return newDoubleGenerator(config, base, pcg64, ci.Count)
 
 	case TypeDecimal:
 		if !ci.versionAtLeast(3, 4) {
 			return nil, errors.New("decimal type (bson decimal128) requires mongodb 3.4 at least")
 		}
-		return newDecimalGenerator(base, ci.pcg64)
+		return newDecimalGenerator(base, pcg64)
 
 	case TypeBoolean:
 		return newBoolGenerator(base)
 
 	case TypeObjectID:
-		return newObjectIDGenerator(base)
+		return newObjectIDGenerator(base, ci.Seed, ci.Namespace, pcg64)
 
 	case TypeArray:
-		return newArrayGenerator(config, base, ci, buffer)
+		return newArrayGenerator(config, base, ci, buffer, path)
 
 	case TypeObject:
-		return newEmbededGenerator(config, base, ci, buffer)
+		return newEmbededGenerator(config, base, ci, buffer, path)
 
 	case TypeFromArray, TypeEnum:
 		return newFromArrayGenerator(config, base)
 
 	case TypeBinary:
-		return newBinaryGenerator(config, base)
+		return newBinaryGenerator(config, base, pcg64, ci.Count)
 
 	case TypeDate:
-		return newDateGenerator(config, base, ci.pcg64)
+		return newDateGenerator(config, base, pcg64, ci.Count)
 
 	case TypePosition, TypeCoordinates:
-		return newPositionGenerator(base, ci.pcg64)
+		return newPositionGenerator(base, pcg64)
 
 	case TypeConstant:
 		return newConstantGenerator(base, config.ConstVal)
 
 	case TypeAutoincrement:
+		// the first value of a chunk is computed from the number of values per
+		// document, so it has to be the same for all documents
+		if ci.variableValues {
+			return nil, errors.New("'autoincrement' has to be generated the same number of times in every document, so it can't have a 'nullPercentage', 'missingPercentage', 'nullValuePercentage' or 'condition', or be inside an array of variable length, a 'oneOf', a 'switch', a template variable, a schema variant or a field that may be missing")
+		}
 		switch config.AutoType {
 		case TypeInt:
-			return newAutoIncrementIntGenerator(config, base)
+			return newAutoIncrementIntGenerator(config, base, ci.Count, ci.valuesPerDoc)
 		case TypeLong:
-			return newAutoIncrementLongGenerator(config, base)
+			return newAutoIncrementLongGenerator(config, base, ci.Count, ci.valuesPerDoc)
 		default:
 			return nil, fmt.Errorf("invalid type '%s', must be one of ['int', 'long']", config.Type)
 		}
@@ -573,36 +832,94 @@ func (ci *CollInfo) newGenerator(buffer *DocBuffer, key string, config *Config)
 		switch config.UUIDFormat {
 		case "", // default is string
 			TypeString:
-			return newStringUUIDGenerator(base)
+			return newStringUUIDGenerator(base, pcg64)
 		case TypeBinary:
-			return newBinaryUUIDGenerator(base)
+			return newBinaryUUIDGenerator(base, pcg64)
 		default:
 			return nil, fmt.Errorf("invalid format '%s', must be one of ['string', 'binary']", config.UUIDFormat)
 		}
 
 	case TypeFaker:
-		return newFakerGenerator(config, base)
+		return newFakerGenerator(config, base, pcg64)
 
 	case TypeStringFromParts:
-		return newStringFromPartsGenerator(config, base, ci, buffer)
+		return newStringFromPartsGenerator(config, base, ci, buffer, path)
+
+	case TypeOneOf:
+		return newOneOfGenerator(config, base, ci, buffer, path)
+
+	case TypeSwitch:
+		return newSwitchGenerator(config, base, ci, buffer, path)
+
+	case TypeExpression:
+		return newExpressionGenerator(config, base, ci, path)
+
+	case TypeTemplate:
+		return newTemplateGenerator(config, base, ci, buffer, path)
+
+	case TypeTree:
+		return newTreeGenerator(config, base, ci)
+
+	case TypeTimestamp:
+		return newTimestampGenerator(config, base, pcg64)
+
+	case TypeRegex:
+		return newRegexGenerator(config, base, pcg64)
+
+	case TypeJavascript:
+		return newJavascriptGenerator(config, base, pcg64)
+
+	case TypeSymbol:
+		// symbols are stored like strings
+		return newStringGenerator(config, base, pcg64, ci.Count, ci.valuesPerDoc)
+
+	case TypeDBPointer:
+		return newDBPointerGenerator(config, base, ci.Namespace, pcg64)
+
+	case TypeMinKey:
+		return newKeyGenerator(base, "MinKey")
+
+	case TypeMaxKey:
+		return newKeyGenerator(base, "MaxKey")
+
+	case TypeNull:
+		return newNullGenerator(base)
 
 	case TypeRef, TypeReference:
-		_, ok := ci.mapRef[config.ID]
+		if config.InOrder && config.RefContent != nil {
+			return nil, errors.New("'inOrder' can only be used in the collections using the values of the reference, not with 'refContent'")
+		}
+		r, ok := ci.references[config.ID]
 		if !ok {
 
 			if config.RefContent == nil {
 				return nil, errors.New("'refContent' can't be null or empty'")
 			}
 
-			values, bsonType, err := ci.preGenerate(key, config.RefContent, ci.Count)
+			values, err := ci.newValueSource(key, path, config.RefContent, ci.Count)
 			if err != nil {
 				return nil, err
 			}
-			ci.mapRef[config.ID] = values
-			ci.mapRefType[config.ID] = bsonType
+			r = &reference{
+				values: values,
+				// the n-th document holds the n-th value only if the
+				// field is a top level field present in all documents
+				inAllDocuments: key == path && config.NullPercentage == 0 && config.MissingPercentage == 0 &&
+					config.NullValuePercentage == 0 && config.Condition == nil && config.MaxDistinctValue == 0,
+			}
+			ci.references[config.ID] = r
+		}
+		if hasCardinality(config) || config.Fields != nil || config.Edge != "" || (config.RefContent == nil && ci.cardinalities[config.ID] != nil) {
+			return newReferenceGenerator(config, base, ci, path)
+		}
+		// the field with 'refContent' holds the values in order
+		if config.RefContent == nil && !config.InOrder {
+			if ci.valuesPerDoc > 1 {
+				return newRandomValuesGenerator(base, r.values, nil, pcg64)
+			}
+			return newRandomValuesGenerator(base, r.values, ci.newRandomParents(config.ID), nil)
 		}
-		base.bsonType = ci.mapRefType[config.ID]
-		return newFromArrayGeneratorWithPregeneratedValues(base, ci.mapRef[config.ID], true)
+		return newValuesGenerator(base, r.values)
 	}
 
 	return nil, nil
@@ -622,49 +939,15 @@ func (ci *CollInfo) versionAtLeast(v ...int) (result bool) {
 	return true
 }
 
-// preGenerate generates `nb`values using a generator created from config
-func (ci *CollInfo) preGenerate(key string, config *Config, nb int) (values [][]byte, bsonType bsontype.Type, err error) {
-
-	if nb < 0 {
-		return nil, bson.TypeNull, errors.New("maxDistinctValue can't be negative")
-	}
-
-	buffer := NewDocBuffer()
-	tmpCi := NewCollInfo(ci.Count, ci.Version, ci.Seed, ci.mapRef, ci.mapRefType)
-	g, err := tmpCi.newGenerator(buffer, key, config)
-	if err != nil {
-		return nil, bson.TypeNull, fmt.Errorf("error while creating base array: %v", err)
-	}
-
-	values = make([][]byte, nb)
-	for i := 0; i < nb; i++ {
-		g.EncodeValue()
-		tmpArr := make([]byte, buffer.Len())
-		copy(tmpArr, buffer.Bytes())
-		values[i] = tmpArr
-		buffer.Truncate(0)
-	}
-	if nb > 1 {
-		if bytes.Equal(values[0], values[1]) {
-			return nil, bson.TypeNull, errors.New("couldn't generate enough unique values")
-		}
-	}
-	return values, g.Type(), nil
-}
-
-// NewAggregatorSlice creates a slice of Aggregator from a map of Config
-func (ci *CollInfo) NewAggregatorSlice(content map[string]Config) ([]Aggregator, error) {
-	return ci.newAggregatorFromMap(content)
-}
-
-func (ci *CollInfo) newAggregatorFromMap(content map[string]Config) ([]Aggregator, error) {
+// NewAggregatorSlice creates a slice of Aggregator from a Content
+func (ci *CollInfo) NewAggregatorSlice(content Content) ([]Aggregator, error) {
 	agArr := make([]Aggregator, 0)
-	for k, v := range content {
-		switch v.Type {
+	for _, f := range content {
+		switch f.Config.Type {
 		case TypeCountAggregator, TypeValueAggregator, TypeBoundAggregator:
-			a, err := ci.newAggregator(k, &v)
+			a, err := ci.newAggregator(f.Name, &f.Config)
 			if err != nil {
-				return nil, fmt.Errorf("invalid generator for field '%s'\n  cause: %v", k, err)
+				return nil, fmt.Errorf("invalid generator for field '%s'\n  cause: %v", f.Name, err)
 			}
 			agArr = append(agArr, a)
 		default:
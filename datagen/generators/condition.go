@@ -0,0 +1,336 @@
+package generators
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// operators available in a condition
+var conditionOperators = []string{"$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$in", "$nin", "$exists"}
+
+// condition is a predicate on the fields already generated in the current document,
+// written like a MongoDB query, for example:
+//
+//	{"status": "cancelled", "age": {"$gte": 18}}
+//
+// All the clauses of the condition have to match
+type condition struct {
+	clauses []clause
+}
+
+type clause struct {
+	// path of the field from the root of the document
+	path     []string
+	operator string
+	// values to compare the field to
+	values []bson.RawValue
+	// for the $exists operator only
+	exists bool
+}
+
+// newCondition parses query, and returns nil if there is nothing to check
+func newCondition(query bson.M) (*condition, error) {
+
+	if len(query) == 0 {
+		return nil, nil
+	}
+	// sort the fields so errors don't depend on the order of a go map
+	fields := make([]string, 0, len(query))
+	for field := range query {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	c := &condition{}
+	for _, field := range fields {
+
+		if field == "" || strings.HasPrefix(field, "$") {
+			return nil, fmt.Errorf("invalid field '%s' in condition", field)
+		}
+		path := strings.Split(field, ".")
+
+		operators, ok := asMap(query[field])
+		if !ok || !isOperatorList(operators) {
+			value, err := conditionValue(query[field])
+			if err != nil {
+				return nil, fmt.Errorf("for field '%s': %v", field, err)
+			}
+			c.clauses = append(c.clauses, clause{path: path, operator: "$eq", values: []bson.RawValue{value}})
+			continue
+		}
+
+		ops := make([]string, 0, len(operators))
+		for op := range operators {
+			ops = append(ops, op)
+		}
+		sort.Strings(ops)
+
+		for _, op := range ops {
+			cl, err := newClause(path, op, operators[op])
+			if err != nil {
+				return nil, fmt.Errorf("for field '%s': %v", field, err)
+			}
+			c.clauses = append(c.clauses, cl)
+		}
+	}
+	return c, nil
+}
+
+func newClause(path []string, operator string, arg any) (clause, error) {
+
+	cl := clause{path: path, operator: operator}
+
+	switch operator {
+
+	case "$exists":
+		exists, ok := arg.(bool)
+		if !ok {
+			return cl, errors.New("'$exists' has to be a boolean")
+		}
+		cl.exists = exists
+
+	case "$in", "$nin":
+		list, ok := arg.([]any)
+		if !ok {
+			return cl, fmt.Errorf("'%s' has to be an array", operator)
+		}
+		for _, v := range list {
+			value, err := conditionValue(v)
+			if err != nil {
+				return cl, err
+			}
+			cl.values = append(cl.values, value)
+		}
+
+	case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte":
+		value, err := conditionValue(arg)
+		if err != nil {
+			return cl, err
+		}
+		if value.Type == bson.TypeNull && operator != "$eq" && operator != "$ne" {
+			return cl, fmt.Errorf("'%s' can't be used with null", operator)
+		}
+		cl.values = []bson.RawValue{value}
+
+	default:
+		return cl, fmt.Errorf("invalid operator '%s', must be one of %v", operator, conditionOperators)
+	}
+	return cl, nil
+}
+
+func asMap(v any) (map[string]any, bool) {
+	switch m := v.(type) {
+	case map[string]any:
+		return m, true
+	case bson.M:
+		return m, true
+	}
+	return nil, false
+}
+
+func isOperatorList(m map[string]any) bool {
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return false
+		}
+	}
+	return len(m) > 0
+}
+
+// conditionValue returns v as a bson value that can be compared to
+// a generated value
+func conditionValue(v any) (bson.RawValue, error) {
+	switch n := v.(type) {
+	case nil:
+		return bson.RawValue{Type: bson.TypeNull}, nil
+	case string, bool, float64, int, int32, int64:
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			v = i
+		} else if f, err := n.Float64(); err == nil {
+			v = f
+		} else {
+			return bson.RawValue{}, fmt.Errorf("can't parse number '%s':\n%w", n, err)
+		}
+	default:
+		return bson.RawValue{}, fmt.Errorf("unsupported value %v, only numbers, strings, booleans and null are supported", v)
+	}
+	t, data, err := bson.MarshalValue(v)
+	return bson.RawValue{Type: t, Value: data}, err
+}
+
+// match returns true if the fields already written in buffer match the
+// condition. buffer holds the current document, starting with its length
+func (c *condition) match(buffer *DocBuffer) bool {
+	doc := buffer.Bytes()
+	if len(doc) < 4 {
+		return false
+	}
+	for _, cl := range c.clauses {
+		value, found := lookupGenerated(doc[4:], cl.path)
+		if !cl.match(value, found) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cl *clause) match(value bson.RawValue, found bool) bool {
+
+	switch cl.operator {
+	case "$exists":
+		return found == cl.exists
+	case "$eq":
+		return equal(value, found, cl.values[0])
+	case "$ne":
+		return !equal(value, found, cl.values[0])
+	case "$in", "$nin":
+		in := false
+		for _, v := range cl.values {
+			if equal(value, found, v) {
+				in = true
+				break
+			}
+		}
+		return in == (cl.operator == "$in")
+	}
+
+	if !found {
+		return false
+	}
+	cmp, ok := compareToValue(value, cl.values[0])
+	if !ok {
+		return false
+	}
+	switch cl.operator {
+	case "$gt":
+		return cmp > 0
+	case "$gte":
+		return cmp >= 0
+	case "$lt":
+		return cmp < 0
+	default: // $lte
+		return cmp <= 0
+	}
+}
+
+// equal returns true if value is equal to expected. Like in MongoDB,
+// a missing field is equal to null
+func equal(value bson.RawValue, found bool, expected bson.RawValue) bool {
+	if !found {
+		return expected.Type == bson.TypeNull
+	}
+	cmp, ok := compareToValue(value, expected)
+	return ok && cmp == 0
+}
+
+// compareToValue compares value to expected, and returns false if they
+// can't be compared, ie if they are of different types
+func compareToValue(value, expected bson.RawValue) (int, bool) {
+	if typeOrder[value.Type] != typeOrder[expected.Type] {
+		return 0, false
+	}
+	return compareValues(value, expected), true
+}
+
+// lookupGenerated returns the value of the field at path in doc, the elements
+// of the document being generated. The document isn't complete: the last embedded
+// document or array may still be open, with a length of 0 as it's only set once
+// all its elements are written
+func lookupGenerated(doc []byte, path []string) (bson.RawValue, bool) {
+
+	for len(doc) > 0 {
+
+		t := bsontype.Type(doc[0])
+		if t == 0 {
+			return bson.RawValue{}, false
+		}
+		key, rem, ok := bsoncore.ReadKey(doc[1:])
+		if !ok {
+			return bson.RawValue{}, false
+		}
+
+		if t == bson.TypeEmbeddedDocument || t == bson.TypeArray {
+			length, _, ok := bsoncore.ReadLength(rem)
+			if ok && length == 0 {
+				// open document, nothing can be written after it
+				if key != path[0] || len(path) == 1 {
+					return bson.RawValue{}, false
+				}
+				return lookupGenerated(rem[4:], path[1:])
+			}
+		}
+
+		value, rest, ok := bsoncore.ReadValue(rem, t)
+		if !ok {
+			return bson.RawValue{}, false
+		}
+		if key == path[0] {
+			if len(path) == 1 {
+				return bson.RawValue{Type: t, Value: value.Data}, true
+			}
+			if t != bson.TypeEmbeddedDocument && t != bson.TypeArray {
+				return bson.RawValue{}, false
+			}
+			// skip the length and the terminating byte of the document
+			return lookupGenerated(value.Data[4:len(value.Data)-1], path[1:])
+		}
+		doc = rest
+	}
+	return bson.RawValue{}, false
+}
+
+// dependencies returns the top level fields used by the conditions, the
+// expressions and the templates of config and of its nested generators
+func dependencies(config *Config) []string {
+
+	var fields []string
+	add := func(query bson.M) {
+		for field := range query {
+			fields = append(fields, strings.Split(field, ".")[0])
+		}
+	}
+	add(config.Condition)
+	if config.Expression != "" {
+		if expr, err := parseExpression(config.Expression); err == nil {
+			for _, path := range expr.fields {
+				fields = append(fields, path[0])
+			}
+		}
+	}
+	if config.Template != "" {
+		if t, err := parseTemplate(config.Template); err == nil {
+			for _, path := range t.fields() {
+				fields = append(fields, path[0])
+			}
+		}
+	}
+	for name := range config.Variables {
+		variable := config.Variables[name]
+		fields = append(fields, dependencies(&variable)...)
+	}
+	for i := range config.Cases {
+		add(config.Cases[i].Condition)
+		fields = append(fields, dependencies(&config.Cases[i].Generator)...)
+	}
+	if config.Default != nil {
+		fields = append(fields, dependencies(config.Default)...)
+	}
+	if config.ArrayContent != nil {
+		fields = append(fields, dependencies(config.ArrayContent)...)
+	}
+	for i := range config.ObjectContent {
+		fields = append(fields, dependencies(&config.ObjectContent[i].Config)...)
+	}
+	for i := range config.Generators {
+		fields = append(fields, dependencies(&config.Generators[i])...)
+	}
+	return fields
+}
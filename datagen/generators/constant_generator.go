@@ -30,6 +30,17 @@ func newConstantGenerator(base base, value any) (Generator, error) {
 	}, nil
 }
 
+// newNullGenerator returns a generator for explicit null values. Unlike a missing
+// field, the field is present in the document with a null value
+func newNullGenerator(base base) (Generator, error) {
+	g, err := newConstantGenerator(base, nil)
+	if err != nil {
+		return nil, err
+	}
+	g.(*constGenerator).strVal = []byte("null")
+	return g, nil
+}
+
 func (g *constGenerator) EncodeValue() {
 	g.buffer.Write(g.bsonVal)
 }
@@ -10,14 +10,14 @@ import (
 )
 
 func TestDocumentWithValidConstantObjectID(t *testing.T) {
-	ci := generators.NewCollInfo(1, []int{3, 6, 4}, defaultSeed, nil, nil)
-	docGenerator, err := ci.NewDocumentGenerator(map[string]generators.Config{
-		"key": {
+	ci := generators.NewCollInfo(1, []int{3, 6, 4}, defaultSeed, nil)
+	docGenerator, err := ci.NewDocumentGenerator(generators.Content{
+		{Name: "key", Config: generators.Config{
 			Type: generators.TypeConstant,
 			ConstVal: map[string]any{
 				"$oid": "5a934e000102030405000001",
 			},
-		},
+		}},
 	})
 	if err != nil {
 		t.Error(err)
@@ -38,14 +38,14 @@ func TestDocumentWithValidConstantObjectID(t *testing.T) {
 }
 
 func TestDocumentWithInvalidConstantObjectID(t *testing.T) {
-	ci := generators.NewCollInfo(1, []int{3, 6, 4}, defaultSeed, nil, nil)
-	docGenerator, err := ci.NewDocumentGenerator(map[string]generators.Config{
-		"key": {
+	ci := generators.NewCollInfo(1, []int{3, 6, 4}, defaultSeed, nil)
+	docGenerator, err := ci.NewDocumentGenerator(generators.Content{
+		{Name: "key", Config: generators.Config{
 			Type: generators.TypeConstant,
 			ConstVal: map[string]any{
 				"$oid": "5a9",
 			},
-		},
+		}},
 	})
 	if err != nil {
 		t.Error(err)
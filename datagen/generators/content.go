@@ -0,0 +1,109 @@
+package generators
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Field is a field of a document, with the Config of the generator
+// used to create its value
+type Field struct {
+	Name   string
+	Config Config
+}
+
+// Content holds the fields of a document. Unlike a go map, it keeps the fields
+// in the order in which they are declared in the configuration file, so the
+// generated documents have a stable field order
+type Content []Field
+
+// Get returns the Config of a field, and whether this field exists
+func (c Content) Get(name string) (Config, bool) {
+	for _, f := range c {
+		if f.Name == name {
+			return f.Config, true
+		}
+	}
+	return Config{}, false
+}
+
+// Set replaces the Config of a field if it already exists, or appends a new
+// field at the end of the content otherwise
+func (c *Content) Set(name string, config Config) {
+	for i := range *c {
+		if (*c)[i].Name == name {
+			(*c)[i].Config = config
+			return
+		}
+	}
+	*c = append(*c, Field{Name: name, Config: config})
+}
+
+// UnmarshalJSON decodes a json object into a Content, keeping the order of its keys.
+// Like in the rest of the configuration, unknown fields are not allowed and numbers
+// are decoded as json.Number
+func (c *Content) UnmarshalJSON(data []byte) error {
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	decoder.UseNumber()
+
+	tok, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		*c = nil
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return errors.New("content has to be an object")
+	}
+
+	content := make(Content, 0)
+	for decoder.More() {
+
+		tok, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		// keys of a json object are always strings
+		key := tok.(string)
+
+		var config Config
+		if err := decoder.Decode(&config); err != nil {
+			return fmt.Errorf("for field '%s': %w", key, err)
+		}
+		content.Set(key, config)
+	}
+	*c = content
+	return nil
+}
+
+// MarshalJSON encodes a Content as a json object, keeping the order of the fields
+func (c Content) MarshalJSON() ([]byte, error) {
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range c {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		value, err := json.Marshal(f.Config)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
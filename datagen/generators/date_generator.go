@@ -2,6 +2,7 @@ package generators
 
 import (
 	"errors"
+	"math"
 	"time"
 
 	"github.com/MichaelTJones/pcg"
@@ -15,10 +16,33 @@ type dateGenerator struct {
 	pcg64     *pcg.PCG64
 }
 
-func newDateGenerator(config *Config, base base, pcg64 *pcg.PCG64) (Generator, error) {
+func newDateGenerator(config *Config, base base, pcg64 *pcg.PCG64, count int) (Generator, error) {
 	if config.StartDate.Unix() > config.EndDate.Unix() {
 		return nil, errors.New("make sure that 'startDate' < 'endDate'")
 	}
+	// distributions give the number of seconds since startDate
+	dist, err := newDistribution(config, 0, float64(config.EndDate.Unix()-config.StartDate.Unix()), pcg64)
+	if err != nil {
+		return nil, err
+	}
+	if config.Unique {
+		seq, err := newUniqueSequence(uint64(config.EndDate.Unix()-config.StartDate.Unix()), count, 1, pcg64)
+		if err != nil {
+			return nil, err
+		}
+		return &uniqueDateGenerator{
+			base:           base,
+			uniqueSequence: seq,
+			startDate:      uint64(config.StartDate.Unix()),
+		}, nil
+	}
+	if dist != nil {
+		return &dateDistributionGenerator{
+			base:         base,
+			distribution: dist,
+			startDate:    config.StartDate.Unix(),
+		}, nil
+	}
 	return &dateGenerator{
 		base:      base,
 		startDate: uint64(config.StartDate.Unix()),
@@ -37,3 +61,41 @@ func (g *dateGenerator) EncodeValueAsString() {
 	t := time.Unix(int64(s), 0)
 	g.buffer.WriteString(t.Format(time.RFC822))
 }
+
+// Generator for creating unique dates within bounds. Dates
+// have a precision of one second
+type uniqueDateGenerator struct {
+	base
+	*uniqueSequence
+	startDate uint64
+}
+
+func (g *uniqueDateGenerator) EncodeValue() {
+	g.buffer.Write(uint64Bytes((g.next() + g.startDate) * 1000))
+}
+
+func (g *uniqueDateGenerator) EncodeValueAsString() {
+	t := time.Unix(int64(g.next()+g.startDate), 0)
+	g.buffer.WriteString(t.Format(time.RFC822))
+}
+
+// Generator for creating dates within bounds following a distribution.
+// Dates have a precision of one second
+type dateDistributionGenerator struct {
+	base
+	*distribution
+	startDate int64
+}
+
+func (g *dateDistributionGenerator) EncodeValue() {
+	g.buffer.Write(int64Bytes(g.nextUnix() * 1000))
+}
+
+func (g *dateDistributionGenerator) EncodeValueAsString() {
+	t := time.Unix(g.nextUnix(), 0)
+	g.buffer.WriteString(t.Format(time.RFC822))
+}
+
+func (g *dateDistributionGenerator) nextUnix() int64 {
+	return g.startDate + int64(math.Round(g.next()))
+}
@@ -0,0 +1,59 @@
+package generators
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/MichaelTJones/pcg"
+)
+
+// Generator for creating random bson DBPointers to the namespace
+// `Database`.`Collection`
+type dbPointerGenerator struct {
+	base
+	namespace []byte
+	pcg64     *pcg.PCG64
+}
+
+func newDBPointerGenerator(config *Config, base base, namespace string, pcg64 *pcg.PCG64) (Generator, error) {
+	// default to the database and to the collection being generated
+	database, collection, _ := strings.Cut(namespace, ".")
+	if config.Database != "" {
+		database = config.Database
+	}
+	if config.Collection != "" {
+		collection = config.Collection
+	}
+	namespace = database + "." + collection
+	return &dbPointerGenerator{
+		base:      base,
+		namespace: []byte(namespace),
+		pcg64:     pcg64,
+	}, nil
+}
+
+func (g *dbPointerGenerator) EncodeValue() {
+	// namespace is stored like a string
+	g.buffer.Write(uint32Bytes(uint32(len(g.namespace) + 1)))
+	g.buffer.Write(g.namespace)
+	g.buffer.WriteSingleByte(byte(0))
+	g.buffer.Write(g.nextObjectID())
+}
+
+func (g *dbPointerGenerator) EncodeValueAsString() {
+	dst := make([]byte, hex.EncodedLen(12))
+	hex.Encode(dst, g.nextObjectID())
+
+	g.buffer.WriteString("DBPointer(")
+	g.buffer.Write(g.namespace)
+	g.buffer.WriteString(", ")
+	g.buffer.Write(dst)
+	g.buffer.WriteSingleByte(')')
+}
+
+func (g *dbPointerGenerator) nextObjectID() []byte {
+	id := make([]byte, 12)
+	copy(id, uint64Bytes(g.pcg64.Random()))
+	copy(id[8:], uint32Bytes(uint32(g.pcg64.Random())))
+	return id
+}
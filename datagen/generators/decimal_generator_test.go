@@ -10,9 +10,9 @@ import (
 
 func TestDocumentWithDecimal128(t *testing.T) {
 
-	ci := generators.NewCollInfo(1, []int{3, 6, 4}, defaultSeed, nil, nil)
-	docGenerator, err := ci.NewDocumentGenerator(map[string]generators.Config{
-		"key": {Type: generators.TypeDecimal},
+	ci := generators.NewCollInfo(1, []int{3, 6, 4}, defaultSeed, nil)
+	docGenerator, err := ci.NewDocumentGenerator(generators.Content{
+		{Name: "key", Config: generators.Config{Type: generators.TypeDecimal}},
 	})
 	if err != nil {
 		t.Error(err)
@@ -0,0 +1,190 @@
+package generators
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/MichaelTJones/pcg"
+)
+
+// available distributions, see https://github.com/feliixx/mgodatagen/blob/master/README.md#distribution for details
+const (
+	DistributionUniform     = "uniform"
+	DistributionNormal      = "normal"
+	DistributionLogNormal   = "logNormal"
+	DistributionExponential = "exponential"
+	DistributionZipf        = "zipf"
+	DistributionPoisson     = "poisson"
+)
+
+// distribution draws random float64 following a given shape. Values
+// are clamped to the bounds of the generator
+type distribution struct {
+	rand   *rand.Rand
+	sample func(d *distribution) float64
+	min    float64
+	max    float64
+	// parameters of the distribution
+	mean   float64
+	stdDev float64
+	lambda float64
+	zipf   *rand.Zipf
+}
+
+// newDistribution returns the distribution described by config for values between
+// min and max, or nil if the values are uniformly distributed. Except for the normal
+// distribution, values are offsets from min
+func newDistribution(config *Config, min, max float64, pcg64 *pcg.PCG64) (d *distribution, err error) {
+
+	if config.Distribution == "" || config.Distribution == DistributionUniform {
+		return nil, nil
+	}
+	if config.Unique {
+		return nil, fmt.Errorf("'distribution' can't be used with 'unique'")
+	}
+
+	d = &distribution{
+		// use the stream of the generator, so values only depend on the seed
+		rand: rand.New(&pcgSource{pcg64: pcg64}),
+		min:  min,
+		max:  max,
+	}
+
+	switch config.Distribution {
+
+	case DistributionNormal:
+		d.sample = (*distribution).normal
+		d.mean, err = parseParameter("mean", config.Mean, min/2+max/2)
+		if err == nil {
+			d.stdDev, err = parseParameter("stdDev", config.StdDev, (max-min)/6)
+		}
+
+	case DistributionLogNormal:
+		d.sample = (*distribution).logNormal
+		d.mean, err = parseParameter("mean", config.Mean, 0)
+		if err == nil {
+			d.stdDev, err = parseParameter("stdDev", config.StdDev, 1)
+		}
+
+	case DistributionExponential:
+		d.sample = (*distribution).exponential
+		d.lambda, err = parseParameter("lambda", config.Lambda, 1)
+		if err == nil && d.lambda <= 0 {
+			err = fmt.Errorf("'lambda' has to be > 0")
+		}
+
+	case DistributionPoisson:
+		d.sample = (*distribution).poisson
+		d.lambda, err = parseParameter("lambda", config.Lambda, 1)
+		if err == nil && d.lambda <= 0 {
+			err = fmt.Errorf("'lambda' has to be > 0")
+		}
+
+	case DistributionZipf:
+		d.sample = (*distribution).zipfian
+		var exponent float64
+		exponent, err = parseParameter("exponent", config.Exponent, 2)
+		if err == nil && exponent <= 1 {
+			err = fmt.Errorf("'exponent' has to be > 1")
+		}
+		if err == nil {
+			// number of distinct values - 1
+			imax := uint64(math.MaxUint64)
+			if max-min < float64(math.MaxUint64) {
+				imax = uint64(max - min)
+			}
+			d.zipf = rand.NewZipf(d.rand, exponent, 1, imax)
+		}
+
+	default:
+		return nil, fmt.Errorf("invalid distribution '%s', must be one of %v", config.Distribution,
+			[]string{DistributionUniform, DistributionNormal, DistributionLogNormal, DistributionExponential, DistributionZipf, DistributionPoisson})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.stdDev < 0 {
+		return nil, fmt.Errorf("'stdDev' has to be >= 0")
+	}
+	return d, nil
+}
+
+func parseParameter(name string, n json.Number, defaultValue float64) (float64, error) {
+	if n == "" {
+		return defaultValue, nil
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return 0, fmt.Errorf("can't parse '%s' %s as a double:\n%w", name, n, err)
+	}
+	return f, nil
+}
+
+// next returns a random value between min and max
+func (d *distribution) next() float64 {
+	v := d.sample(d)
+	if v < d.min {
+		return d.min
+	}
+	if v > d.max {
+		return d.max
+	}
+	return v
+}
+
+func (d *distribution) normal() float64 {
+	return d.mean + d.rand.NormFloat64()*d.stdDev
+}
+
+func (d *distribution) logNormal() float64 {
+	return d.min + math.Exp(d.mean+d.rand.NormFloat64()*d.stdDev)
+}
+
+func (d *distribution) exponential() float64 {
+	return d.min + d.rand.ExpFloat64()/d.lambda
+}
+
+func (d *distribution) zipfian() float64 {
+	return d.min + float64(d.zipf.Uint64())
+}
+
+// poisson uses Knuth's algorithm for small lambda, and the transformed rejection
+// method of Hörmann (PTRS) otherwise, as the former is in O(lambda)
+func (d *distribution) poisson() float64 {
+	if d.lambda < 30 {
+		limit := math.Exp(-d.lambda)
+		k, p := 0.0, d.rand.Float64()
+		for p > limit {
+			k++
+			p *= d.rand.Float64()
+		}
+		return d.min + k
+	}
+
+	smu := math.Sqrt(d.lambda)
+	b := 0.931 + 2.53*smu
+	a := -0.059 + 0.02483*b
+	invAlpha := 1.1239 + 1.1328/(b-3.4)
+	vr := 0.9277 - 3.6224/(b-2)
+	logLambda := math.Log(d.lambda)
+
+	for {
+		u := d.rand.Float64() - 0.5
+		v := d.rand.Float64()
+		us := 0.5 - math.Abs(u)
+		k := math.Floor((2*a/us+b)*u + d.lambda + 0.43)
+		if us >= 0.07 && v <= vr {
+			return d.min + k
+		}
+		if k < 0 || (us < 0.013 && v > us) {
+			continue
+		}
+		lgamma, _ := math.Lgamma(k + 1)
+		if math.Log(v*invAlpha/(a/(us*us)+b)) <= -d.lambda+k*logLambda-lgamma {
+			return d.min + k
+		}
+	}
+}
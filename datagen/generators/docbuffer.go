@@ -9,6 +9,8 @@ import (
 // write at a specific position of the underlying slice of bytes
 type DocBuffer struct {
 	buf []byte
+	// index of the document being generated
+	index int
 }
 
 // NewDocBuffer returns a new DocBuffer
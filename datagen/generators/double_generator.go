@@ -12,12 +12,12 @@ import (
 // Generator for creating random float64 between `Min` and `Max`
 type doubleGenerator struct {
 	base
-	mean   float64
-	stdDev float64
-	pcg64  *pcg.PCG64
+	min   float64
+	max   float64
+	pcg64 *pcg.PCG64
 }
 
-func newDoubleGenerator(config *Config, base base, pcg64 *pcg.PCG64) (g Generator, err error) {
+func newDoubleGenerator(config *Config, base base, pcg64 *pcg.PCG64, count int) (g Generator, err error) {
 
 	min, max := 0.0, math.MaxFloat64 -2
 
@@ -38,14 +38,24 @@ func newDoubleGenerator(config *Config, base base, pcg64 *pcg.PCG64) (g Generato
 	if min > max {
 		return nil, errors.New("make sure that 'max' >= 'min'")
 	}
+	dist, err := newDistribution(config, min, max, pcg64)
+	if err != nil {
+		return nil, err
+	}
+	if config.Unique {
+		return newUniqueDoubleGenerator(base, min, max, pcg64, count)
+	}
 	if min == max {
 		return newConstantGenerator(base, max)
 	}
+	if dist != nil {
+		return &doubleDistributionGenerator{base: base, distribution: dist}, nil
+	}
 	return &doubleGenerator{
-		base:   base,
-		mean:   min,
-		stdDev: (max - min) / 2,
-		pcg64:  pcg64,
+		base:  base,
+		min:   min,
+		max:   max,
+		pcg64: pcg64,
 	}, nil
 }
 
@@ -58,5 +68,68 @@ func (g *doubleGenerator) EncodeValueAsString() {
 }
 
 func (g *doubleGenerator) boundedFloat64() float64 {
-	return float64(g.pcg64.Random())/(1<<64)*g.stdDev + g.mean
+	// interpolate between the bounds, as 'max - min' may overflow
+	r := float64(g.pcg64.Random()>>11) / (1 << 53)
+	return g.min + r*g.max - r*g.min
+}
+
+// Generator for creating float64 between `Min` and `Max` following a distribution
+type doubleDistributionGenerator struct {
+	base
+	*distribution
+}
+
+func (g *doubleDistributionGenerator) EncodeValue() {
+	g.buffer.Write(float64Bytes(g.next()))
+}
+
+func (g *doubleDistributionGenerator) EncodeValueAsString() {
+	g.buffer.WriteString(strconv.FormatFloat(g.next(), 'f', 10, 64))
+}
+
+// Generator for creating unique float64 between `Min` and `Max`. Values
+// are taken from a grid of evenly spaced values within the bounds
+type uniqueDoubleGenerator struct {
+	base
+	*uniqueSequence
+	min  float64
+	step float64
+}
+
+func newUniqueDoubleGenerator(base base, min, max float64, pcg64 *pcg.PCG64, count int) (Generator, error) {
+	// make sure two consecutive values of the grid are still distinct
+	// once rounded, ie that the step is greater than the precision of
+	// the largest values. Values are within the bounds, so use the gap
+	// below the largest one: the gap above math.MaxFloat64 is infinite
+	largest := math.Max(math.Abs(min), math.Abs(max))
+	precision := largest - math.Nextafter(largest, 0)
+
+	size := uint64(1 << 52)
+	if min == max {
+		size = 1
+	} else if steps := (max/2 - min/2) / precision; steps < float64(size) {
+		size = uint64(steps)
+	}
+	seq, err := newUniqueSequence(size, count, 1, pcg64)
+	if err != nil {
+		return nil, err
+	}
+	return &uniqueDoubleGenerator{
+		base:           base,
+		uniqueSequence: seq,
+		min:            min,
+		step:           max/float64(size) - min/float64(size),
+	}, nil
+}
+
+func (g *uniqueDoubleGenerator) EncodeValue() {
+	g.buffer.Write(float64Bytes(g.nextFloat64()))
+}
+
+func (g *uniqueDoubleGenerator) EncodeValueAsString() {
+	g.buffer.WriteString(strconv.FormatFloat(g.nextFloat64(), 'f', 10, 64))
+}
+
+func (g *uniqueDoubleGenerator) nextFloat64() float64 {
+	return g.min + float64(g.next())*g.step
 }
@@ -0,0 +1,302 @@
+package generators
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ends of an edge, see https://github.com/feliixx/mgodatagen/blob/master/README.md#edges-of-a-graph for details
+const (
+	EdgeFrom = "from"
+	EdgeTo   = "to"
+)
+
+// edges are the edges of a directed graph, one per document of a collection, whose
+// nodes are the values of a reference.
+//
+// Each node creates a number of edges given by a cardinality, like the number of
+// children of a parent. The k-th edge created by node u goes to node u+1+targets(k),
+// where targets is a permutation of [0, span) specific to u, so the edges created by
+// a node go to distinct nodes. As span is smaller than half the number of nodes, the
+// edge between u and v can only be created by u, so there are no duplicates nor
+// self-loops. A reciprocal edge created by u is followed by its reverse edge, from
+// v to u, in the next document.
+//
+// Like for a cardinality, nothing is stored: the nodes of a document are computed
+// again from its index when needed
+type edges struct {
+	settings string
+	// number of edges created by each node
+	children *cardinality
+	count    int
+	nbNodes  int
+	// number of edges created by the nodes, and number of reverse edges
+	created, reverse int
+	span             int
+	targets          *permutation
+}
+
+// assignEdges creates the edges of the reference fields of content with 'edge'. All the
+// fields of a collection using the same reference share the same edges, so the number of
+// edges per node and the reciprocity only have to be set on one of them
+func (ci *CollInfo) assignEdges(content Content) error {
+	// use the fields with settings first, so the edges aren't
+	// created with the default settings
+	for _, withSettings := range []bool{true, false} {
+		for _, f := range content {
+			config := f.Config
+			if (config.Type != TypeReference && config.Type != TypeRef) || config.RefContent != nil || config.Edge == "" {
+				continue
+			}
+			if config.Edge != EdgeFrom && config.Edge != EdgeTo {
+				return fmt.Errorf("invalid generator for field '%s'\n  cause: invalid 'edge' '%s', must be one of [%s %s]", f.Name, config.Edge, EdgeFrom, EdgeTo)
+			}
+			if (hasCardinality(&config) || config.Reciprocity != "") != withSettings {
+				continue
+			}
+			settings := fmt.Sprint(config.MinChildren, config.MaxChildren, config.Distribution, config.Mean, config.StdDev, config.Lambda, config.Exponent, config.Reciprocity)
+			if e, ok := ci.edges[config.ID]; ok {
+				if withSettings && e.settings != settings {
+					return fmt.Errorf("invalid generator for field '%s'\n  cause: all the fields with 'edge' using reference %d have to use the same 'minChildren', 'maxChildren', 'distribution' and 'reciprocity'", f.Name, config.ID)
+				}
+				continue
+			}
+			r, ok := ci.references[config.ID]
+			if !ok {
+				// the error is returned when the generator is created
+				continue
+			}
+			e, err := ci.newEdges(&config, r.values.size)
+			if err != nil {
+				return fmt.Errorf("invalid generator for field '%s'\n  cause: %v", f.Name, err)
+			}
+			e.settings = settings
+			ci.edges[config.ID] = e
+		}
+	}
+	return nil
+}
+
+func (ci *CollInfo) newEdges(config *Config, nbNodes int) (*edges, error) {
+
+	reciprocity, err := parseParameter("reciprocity", config.Reciprocity, 0)
+	if err != nil {
+		return nil, err
+	}
+	if reciprocity < 0 || reciprocity > 1 {
+		return nil, fmt.Errorf("'reciprocity' has to be between 0 and 1")
+	}
+	if nbNodes < 3 {
+		return nil, fmt.Errorf("a graph needs at least 3 nodes, but reference %d only has %d values", config.ID, nbNodes)
+	}
+	if config.MaxChildren > (nbNodes-1)/2 {
+		return nil, fmt.Errorf("'maxChildren' can't be greater than %d, as the edges created by a node go to distinct nodes among the following half of the nodes", (nbNodes-1)/2)
+	}
+	// keep the nodes of an edge close to each other, so the values of the nodes
+	// used by the documents being generated are in a few chunks of values
+	span := ChunkSize
+	if config.MaxChildren > span {
+		span = config.MaxChildren
+	}
+	if span > (nbNodes-1)/2 {
+		span = (nbNodes - 1) / 2
+	}
+
+	e := &edges{
+		count:   ci.Count,
+		nbNodes: nbNodes,
+		reverse: int(reciprocity * float64(ci.Count) / 2),
+		span:    span,
+	}
+	e.created = e.count - e.reverse
+
+	e.children, err = ci.newCardinality(config, e.created, nbNodes, span)
+	if err != nil {
+		if e.reverse > 0 {
+			err = fmt.Errorf("%v, as %d documents hold the reverse of another edge", err, e.reverse)
+		}
+		return nil, err
+	}
+	// use dedicated streams, so the edges don't depend on
+	// the number of children of the other references
+	e.children.sequence = pathSequence(fmt.Sprintf("$reference.%d.edges", config.ID))
+	e.targets = newPermutation(uint64(span), newStream(ci.Seed, pathSequence(fmt.Sprintf("$reference.%d.targets", config.ID))).pcg64)
+	return e, nil
+}
+
+// firstDocument returns the index of the document holding the edge created at index
+// created. The reciprocal edges are spread evenly among the created edges
+func (e *edges) firstDocument(created int) int {
+	return created + int(uint64(created)*uint64(e.reverse)/uint64(e.created))
+}
+
+// isReciprocal returns true if the edge created at index created has a reverse edge
+func (e *edges) isReciprocal(created int) bool {
+	return e.firstDocument(created+1)-e.firstDocument(created) == 2
+}
+
+// target returns the end of the rank-th edge created by node
+func (e *edges) target(node, rank int) int {
+	targets := *e.targets
+	for i := range targets.keys {
+		targets.keys[i] ^= splitmix64(uint64(node)<<2 | uint64(i))
+	}
+	return (node + 1 + int(targets.at(uint64(rank)))) % e.nbNodes
+}
+
+func (e *edges) newCursor() *edgeCursor {
+	return &edgeCursor{
+		edges:   e,
+		parents: e.children.newCursor(),
+	}
+}
+
+// edgeCursor gives the nodes of the edges. It can't be used concurrently
+type edgeCursor struct {
+	*edges
+	parents *parentCursor
+}
+
+// nodes returns the start and the end of the edge of the document at index doc
+func (c *edgeCursor) nodes(doc int) (int, int) {
+	created := int(uint64(doc) * uint64(c.created) / uint64(c.count))
+	for created > 0 && c.firstDocument(created) > doc {
+		created--
+	}
+	for c.firstDocument(created+1) <= doc {
+		created++
+	}
+	from, rank := c.parents.child(created)
+	to := c.target(from, rank)
+	if doc > c.firstDocument(created) {
+		return to, from
+	}
+	return from, to
+}
+
+// GraphStats describes the graph whose edges are the documents of a
+// collection, and whose nodes are the values of a reference
+type GraphStats struct {
+	// id of the reference
+	ID    int
+	Nodes int
+	Edges int
+	// fraction of the edges whose reverse edge is also in the collection
+	Reciprocity float64
+	OutDegree   DegreeStats
+	InDegree    DegreeStats
+}
+
+// DegreeStats describes the number of edges per node
+type DegreeStats struct {
+	Min, Median, P99, Max int
+	Mean                  float64
+}
+
+// GraphStats returns the statistics of the graphs whose edges are the documents of the
+// collection, sorted by reference id. The edges are computed again, without their values
+func (ci *CollInfo) GraphStats() []GraphStats {
+	ids := make([]int, 0, len(ci.edges))
+	for id := range ci.edges {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	stats := make([]GraphStats, 0, len(ids))
+	for _, id := range ids {
+		s := ci.edges[id].stats()
+		s.ID = id
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+func (e *edges) stats() GraphStats {
+
+	// a node only gets edges from the span nodes before it, or from the last nodes
+	// for the first ones, and nodes are visited in order, so the degree of a node is
+	// known once the nodes after it are visited. This keeps the memory used
+	// independent of the number of nodes
+	degrees := map[int]*[2]int{}
+	outs, ins := map[int]int{}, map[int]int{}
+	counted := 0
+	count := func(node int) {
+		outs[degrees[node][0]]++
+		ins[degrees[node][1]]++
+		delete(degrees, node)
+		counted++
+	}
+	add := func(from, to int) {
+		for _, node := range []int{from, to} {
+			if degrees[node] == nil {
+				degrees[node] = &[2]int{}
+			}
+		}
+		degrees[from][0]++
+		degrees[to][1]++
+	}
+
+	cursor := e.newCursor()
+	block := 0
+	for created := 0; created < e.created; created++ {
+		from, rank := cursor.parents.child(created)
+		if b := from / ChunkSize; b != block {
+			for node := range degrees {
+				if node < b*ChunkSize && node >= e.span {
+					count(node)
+				}
+			}
+			block = b
+		}
+		to := e.target(from, rank)
+		add(from, to)
+		if e.isReciprocal(created) {
+			add(to, from)
+		}
+	}
+	for node := range degrees {
+		count(node)
+	}
+	// nodes without edges
+	outs[0] += e.nbNodes - counted
+	ins[0] += e.nbNodes - counted
+
+	return GraphStats{
+		Nodes:       e.nbNodes,
+		Edges:       e.count,
+		Reciprocity: float64(2*e.reverse) / float64(e.count),
+		OutDegree:   newDegreeStats(outs, e.nbNodes, e.count),
+		InDegree:    newDegreeStats(ins, e.nbNodes, e.count),
+	}
+}
+
+// newDegreeStats returns the statistics of the degrees of nbNodes nodes,
+// histogram holding the number of nodes of each degree
+func newDegreeStats(histogram map[int]int, nbNodes, nbEdges int) DegreeStats {
+	degrees := make([]int, 0, len(histogram))
+	for d, n := range histogram {
+		if n > 0 {
+			degrees = append(degrees, d)
+		}
+	}
+	sort.Ints(degrees)
+
+	s := DegreeStats{
+		Min:    degrees[0],
+		Median: -1,
+		P99:    -1,
+		Max:    degrees[len(degrees)-1],
+		Mean:   float64(nbEdges) / float64(nbNodes),
+	}
+	seen := 0
+	for _, d := range degrees {
+		seen += histogram[d]
+		if s.Median == -1 && 2*seen >= nbNodes {
+			s.Median = d
+		}
+		if s.P99 == -1 && 100*seen >= 99*nbNodes {
+			s.P99 = d
+		}
+	}
+	return s
+}
@@ -10,14 +10,14 @@ import (
 
 func Example() {
 
-	var content = map[string]generators.Config{
-		"key": {
+	var content = generators.Content{
+		{Name: "key", Config: generators.Config{
 			Type:      generators.TypeString,
 			MinLength: "3",
 			MaxLength: "5",
-		},
+		}},
 	}
-	collInfo := generators.NewCollInfo(1, nil, 1, nil, nil)
+	collInfo := generators.NewCollInfo(1, nil, 1, nil)
 	docGenerator, err := collInfo.NewDocumentGenerator(content)
 	if err != nil {
 		log.Fatal(err)
@@ -31,6 +31,6 @@ func Example() {
 		log.Fatal(err)
 	}
 	fmt.Printf("%+v", doc)
-	// Output: {Key:1jUK}
+	// Output: {Key:PFAvu}
 
 }
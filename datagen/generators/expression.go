@@ -0,0 +1,686 @@
+package generators
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// An expression computes a value from the fields already generated in the current
+// document, for example:
+//
+//	price * quantity
+//	dateAdd(startDate, duration, 'day')
+//	concat(lower(firstName), '.', lower(lastName))
+//	firstName | lower
+//
+// The pipe operator passes a value as first argument of a function, so
+// 'x | pad(5)' is the same as 'pad(x, 5)'.
+//
+// Values are int32, int64, float64, string, bool, time.Time or nil for null.
+// Any operation on null or on values of unexpected types returns null
+type expression struct {
+	root node
+	// paths of the fields used by the expression
+	fields [][]string
+	// names of the generators used by the expression, like '$name'
+	variables []string
+}
+
+type node interface {
+	eval(ctx *evalContext) any
+}
+
+type evalContext struct {
+	// elements of the document being generated
+	doc []byte
+	now time.Time
+	// values of the generators available to the expression
+	variables map[string]*variable
+}
+
+type (
+	literal  struct{ value any }
+	fieldRef struct{ path []string }
+	// reference to a generator, like '$name'
+	variableRef struct{ name string }
+	unary       struct {
+		op string
+		x  node
+	}
+	binary struct {
+		op   string
+		x, y node
+	}
+	call struct {
+		fn   *function
+		args []node
+	}
+)
+
+// parseExpression parses src. It returns an error with the position
+// of the first invalid token if src isn't a valid expression
+func parseExpression(src string) (*expression, error) {
+	p := &parser{lexer: lexer{src: src}}
+	p.next()
+	root, err := p.parsePipe()
+	if err == nil && p.tok.kind != tokenEOF {
+		err = p.errorf("unexpected '%s'", p.tok.text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &expression{root: root, fields: p.fields, variables: p.variables}, nil
+}
+
+// eval returns the value of the expression for the document in buffer
+func (e *expression) eval(buffer *DocBuffer, now time.Time) any {
+	doc := buffer.Bytes()
+	if len(doc) < 4 {
+		return nil
+	}
+	return e.root.eval(&evalContext{doc: doc[4:], now: now})
+}
+
+const (
+	tokenEOF = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+
+	for l.pos < len(l.src) && unicode.IsSpace(rune(l.src[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokenEOF, pos: start}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+
+	case c >= '0' && c <= '9' || c == '.' && l.pos+1 < len(l.src) && l.src[l.pos+1] >= '0' && l.src[l.pos+1] <= '9':
+		for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+			l.pos++
+		}
+		// exponent, like 1e6 or 2.5E-3
+		if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+			l.pos++
+			if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+				l.pos++
+			}
+			for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+				l.pos++
+			}
+		}
+		return token{kind: tokenNumber, text: l.src[start:l.pos], pos: start}, nil
+
+	case c == '\'' || c == '"':
+		var sb strings.Builder
+		l.pos++
+		for l.pos < len(l.src) && l.src[l.pos] != c {
+			if l.src[l.pos] == '\\' && l.pos+1 < len(l.src) {
+				l.pos++
+			}
+			sb.WriteByte(l.src[l.pos])
+			l.pos++
+		}
+		if l.pos >= len(l.src) {
+			return token{}, fmt.Errorf("unterminated string at position %d", start)
+		}
+		l.pos++
+		return token{kind: tokenString, text: sb.String(), pos: start}, nil
+
+	case isIdentStart(c):
+		for l.pos < len(l.src) && (isIdentStart(l.src[l.pos]) || isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+			l.pos++
+		}
+		return token{kind: tokenIdent, text: l.src[start:l.pos], pos: start}, nil
+	}
+
+	for _, op := range []string{"==", "!=", "<=", ">=", "&&", "||", "|", "+", "-", "*", "/", "%", "<", ">", "!", "(", ")", ","} {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokenOperator, text: op, pos: start}, nil
+		}
+	}
+	return token{}, fmt.Errorf("unexpected character '%c' at position %d", c, start)
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$'
+}
+
+type parser struct {
+	lexer     lexer
+	tok       token
+	err       error
+	fields    [][]string
+	variables []string
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.err = p.lexer.next()
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	if p.err != nil {
+		return p.err
+	}
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, args...), p.tok.pos)
+}
+
+// precedence of binary operators, higher binds tighter
+var precedences = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+// parsePipe parses 'x | f(args)' as 'f(x, args)'. The pipe has the lowest precedence
+func (p *parser) parsePipe() (node, error) {
+	x, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokenOperator && p.tok.text == "|" {
+		p.next()
+		name := p.tok
+		if p.err != nil {
+			return nil, p.err
+		}
+		if name.kind != tokenIdent {
+			return nil, p.errorf("expected a function after '|'")
+		}
+		p.next()
+		if x, err = p.parseCall(name, x); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+func (p *parser) parseBinary(minPrecedence int) (node, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		prec, ok := precedences[p.tok.text]
+		if p.tok.kind != tokenOperator || !ok || prec <= minPrecedence {
+			return x, nil
+		}
+		op := p.tok.text
+		p.next()
+		y, err := p.parseBinary(prec)
+		if err != nil {
+			return nil, err
+		}
+		x = &binary{op: op, x: x, y: y}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.tok.kind == tokenOperator && (p.tok.text == "-" || p.tok.text == "!") {
+		op := p.tok.text
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: op, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+
+	if p.err != nil {
+		return nil, p.err
+	}
+	tok := p.tok
+
+	switch tok.kind {
+
+	case tokenNumber:
+		p.next()
+		if i, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			if i >= math.MinInt32 && i <= math.MaxInt32 {
+				return &literal{value: int32(i)}, nil
+			}
+			return &literal{value: i}, nil
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s' at position %d", tok.text, tok.pos)
+		}
+		return &literal{value: f}, nil
+
+	case tokenString:
+		p.next()
+		return &literal{value: tok.text}, nil
+
+	case tokenIdent:
+		p.next()
+		if p.tok.kind == tokenOperator && p.tok.text == "(" {
+			p.next()
+			return p.parseCall(tok, nil)
+		}
+		switch tok.text {
+		case "true":
+			return &literal{value: true}, nil
+		case "false":
+			return &literal{value: false}, nil
+		case "null":
+			return &literal{value: nil}, nil
+		}
+		if strings.HasPrefix(tok.text, "$") {
+			return p.newVariableRef(tok.text, tok.pos)
+		}
+		return p.newFieldRef(tok.text, tok.pos)
+
+	case tokenOperator:
+		if tok.text == "(" {
+			p.next()
+			x, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokenOperator || p.tok.text != ")" {
+				return nil, p.errorf("expected ')'")
+			}
+			p.next()
+			return x, nil
+		}
+	case tokenEOF:
+		return nil, p.errorf("unexpected end of expression")
+	}
+	return nil, p.errorf("unexpected '%s'", tok.text)
+}
+
+func (p *parser) newFieldRef(path string, pos int) (node, error) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("invalid field '%s' at position %d", path, pos)
+		}
+	}
+	p.fields = append(p.fields, parts)
+	return &fieldRef{path: parts}, nil
+}
+
+func (p *parser) newVariableRef(name string, pos int) (node, error) {
+	name = strings.TrimPrefix(name, "$")
+	if name == "" || strings.Contains(name, ".") {
+		return nil, fmt.Errorf("invalid generator '$%s' at position %d", name, pos)
+	}
+	p.variables = append(p.variables, name)
+	return &variableRef{name: name}, nil
+}
+
+// parseCall parses the arguments of the function name. The opening parenthesis
+// has already been read. piped is the value passed with '|', if any, and is
+// the first argument of the function. With a pipe, the parentheses are optional
+func (p *parser) parseCall(name token, piped node) (node, error) {
+
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function '%s' at position %d", name.text, name.pos)
+	}
+
+	var args []node
+	if piped != nil {
+		args = append(args, piped)
+	}
+	if piped == nil || p.tok.kind == tokenOperator && p.tok.text == "(" {
+		if piped != nil {
+			p.next()
+		}
+		start := len(args)
+		for p.tok.kind != tokenOperator || p.tok.text != ")" {
+			if len(args) > start {
+				if p.tok.kind != tokenOperator || p.tok.text != "," {
+					return nil, p.errorf("expected ',' or ')'")
+				}
+				p.next()
+			}
+			arg, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		p.next()
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments for function '%s' at position %d: %s", name.text, name.pos, fn.usage)
+	}
+	// field() gives access to fields whose name isn't a valid identifier,
+	// the path has to be known to check the dependencies of the expression
+	if name.text == "field" {
+		lit, ok := args[0].(*literal)
+		path, isString := "", false
+		if ok {
+			path, isString = lit.value.(string)
+		}
+		if !isString {
+			return nil, fmt.Errorf("argument of 'field' has to be a string at position %d", name.pos)
+		}
+		return p.newFieldRef(path, name.pos)
+	}
+	return &call{fn: fn, args: args}, nil
+}
+
+func (n *literal) eval(*evalContext) any { return n.value }
+
+func (n *fieldRef) eval(ctx *evalContext) any {
+	v, found := lookupGenerated(ctx.doc, n.path)
+	if !found {
+		return nil
+	}
+	return goValue(v)
+}
+
+// goValue returns v as a value of an expression, or nil if
+// its type isn't supported
+func goValue(v bson.RawValue) any {
+	switch v.Type {
+	case bson.TypeInt32:
+		return v.Int32()
+	case bson.TypeInt64:
+		return v.Int64()
+	case bson.TypeDouble, bson.TypeDecimal128:
+		return numberAsFloat(v)
+	case bson.TypeString, bson.TypeSymbol:
+		return stringOf(v)
+	case bson.TypeBoolean:
+		return v.Boolean()
+	case bson.TypeDateTime:
+		return time.UnixMilli(v.DateTime()).UTC()
+	case bson.TypeObjectID:
+		return v.ObjectID().Hex()
+	}
+	return nil
+}
+
+func (n *variableRef) eval(ctx *evalContext) any {
+	v, ok := ctx.variables[n.name]
+	if !ok {
+		return nil
+	}
+	return v.get()
+}
+
+func (n *unary) eval(ctx *evalContext) any {
+	x := n.x.eval(ctx)
+	if n.op == "!" {
+		if b, ok := x.(bool); ok {
+			return !b
+		}
+		return nil
+	}
+	switch x := x.(type) {
+	case int32:
+		if x == math.MinInt32 {
+			return -int64(x)
+		}
+		return -x
+	case int64:
+		return -x
+	case float64:
+		return -x
+	}
+	return nil
+}
+
+func (n *binary) eval(ctx *evalContext) any {
+	x, y := n.x.eval(ctx), n.y.eval(ctx)
+
+	switch n.op {
+	case "&&", "||":
+		bx, okx := x.(bool)
+		by, oky := y.(bool)
+		if !okx || !oky {
+			return nil
+		}
+		if n.op == "&&" {
+			return bx && by
+		}
+		return bx || by
+	case "==":
+		return equalValues(x, y)
+	case "!=":
+		return !equalValues(x, y)
+	case "<", "<=", ">", ">=":
+		cmp, ok := compareGoValues(x, y)
+		if !ok {
+			return nil
+		}
+		switch n.op {
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		case ">":
+			return cmp > 0
+		}
+		return cmp >= 0
+	}
+	return arithmetic(n.op, x, y)
+}
+
+// arithmetic applies op to x and y. Like in MongoDB, a number added to or
+// subtracted from a date is a number of milliseconds, and the difference
+// between two dates is a number of milliseconds
+func arithmetic(op string, x, y any) any {
+
+	if x == nil || y == nil {
+		return nil
+	}
+	if sx, ok := x.(string); ok && op == "+" {
+		if sy, ok := y.(string); ok {
+			return sx + sy
+		}
+		return nil
+	}
+
+	tx, xIsDate := x.(time.Time)
+	ty, yIsDate := y.(time.Time)
+	switch {
+	case xIsDate && yIsDate:
+		if op != "-" {
+			return nil
+		}
+		return tx.Sub(ty).Milliseconds()
+	case xIsDate || yIsDate:
+		t, n := tx, y
+		if yIsDate {
+			t, n = ty, x
+		}
+		ms, ok := toInt64(n)
+		if !ok || (op != "+" && op != "-") || (op == "-" && yIsDate) {
+			return nil
+		}
+		if op == "-" {
+			ms = -ms
+		}
+		return t.Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	if !isNumber(x) || !isNumber(y) {
+		return nil
+	}
+	_, xIsFloat := x.(float64)
+	_, yIsFloat := y.(float64)
+	if xIsFloat || yIsFloat || op == "/" {
+		fx, fy := toFloat64(x), toFloat64(y)
+		switch op {
+		case "+":
+			return fx + fy
+		case "-":
+			return fx - fy
+		case "*":
+			return fx * fy
+		case "/":
+			if fy == 0 {
+				return nil
+			}
+			return fx / fy
+		}
+		if fy == 0 {
+			return nil
+		}
+		return math.Mod(fx, fy)
+	}
+
+	ix, _ := toInt64(x)
+	iy, _ := toInt64(y)
+	var r int64
+	switch op {
+	case "+":
+		r = ix + iy
+	case "-":
+		r = ix - iy
+	case "*":
+		r = ix * iy
+	case "%":
+		if iy == 0 {
+			return nil
+		}
+		r = ix % iy
+	}
+	// like in MongoDB, the result of two int32 is an int32 if it fits
+	_, xIsInt32 := x.(int32)
+	_, yIsInt32 := y.(int32)
+	if xIsInt32 && yIsInt32 && r >= math.MinInt32 && r <= math.MaxInt32 {
+		return int32(r)
+	}
+	return r
+}
+
+func isNumber(v any) bool {
+	switch v.(type) {
+	case int32, int64, float64:
+		return true
+	}
+	return false
+}
+
+func toInt64(v any) (int64, bool) {
+	switch v := v.(type) {
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func toFloat64(v any) float64 {
+	switch v := v.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+func equalValues(x, y any) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	cmp, ok := compareGoValues(x, y)
+	return ok && cmp == 0
+}
+
+// compareGoValues compares two values of the same type, numbers
+// of different types being compared by value
+func compareGoValues(x, y any) (int, bool) {
+	switch {
+	case isNumber(x) && isNumber(y):
+		fx, fy := toFloat64(x), toFloat64(y)
+		switch {
+		case fx < fy:
+			return -1, true
+		case fx > fy:
+			return 1, true
+		}
+		return 0, fx == fy
+	}
+	switch x := x.(type) {
+	case string:
+		if y, ok := y.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case bool:
+		if y, ok := y.(bool); ok {
+			return compareInts(boolAsInt(x), boolAsInt(y)), true
+		}
+	case time.Time:
+		if y, ok := y.(time.Time); ok {
+			return compareInts(x.UnixNano(), y.UnixNano()), true
+		}
+	}
+	return 0, false
+}
+
+func (n *call) eval(ctx *evalContext) any {
+	args := make([]any, len(n.args))
+	for i, a := range n.args {
+		args[i] = a.eval(ctx)
+	}
+	return n.fn.eval(ctx, args)
+}
+
+// formatValue returns the string representation of a value
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+	}
+	return v.(string)
+}
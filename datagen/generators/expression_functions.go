@@ -0,0 +1,425 @@
+package generators
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// function is a function available in expressions. Arguments are evaluated
+// before calling eval. maxArgs is -1 for functions with a variable number of
+// arguments
+type function struct {
+	minArgs int
+	maxArgs int
+	usage   string
+	eval    func(ctx *evalContext, args []any) any
+}
+
+// available units for date functions
+var dateUnits = map[string]time.Duration{
+	"millisecond": time.Millisecond,
+	"second":      time.Second,
+	"minute":      time.Minute,
+	"hour":        time.Hour,
+	"day":         24 * time.Hour,
+	"week":        7 * 24 * time.Hour,
+	// months and years don't have a fixed duration
+	"month": 0,
+	"year":  0,
+}
+
+var functions map[string]*function
+
+func init() {
+	functions = map[string]*function{
+
+		// conditionals
+		"if": {3, 3, "if(condition, valueIfTrue, valueIfFalse)", func(_ *evalContext, args []any) any {
+			if b, ok := args[0].(bool); ok && b {
+				return args[1]
+			}
+			return args[2]
+		}},
+		"ifNull": {2, 2, "ifNull(value, replacement)", func(_ *evalContext, args []any) any {
+			if args[0] == nil {
+				return args[1]
+			}
+			return args[0]
+		}},
+		"field": {1, 1, "field('name')", nil},
+
+		// math
+		"abs": {1, 1, "abs(number)", func(_ *evalContext, args []any) any {
+			switch x := args[0].(type) {
+			case int32, int64:
+				if n, _ := toInt64(x); n < 0 {
+					return arithmetic("*", x, int32(-1))
+				}
+				return x
+			case float64:
+				return math.Abs(x)
+			}
+			return nil
+		}},
+		"floor": {1, 1, "floor(number)", roundingFunction(math.Floor)},
+		"ceil":  {1, 1, "ceil(number)", roundingFunction(math.Ceil)},
+		"round": {1, 2, "round(number, digits)", func(_ *evalContext, args []any) any {
+			digits := int64(0)
+			if len(args) == 2 {
+				var ok bool
+				if digits, ok = toInt64(args[1]); !ok {
+					return nil
+				}
+			}
+			if !isNumber(args[0]) {
+				return nil
+			}
+			if _, ok := args[0].(float64); !ok && digits >= 0 {
+				return args[0]
+			}
+			pow := math.Pow(10, float64(digits))
+			return math.Round(toFloat64(args[0])*pow) / pow
+		}},
+		"pow": {2, 2, "pow(number, exponent)", func(_ *evalContext, args []any) any {
+			if !isNumber(args[0]) || !isNumber(args[1]) {
+				return nil
+			}
+			return math.Pow(toFloat64(args[0]), toFloat64(args[1]))
+		}},
+		"sqrt": {1, 1, "sqrt(number)", func(_ *evalContext, args []any) any {
+			if !isNumber(args[0]) || toFloat64(args[0]) < 0 {
+				return nil
+			}
+			return math.Sqrt(toFloat64(args[0]))
+		}},
+		"min": {1, -1, "min(value, ...)", func(_ *evalContext, args []any) any { return extremum(args, -1) }},
+		"max": {1, -1, "max(value, ...)", func(_ *evalContext, args []any) any { return extremum(args, 1) }},
+
+		// strings
+		"concat": {1, -1, "concat(value, ...)", func(_ *evalContext, args []any) any {
+			var sb strings.Builder
+			for _, a := range args {
+				if a == nil {
+					return nil
+				}
+				sb.WriteString(formatValue(a))
+			}
+			return sb.String()
+		}},
+		"lower": {1, 1, "lower(string)", stringFunction(strings.ToLower)},
+		"upper": {1, 1, "upper(string)", stringFunction(strings.ToUpper)},
+		"trim":  {1, 1, "trim(string)", stringFunction(strings.TrimSpace)},
+		"capitalize": {1, 1, "capitalize(string)", stringFunction(func(s string) string {
+			r, size := utf8.DecodeRuneInString(s)
+			return string(unicode.ToUpper(r)) + s[size:]
+		})},
+		"slug": {1, 1, "slug(string)", stringFunction(slug)},
+		"pad": {2, 3, "pad(value, length, char)", func(_ *evalContext, args []any) any {
+			return pad(args, true)
+		}},
+		"padRight": {2, 3, "padRight(value, length, char)", func(_ *evalContext, args []any) any {
+			return pad(args, false)
+		}},
+		"length": {1, 1, "length(string)", func(_ *evalContext, args []any) any {
+			if s, ok := args[0].(string); ok {
+				return int32(utf8.RuneCountInString(s))
+			}
+			return nil
+		}},
+		"substr": {2, 3, "substr(string, start, length)", func(_ *evalContext, args []any) any {
+			s, ok := args[0].(string)
+			start, okStart := toInt64(args[1])
+			if !ok || !okStart || start < 0 {
+				return nil
+			}
+			runes := []rune(s)
+			if start > int64(len(runes)) {
+				start = int64(len(runes))
+			}
+			end := int64(len(runes))
+			if len(args) == 3 {
+				length, ok := toInt64(args[2])
+				if !ok || length < 0 {
+					return nil
+				}
+				// compare with the remaining length, as start+length can overflow
+				if length < end-start {
+					end = start + length
+				}
+			}
+			return string(runes[start:end])
+		}},
+		"replace": {3, 3, "replace(string, old, new)", func(_ *evalContext, args []any) any {
+			s, ok1 := args[0].(string)
+			old, ok2 := args[1].(string)
+			new, ok3 := args[2].(string)
+			if !ok1 || !ok2 || !ok3 {
+				return nil
+			}
+			return strings.ReplaceAll(s, old, new)
+		}},
+
+		// type conversions
+		"toString": {1, 1, "toString(value)", func(_ *evalContext, args []any) any {
+			if args[0] == nil {
+				return nil
+			}
+			return formatValue(args[0])
+		}},
+		"toInt": {1, 1, "toInt(value)", func(_ *evalContext, args []any) any {
+			n, ok := toInteger(args[0])
+			if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+				return nil
+			}
+			return int32(n)
+		}},
+		"toLong": {1, 1, "toLong(value)", func(_ *evalContext, args []any) any {
+			if n, ok := toInteger(args[0]); ok {
+				return n
+			}
+			return nil
+		}},
+		"toDouble": {1, 1, "toDouble(value)", func(_ *evalContext, args []any) any {
+			switch x := args[0].(type) {
+			case int32, int64, float64:
+				return toFloat64(x)
+			case bool:
+				return float64(boolAsInt(x))
+			case string:
+				if f, err := strconv.ParseFloat(strings.TrimSpace(x), 64); err == nil {
+					return f
+				}
+			case time.Time:
+				return float64(x.UnixMilli())
+			}
+			return nil
+		}},
+		"toBool": {1, 1, "toBool(value)", func(_ *evalContext, args []any) any {
+			switch x := args[0].(type) {
+			case bool:
+				return x
+			case int32, int64, float64:
+				return toFloat64(x) != 0
+			case string:
+				if b, err := strconv.ParseBool(x); err == nil {
+					return b
+				}
+			}
+			return nil
+		}},
+		"toDate": {1, 1, "toDate(value)", func(_ *evalContext, args []any) any {
+			switch x := args[0].(type) {
+			case time.Time:
+				return x
+			case int32, int64, float64:
+				ms, _ := toInt64(x)
+				return time.UnixMilli(ms).UTC()
+			case string:
+				for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+					if t, err := time.Parse(layout, x); err == nil {
+						return t.UTC()
+					}
+				}
+			}
+			return nil
+		}},
+
+		// dates
+		"now": {0, 0, "now()", func(ctx *evalContext, _ []any) any {
+			return ctx.now
+		}},
+		"dateAdd": {3, 3, "dateAdd(date, amount, unit)", func(_ *evalContext, args []any) any {
+			t, ok1 := args[0].(time.Time)
+			amount, ok2 := toInt64(args[1])
+			unit, ok3 := args[2].(string)
+			if !ok1 || !ok2 || !ok3 {
+				return nil
+			}
+			switch unit {
+			case "month":
+				return t.AddDate(0, int(amount), 0)
+			case "year":
+				return t.AddDate(int(amount), 0, 0)
+			}
+			d, ok := dateUnits[unit]
+			if !ok {
+				return nil
+			}
+			return t.Add(time.Duration(amount) * d)
+		}},
+		"dateDiff": {3, 3, "dateDiff(startDate, endDate, unit)", func(_ *evalContext, args []any) any {
+			start, ok1 := args[0].(time.Time)
+			end, ok2 := args[1].(time.Time)
+			unit, ok3 := args[2].(string)
+			if _, ok := dateUnits[unit]; !ok1 || !ok2 || !ok3 || !ok {
+				return nil
+			}
+			return dateDiff(start, end, unit)
+		}},
+		"year":  {1, 1, "year(date)", datePartFunction(func(t time.Time) int { return t.Year() })},
+		"month": {1, 1, "month(date)", datePartFunction(func(t time.Time) int { return int(t.Month()) })},
+		"day":   {1, 1, "day(date)", datePartFunction(func(t time.Time) int { return t.Day() })},
+		"hour":  {1, 1, "hour(date)", datePartFunction(func(t time.Time) int { return t.Hour() })},
+		"dayOfWeek": {1, 1, "dayOfWeek(date)", datePartFunction(func(t time.Time) int {
+			// like in MongoDB, from 1 (Sunday) to 7 (Saturday)
+			return int(t.Weekday()) + 1
+		})},
+	}
+}
+
+func roundingFunction(f func(float64) float64) func(*evalContext, []any) any {
+	return func(_ *evalContext, args []any) any {
+		switch x := args[0].(type) {
+		case int32, int64:
+			return x
+		case float64:
+			return f(x)
+		}
+		return nil
+	}
+}
+
+func stringFunction(f func(string) string) func(*evalContext, []any) any {
+	return func(_ *evalContext, args []any) any {
+		if s, ok := args[0].(string); ok {
+			return f(s)
+		}
+		return nil
+	}
+}
+
+// slug returns s in lower case, without accents and with only letters and digits
+// separated by a single '-', so 'Hello, Wörld!' becomes 'hello-world'
+func slug(s string) string {
+	var sb strings.Builder
+	dash := false
+	// decompose accented letters, so the accents can be removed
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			dash = sb.Len() > 0
+			continue
+		}
+		if dash {
+			sb.WriteByte('-')
+			dash = false
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+// maxPadLength is the maximum length of a string padded by pad() or padRight(),
+// so a large length can't use all the memory
+const maxPadLength = 1 << 20
+
+// pad returns the first argument as a string of at least the length given by the
+// second argument, padded with spaces or with the character given by the third
+// argument, on the left if left is true, or on the right otherwise. The result is
+// null if the length is greater than maxPadLength
+func pad(args []any, left bool) any {
+	if args[0] == nil {
+		return nil
+	}
+	length, ok := toInt64(args[1])
+	if !ok || length > maxPadLength {
+		return nil
+	}
+	char := " "
+	if len(args) == 3 {
+		c, ok := args[2].(string)
+		if !ok || utf8.RuneCountInString(c) != 1 {
+			return nil
+		}
+		char = c
+	}
+	s := formatValue(args[0])
+	n := int(length) - utf8.RuneCountInString(s)
+	if n <= 0 {
+		return s
+	}
+	if left {
+		return strings.Repeat(char, n) + s
+	}
+	return s + strings.Repeat(char, n)
+}
+
+func datePartFunction(f func(time.Time) int) func(*evalContext, []any) any {
+	return func(_ *evalContext, args []any) any {
+		if t, ok := args[0].(time.Time); ok {
+			return int32(f(t.UTC()))
+		}
+		return nil
+	}
+}
+
+// extremum returns the smallest value of args if sign is -1, or
+// the greatest one if sign is 1. Null values are ignored
+func extremum(args []any, sign int) any {
+	var result any
+	for _, a := range args {
+		if a == nil {
+			continue
+		}
+		if result == nil {
+			result = a
+			continue
+		}
+		cmp, ok := compareGoValues(a, result)
+		if !ok {
+			return nil
+		}
+		if cmp == sign {
+			result = a
+		}
+	}
+	return result
+}
+
+// toInteger converts v to an integer, truncating doubles
+func toInteger(v any) (int64, bool) {
+	switch x := v.(type) {
+	case int32, int64:
+		return toInt64(x)
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) || x < math.MinInt64 || x >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(x), true
+	case bool:
+		return boolAsInt(x), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(x), 10, 64)
+		return n, err == nil
+	case time.Time:
+		return x.UnixMilli(), true
+	}
+	return 0, false
+}
+
+// dateDiff returns the number of whole units elapsed between start and end,
+// so the age of someone born at start is dateDiff(start, now, 'year')
+func dateDiff(start, end time.Time, unit string) int64 {
+	if end.Before(start) {
+		return -dateDiff(end, start, unit)
+	}
+	if d := dateUnits[unit]; d != 0 {
+		return int64(end.Sub(start) / d)
+	}
+	months := (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month())
+	if start.AddDate(0, months, 0).After(end) {
+		months--
+	}
+	if unit == "year" {
+		return int64(months / 12)
+	}
+	return int64(months)
+}
@@ -0,0 +1,158 @@
+package generators
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// defaultReferenceDate is the date returned by now() without 'referenceDate'. It's
+// fixed, so the documents only depend on the seed, and not on the day they're generated
+var defaultReferenceDate = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// referenceDate returns the date returned by now() for the generator of config
+func referenceDate(config *Config) time.Time {
+	if config.ReferenceDate.IsZero() {
+		return defaultReferenceDate
+	}
+	return config.ReferenceDate.UTC()
+}
+
+// Generator for creating values computed from the fields generated before
+// in the document. The bson type of the value depends on the result of the
+// expression, and the value is null if the expression can't be computed
+type expressionGenerator struct {
+	base
+	expression *expression
+	// reference date returned by now()
+	now time.Time
+	// value computed for the current document
+	current any
+}
+
+func newExpressionGenerator(config *Config, base base, ci *CollInfo, path string) (Generator, error) {
+	if config.Expression == "" {
+		return nil, fmt.Errorf("'expression' can't be null or empty")
+	}
+	expr, err := parseExpression(config.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression '%s': %v", config.Expression, err)
+	}
+	if len(expr.variables) > 0 {
+		return nil, fmt.Errorf("generator '$%s' can only be used in a template", expr.variables[0])
+	}
+	for _, field := range expr.fields {
+		if err := ci.checkGeneratedBefore(field, path, "expression"); err != nil {
+			return nil, err
+		}
+	}
+	return &expressionGenerator{
+		base:       base,
+		expression: expr,
+		now:        referenceDate(config),
+	}, nil
+}
+
+// Exists computes the value of the expression, so its type is known
+// before the value is written
+func (g *expressionGenerator) Exists() bool {
+	if !g.base.Exists() {
+		return false
+	}
+	if !g.null {
+		g.current = g.expression.eval(g.buffer, g.now)
+		g.null = g.current == nil
+	}
+	return true
+}
+
+func (g *expressionGenerator) Type() bsontype.Type {
+	switch g.current.(type) {
+	case int32:
+		return bson.TypeInt32
+	case int64:
+		return bson.TypeInt64
+	case float64:
+		return bson.TypeDouble
+	case string:
+		return bson.TypeString
+	case bool:
+		return bson.TypeBoolean
+	case time.Time:
+		return bson.TypeDateTime
+	}
+	return bson.TypeNull
+}
+
+func (g *expressionGenerator) EncodeValue() {
+	switch v := g.current.(type) {
+	case int32:
+		g.buffer.Write(int32Bytes(v))
+	case int64:
+		g.buffer.Write(int64Bytes(v))
+	case float64:
+		g.buffer.Write(float64Bytes(v))
+	case string:
+		g.buffer.Write(int32Bytes(int32(len(v) + 1)))
+		g.buffer.WriteString(v)
+		g.buffer.WriteSingleByte(byte(0))
+	case bool:
+		if v {
+			g.buffer.WriteSingleByte(byte(1))
+		} else {
+			g.buffer.WriteSingleByte(byte(0))
+		}
+	case time.Time:
+		g.buffer.Write(int64Bytes(v.UnixMilli()))
+	}
+}
+
+func (g *expressionGenerator) EncodeValueAsString() {
+	g.buffer.WriteString(formatValue(g.expression.eval(g.buffer, g.now)))
+}
+
+// checkGeneratedBefore returns an error if the field at path, used by the generator
+// of the field at generatorPath, isn't generated before it in the document. kind is
+// the name of what uses the field in the error
+func (ci *CollInfo) checkGeneratedBefore(path []string, generatorPath, kind string) error {
+
+	name := strings.Join(path, ".")
+	for _, p := range ci.generatedBefore {
+		if p == path[0] {
+			return nil
+		}
+	}
+	// the field is in the same top level field as the generator, so it has to be
+	// one of the nested fields already created. Elements of arrays share the path
+	// of the content of the array
+	generatorPath = strings.TrimPrefix(generatorPath, ci.pathPrefix)
+	current := strings.Split(generatorPath, ".")
+	if path[0] == current[0] {
+		normalized := make([]string, len(path))
+		for i, p := range path {
+			if isIndex(p) {
+				p = "$"
+			}
+			normalized[i] = p
+		}
+		if strings.Join(normalized, ".") == generatorPath {
+			return fmt.Errorf("%s can't use its own field '%s'", kind, name)
+		}
+		if ci.created[strings.Join(normalized, ".")] {
+			return nil
+		}
+	}
+	return fmt.Errorf("field '%s' used by %s has to be declared before the %s", name, kind, kind)
+}
+
+func isIndex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return s != ""
+}
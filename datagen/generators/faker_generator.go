@@ -1,31 +1,51 @@
 package generators
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/MichaelTJones/pcg"
+	"github.com/brianvoe/gofakeit/v6"
+)
 
 // Generator for creating random string using faker library
 type fakerGenerator struct {
 	base
-	f func() string
+	faker *gofakeit.Faker
+	f     func(*gofakeit.Faker) string
 }
 
-func newFakerGenerator(config *Config, base base) (Generator, error) {
+func newFakerGenerator(config *Config, base base, pcg64 *pcg.PCG64) (Generator, error) {
 	method, ok := fakerMethods[config.Method]
 	if !ok {
 		return nil, fmt.Errorf("invalid Faker method '%s'", config.Method)
 	}
 	return &fakerGenerator{
-		base: base,
-		f:    method,
+		base:  base,
+		faker: gofakeit.NewCustom(&pcgSource{pcg64: pcg64}),
+		f:     method,
 	}, nil
 }
 
 func (g *fakerGenerator) EncodeValue() {
-	fakerVal := []byte(g.f())
+	fakerVal := []byte(g.f(g.faker))
 	g.buffer.Write(int32Bytes(int32(len(fakerVal) + 1)))
 	g.buffer.Write(fakerVal)
 	g.buffer.WriteSingleByte(byte(0))
 }
 
 func (g *fakerGenerator) EncodeValueAsString() {
-	g.buffer.WriteString(g.f())
+	g.buffer.WriteString(g.f(g.faker))
+}
+
+// pcgSource implements rand.Source64 on top of a PCG64, so that
+// gofakeit values can be reproduced from the seed
+type pcgSource struct {
+	pcg64 *pcg.PCG64
 }
+
+func (s *pcgSource) Uint64() uint64 { return s.pcg64.Random() }
+
+func (s *pcgSource) Int63() int64 { return int64(s.pcg64.Random() >> 1) }
+
+// Seed is a no-op: the underlying PCG64 is seeded by CollInfo
+func (s *pcgSource) Seed(int64) {}
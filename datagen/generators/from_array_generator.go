@@ -3,17 +3,23 @@ package generators
 import (
 	"errors"
 	"fmt"
+	"math"
+	"sort"
+
+	"github.com/MichaelTJones/pcg"
 )
 
 // Generator for creating a random value from an array of user-defined values
 type fromArrayGenerator struct {
 	base
-	size          int
-	bsonArray     [][]byte
-	strArray      [][]byte
-	index         int
-	randomOrder   bool
-	doNotTruncate bool
+	size        int
+	bsonArray   [][]byte
+	strArray    [][]byte
+	index       int
+	randomOrder bool
+	// cumulated weights of the values, if
+	// values have different probabilities
+	cumulatedWeights []float64
 }
 
 func newFromArrayGenerator(config *Config, base base) (Generator, error) {
@@ -39,24 +45,53 @@ func newFromArrayGenerator(config *Config, base base) (Generator, error) {
 		array[i] = raw
 		arrayStr[i] = []byte(fmt.Sprint(v))
 	}
+	cumulatedWeights, err := cumulateWeights(config.Weights, size)
+	if err != nil {
+		return nil, err
+	}
 	return &fromArrayGenerator{
-		base:        base,
-		bsonArray:   array,
-		strArray:    arrayStr,
-		size:        size,
-		index:       0,
-		randomOrder: config.RandomOrder,
+		base:             base,
+		bsonArray:        array,
+		strArray:         arrayStr,
+		size:             size,
+		index:            0,
+		randomOrder:      config.RandomOrder,
+		cumulatedWeights: cumulatedWeights,
 	}, nil
 }
 
-func newFromArrayGeneratorWithPregeneratedValues(base base, values [][]byte, doNotTruncate bool) (Generator, error) {
-	return &fromArrayGenerator{
-		base:          base,
-		bsonArray:     values,
-		size:          len(values),
-		index:         0,
-		doNotTruncate: doNotTruncate,
-	}, nil
+// cumulateWeights returns the running sum of weights, or nil if
+// there are no weights
+func cumulateWeights(weights []float64, size int) ([]float64, error) {
+	if len(weights) == 0 {
+		return nil, nil
+	}
+	if len(weights) != size {
+		return nil, fmt.Errorf("'weights' must have the same length as 'values', expected %d weights but got %d", size, len(weights))
+	}
+	cumulated := make([]float64, size)
+	total := 0.0
+	for i, w := range weights {
+		if w < 0 || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("weights have to be positive and finite, but got %v", w)
+		}
+		total += w
+		cumulated[i] = total
+	}
+	if total == 0 {
+		return nil, errors.New("at least one weight has to be > 0")
+	}
+	return cumulated, nil
+}
+
+// weightedIndex returns a random index of cumulatedWeights, with a
+// probability proportional to the weight of the index
+func weightedIndex(pcg32 *pcg.PCG32, cumulatedWeights []float64) int {
+	size := len(cumulatedWeights)
+	r := float64(pcg32.Random()) / (1 << 32) * cumulatedWeights[size-1]
+	// first index whose cumulated weight is greater than r, so
+	// indexes with a weight of 0 are never picked
+	return sort.Search(size, func(i int) bool { return cumulatedWeights[i] > r })
 }
 
 func (g *fromArrayGenerator) EncodeValue() {
@@ -73,6 +108,10 @@ func (g *fromArrayGenerator) EncodeValueAsString() {
 
 func (g *fromArrayGenerator) randomIndex() int {
 
+	if g.cumulatedWeights != nil {
+		return weightedIndex(g.pcg32, g.cumulatedWeights)
+	}
+
 	if g.randomOrder {
 		return int(g.base.pcg32.Bounded(uint32(g.size)))
 	}
@@ -84,3 +123,7 @@ func (g *fromArrayGenerator) randomIndex() int {
 	g.index++
 	return i
 }
+
+func (g *fromArrayGenerator) seek(n int) {
+	g.index = n % g.size
+}
@@ -8,11 +8,18 @@
 package generators
 
 import (
+	"math"
+
 	"github.com/MichaelTJones/pcg"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/bsontype"
 )
 
+// ChunkSize is the number of documents in a chunk. The random streams of the
+// generators are reseeded at the beginning of each chunk, so a chunk can be
+// generated independently of the previous ones
+const ChunkSize = 1000
+
 // DocumentGenerator is a Generator for creating random bson documents
 type DocumentGenerator struct {
 	// Buffer holds the document bytes
@@ -20,26 +27,90 @@ type DocumentGenerator struct {
 	// list of all generators used to create the document. The resulting document
 	// will have n keys where 0 < n < len(Generators)
 	Generators []Generator
+
+	seed uint64
+	// number of documents generated so far
+	index int
+	// random streams and seekers of all generators, including
+	// nested ones, reset at the beginning of each chunk
+	streams []*stream
+	seekers []seeker
+	// the fields of one of the variants are added to each document,
+	// after the fields common to all variants
+	variants         []*DocumentGenerator
+	cumulatedWeights []float64
+	pcg32            *pcg.PCG32
 }
 
 // Generate creates a new bson document and returns it as a slice of bytes
 func (g *DocumentGenerator) Generate() []byte {
-	g.Buffer.Truncate(4)
-	for _, gen := range g.Generators {
-		if gen.Exists() {
-			if gen.Type() != bson.TypeNull {
-				g.Buffer.WriteSingleByte(byte(gen.Type()))
-				g.Buffer.Write(gen.Key())
-				g.Buffer.WriteSingleByte(byte(0))
-			}
-			gen.EncodeValue()
-		}
+	if g.index%ChunkSize == 0 {
+		g.startChunk(g.index / ChunkSize)
 	}
+	g.Buffer.index = g.index
+	g.index++
+
+	g.Buffer.Truncate(4)
+	g.encodeFields()
 	g.Buffer.WriteSingleByte(byte(0))
 	g.Buffer.WriteAt(0, int32Bytes(int32(g.Buffer.Len())))
 	return g.Buffer.Bytes()
 }
 
+func (g *DocumentGenerator) encodeFields() {
+	for _, gen := range g.Generators {
+		encodeElement(g.Buffer, gen)
+	}
+	if g.variants != nil {
+		g.variants[weightedIndex(g.pcg32, g.cumulatedWeights)].encodeFields()
+	}
+}
+
+// encodeElement writes the type, the key and the value of the element
+// of gen to buffer, unless the element doesn't exist
+func encodeElement(buffer *DocBuffer, gen Generator) {
+	if !gen.Exists() {
+		return
+	}
+	if gen.IsNull() {
+		buffer.WriteSingleByte(byte(bson.TypeNull))
+		buffer.Write(gen.Key())
+		buffer.WriteSingleByte(byte(0))
+		return
+	}
+	if gen.Type() != bson.TypeNull {
+		buffer.WriteSingleByte(byte(gen.Type()))
+		buffer.Write(gen.Key())
+		buffer.WriteSingleByte(byte(0))
+	}
+	gen.EncodeValue()
+}
+
+// Seek moves the generator to the n-th document, so that the next call
+// to Generate() returns the same document as the n-th call to Generate()
+// on a new DocumentGenerator created from the same config and seed.
+//
+// Seeking to the first document of a chunk, ie n%ChunkSize == 0, is free.
+// Otherwise, the previous documents of the chunk have to be generated
+func (g *DocumentGenerator) Seek(n int) {
+	g.index = n - n%ChunkSize
+	for g.index < n {
+		g.Generate()
+	}
+}
+
+func (g *DocumentGenerator) startChunk(chunk int) {
+	for _, s := range g.streams {
+		s.reset(g.seed, uint64(chunk))
+	}
+	for _, s := range g.seekers {
+		s.seek(chunk * ChunkSize)
+	}
+	for _, v := range g.variants {
+		v.startChunk(chunk)
+	}
+}
+
 // Add append a new Generator to the DocumentGenerator. The generator EncodeValue() method
 // must write to the same DocBuffer as the DocumentGenerator g
 func (g *DocumentGenerator) Add(generator Generator) {
@@ -58,31 +129,102 @@ type Generator interface {
 	Type() bsontype.Type
 	// Exists returns true if the generation should be performed.
 	Exists() bool
+	// IsNull returns true if the element has to be written with a null value
+	// instead of a generated one. Only meaningful after a call to Exists()
+	IsNull() bool
 	// EncodeValue encodes a random value in bson and write it to a DocBuffer
 	EncodeValue()
 	// EncodeToString encodes a random value as a string and write it to a DocBuffer
 	EncodeValueAsString()
 }
 
+// seeker is implemented by generators whose values depend on the number
+// of values generated before, like a counter
+type seeker interface {
+	// seek resets the state of the generator to the one it has when
+	// generating the n-th document
+	seek(n int)
+}
+
+// selector is implemented by generators delegating each value to one
+// of their generators, which may be of different types
+type selector interface {
+	// selected returns the generator to use for the current value. Only
+	// meaningful after a call to Exists()
+	selected() Generator
+	// choices returns all the generators that can be selected
+	choices() []Generator
+}
+
+// stream holds the random number generators of a field. The n-th chunk
+// of documents uses the part of the streams starting at n * 2^32, so
+// chunks don't overlap unless a field draws more than 2^32 random numbers
+// in a single chunk
+type stream struct {
+	sequence uint64
+	pcg32    *pcg.PCG32
+	pcg64    *pcg.PCG64
+}
+
+func newStream(seed, sequence uint64) *stream {
+	s := &stream{
+		sequence: sequence,
+		pcg32:    pcg.NewPCG32(),
+		pcg64:    pcg.NewPCG64(),
+	}
+	s.reset(seed, 0)
+	return s
+}
+
+func (s *stream) reset(seed, chunk uint64) {
+	s.pcg32.Seed(seed, s.sequence).Advance(chunk << 32)
+	s.pcg64.Seed(seed, seed, splitmix64(s.sequence), splitmix64(s.sequence+1)).Advance(chunk << 32)
+}
+
+// splitmix64 scrambles x, so that close inputs give unrelated outputs
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// saturatingMul returns a*b, or math.MaxInt if it overflows. a and b
+// have to be >= 0
+func saturatingMul(a, b int) int {
+	if a != 0 && b > math.MaxInt/a {
+		return math.MaxInt
+	}
+	return a * b
+}
+
 // base implements Key(), Type(), Exists() and EncodeValueAsString() methods. Intended to be
 // embedded in each generator
 type base struct {
 	key []byte
 	// probability that the element doesn't exist
 	nullPercentage uint32
-	bsonType       bsontype.Type
-	buffer         *DocBuffer
-	pcg32          *pcg.PCG32
+	// probability that the element exists with a null value
+	nullValuePercentage uint32
+	// set by Exists(), true if the element has a null value
+	null     bool
+	bsonType bsontype.Type
+	buffer   *DocBuffer
+	pcg32    *pcg.PCG32
+	// if set, the element only exists when the fields
+	// generated before match the condition
+	condition *condition
 }
 
 // newBase returns a new base
-func newBase(key string, nullPercentage uint32, bsonType bsontype.Type, out *DocBuffer, pcg32 *pcg.PCG32) base {
+func newBase(key string, nullPercentage, nullValuePercentage uint32, bsonType bsontype.Type, out *DocBuffer, pcg32 *pcg.PCG32) base {
 	return base{
-		key:            []byte(key),
-		nullPercentage: nullPercentage,
-		bsonType:       bsonType,
-		buffer:         out,
-		pcg32:          pcg32,
+		key:                 []byte(key),
+		nullPercentage:      nullPercentage,
+		nullValuePercentage: nullValuePercentage,
+		bsonType:            bsonType,
+		buffer:              out,
+		pcg32:               pcg32,
 	}
 }
 
@@ -93,10 +235,19 @@ func (g *base) Type() bsontype.Type { return g.bsonType }
 // if a generator has a nullPercentage of 10%, this method will return
 // true ~90% of the time, and false ~10% of the time
 func (g *base) Exists() bool {
-	if g.nullPercentage == 0 {
+	if g.condition != nil && !g.condition.match(g.buffer) {
+		g.null = false
+		return false
+	}
+	if g.nullPercentage == 0 && g.nullValuePercentage == 0 {
 		return true
 	}
 	// get the last 10 bits of a random int32 to get a number between 0 and 1023,
-	// and compare it to nullPercentage * 10
-	return g.pcg32.Random()>>22 >= g.nullPercentage
+	// and compare it to nullPercentage * 10. A single number is used for both
+	// percentages, so an element can't be both missing and null
+	r := g.pcg32.Random() >> 22
+	g.null = r >= g.nullPercentage && r < g.nullPercentage+g.nullValuePercentage
+	return r >= g.nullPercentage
 }
+
+func (g *base) IsNull() bool { return g.null }
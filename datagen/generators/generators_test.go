@@ -1,9 +1,12 @@
 package generators_test
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -57,7 +60,7 @@ func TestIsDocumentCorrect(t *testing.T) {
 
 	fullDocumentTests := []struct {
 		name     string
-		content  map[string]generators.Config
+		content  generators.Content
 		expected any
 	}{
 		{
@@ -67,12 +70,12 @@ func TestIsDocumentCorrect(t *testing.T) {
 		},
 		{
 			name:     "empty generator",
-			content:  map[string]generators.Config{},
+			content:  generators.Content{},
 			expected: bson.M{},
 		},
 	}
 
-	ci := generators.NewCollInfo(1000, []int{3, 2}, defaultSeed, map[int][][]byte{}, map[int]bsontype.Type{})
+	ci := generators.NewCollInfo(1000, []int{3, 2}, defaultSeed, generators.References{})
 
 	for _, tt := range fullDocumentTests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -198,6 +201,139 @@ func TestNewGenerator(t *testing.T) {
 			correct: false,
 			version: []int{3, 6},
 		},
+		{
+			name: "unique int",
+			config: generators.Config{
+				Type:   generators.TypeInt,
+				Min:    "0",
+				Max:    "99",
+				Unique: true,
+			},
+			correct: true,
+			version: []int{3, 6},
+		},
+		{
+			name: "unique int with range smaller than count",
+			config: generators.Config{
+				Type:   generators.TypeInt,
+				Min:    "0",
+				Max:    "98",
+				Unique: true,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "unique int inside an array",
+			config: generators.Config{
+				Type: generators.TypeArray,
+				ArrayContent: &generators.Config{
+					Type:   generators.TypeInt,
+					Unique: true,
+				},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "unique date with range smaller than count",
+			config: generators.Config{
+				Type:      generators.TypeDate,
+				StartDate: time.Unix(0, 0),
+				EndDate:   time.Unix(10, 0),
+				Unique:    true,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "unique double with range smaller than count",
+			config: generators.Config{
+				Type:   generators.TypeDouble,
+				Min:    "1",
+				Max:    "1.00000000000001",
+				Unique: true,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "unique double with default bounds",
+			config: generators.Config{
+				Type:   generators.TypeDouble,
+				Unique: true,
+			},
+			correct: true,
+			version: []int{3, 6},
+		},
+		{
+			name: "unique long with full range",
+			config: generators.Config{
+				Type:   generators.TypeLong,
+				Min:    "-9223372036854775808",
+				Max:    "9223372036854775807",
+				Unique: true,
+			},
+			correct: true,
+			version: []int{3, 6},
+		},
+		{
+			name: "unique string inside an array",
+			config: generators.Config{
+				Type:      generators.TypeArray,
+				MaxLength: "5",
+				ArrayContent: &generators.Config{
+					Type:      generators.TypeString,
+					MinLength: "2",
+					MaxLength: "2",
+					Unique:    true,
+				},
+			},
+			correct: true,
+			version: []int{3, 6},
+		},
+		{
+			name: "unique string inside an array with range smaller than count",
+			config: generators.Config{
+				Type:      generators.TypeArray,
+				MaxLength: "100",
+				ArrayContent: &generators.Config{
+					Type:      generators.TypeString,
+					MinLength: "2",
+					MaxLength: "2",
+					Unique:    true,
+				},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "unique decimal",
+			config: generators.Config{
+				Type:   generators.TypeDecimal,
+				Unique: true,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "unique uuid",
+			config: generators.Config{
+				Type:   generators.TypeUUID,
+				Unique: true,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "unique binary with minLength 0",
+			config: generators.Config{
+				Type:   generators.TypeBinary,
+				Unique: true,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
 		{
 			name: "long with missing Min and Max",
 			config: generators.Config{
@@ -362,6 +498,94 @@ func TestNewGenerator(t *testing.T) {
 			correct: false,
 			version: []int{3, 6},
 		},
+		{
+			name: "array with minLength and content with condition",
+			config: generators.Config{
+				Type:         generators.TypeArray,
+				MinLength:    "1",
+				ArrayContent: &generators.Config{Type: generators.TypeBoolean, Condition: bson.M{"a": 1}},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "array with minLength and switch without default",
+			config: generators.Config{
+				Type:      generators.TypeArray,
+				MinLength: "1",
+				ArrayContent: &generators.Config{
+					Type:  generators.TypeSwitch,
+					Cases: []generators.Case{{Condition: bson.M{"a": 1}, Generator: generators.Config{Type: generators.TypeBoolean}}},
+				},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "autoincrement int out of range",
+			config: generators.Config{
+				Type:     generators.TypeAutoincrement,
+				AutoType: generators.TypeInt,
+				Start:    "2147483600",
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "autoincrement long out of range",
+			config: generators.Config{
+				Type:     generators.TypeAutoincrement,
+				AutoType: generators.TypeLong,
+				Start:    "9223372036854775800",
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "autoincrement with nullPercentage",
+			config: generators.Config{
+				Type:           generators.TypeAutoincrement,
+				AutoType:       generators.TypeInt,
+				NullPercentage: 10,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "autoincrement in array of variable length",
+			config: generators.Config{
+				Type:         generators.TypeArray,
+				MinLength:    "1",
+				MaxLength:    "3",
+				ArrayContent: &generators.Config{Type: generators.TypeAutoincrement, AutoType: generators.TypeInt},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "autoincrement in object that may be missing",
+			config: generators.Config{
+				Type:              generators.TypeObject,
+				MissingPercentage: 10,
+				ObjectContent: generators.Content{
+					{Name: "n", Config: generators.Config{Type: generators.TypeAutoincrement, AutoType: generators.TypeLong}},
+				},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "autoincrement in oneOf",
+			config: generators.Config{
+				Type: generators.TypeOneOf,
+				Generators: []generators.Config{
+					{Type: generators.TypeAutoincrement, AutoType: generators.TypeInt},
+					{Type: generators.TypeBoolean},
+				},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
 		{
 			name: "ref generator with invalid generator",
 			config: generators.Config{
@@ -386,11 +610,11 @@ func TestNewGenerator(t *testing.T) {
 			name: "object generator with invalid generator",
 			config: generators.Config{
 				Type: generators.TypeObject,
-				ObjectContent: map[string]generators.Config{
-					"key": {
+				ObjectContent: generators.Content{
+					{Name: "key", Config: generators.Config{
 						Type:      generators.TypeString,
 						MinLength: "-1",
-					},
+					}},
 				},
 			},
 			correct: false,
@@ -602,354 +826,3230 @@ func TestNewGenerator(t *testing.T) {
 			correct: false,
 			version: []int{3, 6},
 		},
+		{
+			name: "int with normal distribution",
+			config: generators.Config{
+				Type:         generators.TypeInt,
+				Min:          "0",
+				Max:          "100",
+				Distribution: generators.DistributionNormal,
+				Mean:         "40",
+				StdDev:       "10",
+			},
+			correct: true,
+			version: []int{3, 6},
+		},
+		{
+			name: "date with exponential distribution",
+			config: generators.Config{
+				Type:         generators.TypeDate,
+				StartDate:    time.Unix(0, 0),
+				EndDate:      time.Unix(1000000, 0),
+				Distribution: generators.DistributionExponential,
+				Lambda:       "0.0001",
+			},
+			correct: true,
+			version: []int{3, 6},
+		},
+		{
+			name: "invalid distribution",
+			config: generators.Config{
+				Type:         generators.TypeDouble,
+				Distribution: "gamma",
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "distribution with invalid parameter",
+			config: generators.Config{
+				Type:         generators.TypeLong,
+				Distribution: generators.DistributionNormal,
+				StdDev:       "a",
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "normal distribution with negative stdDev",
+			config: generators.Config{
+				Type:         generators.TypeInt,
+				Distribution: generators.DistributionNormal,
+				StdDev:       "-1",
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "poisson distribution with lambda == 0",
+			config: generators.Config{
+				Type:         generators.TypeInt,
+				Distribution: generators.DistributionPoisson,
+				Lambda:       "0",
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "zipf distribution with exponent <= 1",
+			config: generators.Config{
+				Type:         generators.TypeInt,
+				Distribution: generators.DistributionZipf,
+				Exponent:     "1",
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "unique with distribution",
+			config: generators.Config{
+				Type:         generators.TypeInt,
+				Distribution: generators.DistributionNormal,
+				Unique:       true,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "enum with weights",
+			config: generators.Config{
+				Type:    generators.TypeEnum,
+				Values:  []any{"a", "b"},
+				Weights: []float64{0.9, 0.1},
+			},
+			correct: true,
+			version: []int{3, 6},
+		},
+		{
+			name: "enum with less weights than values",
+			config: generators.Config{
+				Type:    generators.TypeEnum,
+				Values:  []any{"a", "b"},
+				Weights: []float64{1},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "enum with negative weight",
+			config: generators.Config{
+				Type:    generators.TypeEnum,
+				Values:  []any{"a", "b"},
+				Weights: []float64{2, -1},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "enum with weights equal to 0",
+			config: generators.Config{
+				Type:    generators.TypeEnum,
+				Values:  []any{"a", "b"},
+				Weights: []float64{0, 0},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "oneOf",
+			config: generators.Config{
+				Type: generators.TypeOneOf,
+				Generators: []generators.Config{
+					{Type: generators.TypeInt, Min: "1", Max: "10"},
+					{Type: generators.TypeEnum, Values: []any{"a", "b"}},
+				},
+				Weights: []float64{2, 1},
+			},
+			correct: true,
+			version: []int{3, 6},
+		},
+		{
+			name: "oneOf without generators",
+			config: generators.Config{
+				Type: generators.TypeOneOf,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "oneOf with less weights than generators",
+			config: generators.Config{
+				Type: generators.TypeOneOf,
+				Generators: []generators.Config{
+					{Type: generators.TypeInt, Min: "1", Max: "10"},
+					{Type: generators.TypeBoolean},
+				},
+				Weights: []float64{1},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "oneOf with invalid generator",
+			config: generators.Config{
+				Type: generators.TypeOneOf,
+				Generators: []generators.Config{
+					{Type: generators.TypeInt, Min: "10", Max: "1"},
+				},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "condition with operators",
+			config: generators.Config{
+				Type:      generators.TypeBoolean,
+				Condition: bson.M{"a": bson.M{"$gte": json.Number("1"), "$lt": 10.5}, "b.c": "x", "d": bson.M{"$exists": true}},
+			},
+			correct: true,
+			version: []int{3, 6},
+		},
+		{
+			name: "condition with invalid operator",
+			config: generators.Config{
+				Type:      generators.TypeBoolean,
+				Condition: bson.M{"a": bson.M{"$regex": "x"}},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "condition with unsupported value",
+			config: generators.Config{
+				Type:      generators.TypeBoolean,
+				Condition: bson.M{"a": []any{1, 2}},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "condition with $gt null",
+			config: generators.Config{
+				Type:      generators.TypeBoolean,
+				Condition: bson.M{"a": bson.M{"$gt": nil}},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "condition with non boolean $exists",
+			config: generators.Config{
+				Type:      generators.TypeBoolean,
+				Condition: bson.M{"a": bson.M{"$exists": "yes"}},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "switch",
+			config: generators.Config{
+				Type: generators.TypeSwitch,
+				Cases: []generators.Case{
+					{Condition: bson.M{"a": "x"}, Generator: generators.Config{Type: generators.TypeInt, Min: "0", Max: "10"}},
+				},
+				Default: &generators.Config{Type: generators.TypeString, MinLength: "1", MaxLength: "3"},
+			},
+			correct: true,
+			version: []int{3, 6},
+		},
+		{
+			name: "switch without cases",
+			config: generators.Config{
+				Type: generators.TypeSwitch,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "switch with empty condition",
+			config: generators.Config{
+				Type: generators.TypeSwitch,
+				Cases: []generators.Case{
+					{Generator: generators.Config{Type: generators.TypeBoolean}},
+				},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "switch with invalid default",
+			config: generators.Config{
+				Type: generators.TypeSwitch,
+				Cases: []generators.Case{
+					{Condition: bson.M{"a": "x"}, Generator: generators.Config{Type: generators.TypeBoolean}},
+				},
+				Default: &generators.Config{Type: generators.TypeInt, Min: "10", Max: "0"},
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "timestamp with invalid bounds",
+			config: generators.Config{
+				Type:      generators.TypeTimestamp,
+				StartDate: time.Unix(100, 0),
+				EndDate:   time.Unix(10, 0),
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "timestamp before 1970",
+			config: generators.Config{
+				Type:    generators.TypeTimestamp,
+				EndDate: time.Unix(10, 0),
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "timestamp with max increment < min increment",
+			config: generators.Config{
+				Type:      generators.TypeTimestamp,
+				StartDate: time.Unix(0, 0),
+				EndDate:   time.Unix(10, 0),
+				Min:       "10",
+				Max:       "1",
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "regex with invalid flag",
+			config: generators.Config{
+				Type:  generators.TypeRegex,
+				Flags: "iz",
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "regex with duplicated flag",
+			config: generators.Config{
+				Type:  generators.TypeRegex,
+				Flags: "imi",
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "javascript with invalid maxLength",
+			config: generators.Config{
+				Type:      generators.TypeJavascript,
+				MinLength: "3",
+				MaxLength: "2",
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "unique symbol",
+			config: generators.Config{
+				Type:   generators.TypeSymbol,
+				Unique: true,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "missingPercentage > 100",
+			config: generators.Config{
+				Type:              generators.TypeBoolean,
+				MissingPercentage: 101,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "nullValuePercentage < 0",
+			config: generators.Config{
+				Type:                generators.TypeBoolean,
+				NullValuePercentage: -1,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "missingPercentage + nullValuePercentage > 100",
+			config: generators.Config{
+				Type:                generators.TypeBoolean,
+				MissingPercentage:   60,
+				NullValuePercentage: 50,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+		{
+			name: "nullPercentage and missingPercentage",
+			config: generators.Config{
+				Type:              generators.TypeBoolean,
+				NullPercentage:    10,
+				MissingPercentage: 10,
+			},
+			correct: false,
+			version: []int{3, 6},
+		},
+	}
+	// all possible faker methods
+	fakerVal := []string{
+		"CellPhoneNumber",
+		"CityPrefix",
+		"CitySuffix",
+		"CompanyBs",
+		"CompanyCatchPhrase",
+		"CompanyName",
+		"DomainWord",
+		"FreeEmail",
+		"PhoneNumber",
+		"PostCode",
+		"SafeEmail",
+		"SecondaryAddress",
+		"StateAbbr",
+		"StreetAddress",
+		"UserName",
+		generators.MethodAnimal,
+		generators.MethodAnimalType,
+		generators.MethodBS,
+		generators.MethodBeerAlcohol,
+		generators.MethodBeerBlg,
+		generators.MethodBeerHop,
+		generators.MethodBeerIbu,
+		generators.MethodBeerMalt,
+		generators.MethodBeerName,
+		generators.MethodBeerStyle,
+		generators.MethodBeerYeast,
+		generators.MethodBuzzWord,
+		generators.MethodCarMaker,
+		generators.MethodCarModel,
+		generators.MethodCat,
+		generators.MethodChromeUserAgent,
+		generators.MethodColor,
+		generators.MethodCity,
+		generators.MethodCompany,
+		generators.MethodCompanySuffix,
+		generators.MethodCountry,
+		generators.MethodCountryAbr,
+		generators.MethodCreditCardCvv,
+		generators.MethodCreditCardExp,
+		generators.MethodCreditCardType,
+		generators.MethodCurrencyLong,
+		generators.MethodCurrencyShort,
+		generators.MethodDog,
+		generators.MethodDomainName,
+		generators.MethodDomainSuffix,
+		generators.MethodEmail,
+		generators.MethodEmoji,
+		generators.MethodEmojiAlias,
+		generators.MethodEmojiCategory,
+		generators.MethodEmojiDescription,
+		generators.MethodEmojiTag,
+		generators.MethodFileExtension,
+		generators.MethodFarmAnimal,
+		generators.MethodFirefoxUserAgent,
+		generators.MethodFirstName,
+		generators.MethodCarFuelType,
+		generators.MethodGender,
+		generators.MethodHTTPMethod,
+		generators.MethodHackerAbbreviation,
+		generators.MethodHackerAdjective,
+		generators.MethodHackeringVerb,
+		generators.MethodHackerNoun,
+		generators.MethodHackerPhrase,
+		generators.MethodHackerVerb,
+		generators.MethodHexColor,
+		generators.MethodHipsterWord,
+		generators.MethodIPv4Address,
+		generators.MethodIPv6Address,
+		generators.MethodJobDescriptor,
+		generators.MethodJobLevel,
+		generators.MethodJobTitle,
+		generators.MethodLanguage,
+		generators.MethodLanguageAbbreviation,
+		generators.MethodLastName,
+		generators.MethodLetter,
+		generators.MethodMacAddress,
+		generators.MethodFileMimeType,
+		generators.MethodMonth,
+		generators.MethodName,
+		generators.MethodNamePrefix,
+		generators.MethodNameSuffix,
+		generators.MethodOperaUserAgent,
+		generators.MethodPetName,
+		generators.MethodPhone,
+		generators.MethodPhoneFormatted,
+		generators.MethodProgrammingLanguage,
+		generators.MethodProgrammingLanguageBest,
+		generators.MethodQuestion,
+		generators.MethodQuote,
+		generators.MethodSSN,
+		generators.MethodSafariUserAgent,
+		generators.MethodSafeColor,
+		generators.MethodState,
+		generators.MethodStateAbr,
+		generators.MethodStreet,
+		generators.MethodStreetName,
+		generators.MethodStreetNumber,
+		generators.MethodStreetPrefix,
+		generators.MethodStreetSuffix,
+		generators.MethodTimeZone,
+		generators.MethodTimeZoneAbv,
+		generators.MethodTimeZoneFull,
+		generators.MethodCarTransmissionType,
+		generators.MethodURL,
+		generators.MethodUserAgent,
+		generators.MethodUsername,
+		generators.MethodCarType,
+		generators.MethodWeekDay,
+		generators.MethodWord,
+		generators.MethodZip,
+	}
+
+	for _, f := range fakerVal {
+		newGeneratorTests = append(newGeneratorTests, testCase{
+			name: fmt.Sprintf(`faker generator with method "%s"`, f),
+			config: generators.Config{
+				Type:   generators.TypeFaker,
+				Method: f,
+			},
+			correct: true,
+			version: []int{3, 6},
+		})
+	}
+
+	ci := generators.NewCollInfo(100, nil, defaultSeed, generators.References{})
+
+	for _, tt := range newGeneratorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			ci.Version = tt.version
+			var content = generators.Content{
+				{Name: "k", Config: tt.config},
+			}
+			_, err := ci.NewDocumentGenerator(content)
+			if tt.correct && err != nil {
+				t.Errorf("expected no error for generator with config %v \nbut got \n%v", tt.config, err)
+			}
+			if !tt.correct && err == nil {
+				t.Errorf("generator with config %v should fail", tt.config)
+			}
+		})
+	}
+}
+
+func TestNewGeneratorFromMap(t *testing.T) {
+
+	contentList := loadCollConfig(t, "ref.json")
+
+	generatorFromMapTests := []struct {
+		name         string
+		config       generators.Content
+		correct      bool
+		nbGenerators int
+	}{
+		{
+			name: "invalid generator",
+			config: generators.Content{
+				{Name: "key", Config: generators.Config{
+					Type:      generators.TypeString,
+					MinLength: "-1",
+				}},
+			},
+			correct:      false,
+			nbGenerators: 0,
+		}, {
+			name:         "ref.json[0]",
+			config:       contentList[0],
+			correct:      true,
+			nbGenerators: 14,
+		}, {
+			name:         "ref.json[1]",
+			config:       contentList[1],
+			correct:      true,
+			nbGenerators: 3,
+		},
+	}
+
+	ci := generators.NewCollInfo(1, []int{3, 4}, defaultSeed, generators.References{})
+
+	for _, tt := range generatorFromMapTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ci.NewDocumentGenerator(tt.config)
+			if tt.correct {
+				if err != nil {
+					t.Errorf("expected no error for config %v \n%v", tt.config, err)
+				}
+			} else if !tt.correct && err == nil {
+				t.Errorf("expected an error for config %v but got none", tt.config)
+			}
+		})
+	}
+}
+
+func loadCollConfig(t *testing.T, filename string) []generators.Content {
+	bytes, err := os.ReadFile("testdata/" + filename)
+	if err != nil {
+		t.Error(err)
+	}
+	var cc []struct {
+		Content generators.Content `json:"content"`
+	}
+	err = json.Unmarshal(bytes, &cc)
+	if err != nil {
+		t.Error(err)
+	}
+	list := make([]generators.Content, 0, len(cc))
+	for _, c := range cc {
+		list = append(list, c.Content)
+	}
+	return list
+}
+
+func TestEncodeToString(t *testing.T) {
+
+	encodeToStringTests := []struct {
+		name   string
+		config generators.Config
+		empty  bool
+	}{
+		{
+			name:   "int",
+			config: generators.Config{Type: generators.TypeInt},
+			empty:  false,
+		},
+		{
+			name:   "long",
+			config: generators.Config{Type: generators.TypeLong},
+			empty:  false,
+		},
+		{
+			name:   "double",
+			config: generators.Config{Type: generators.TypeDouble},
+			empty:  false,
+		},
+		{
+			name:   "decimal",
+			config: generators.Config{Type: generators.TypeDecimal},
+			empty:  false,
+		},
+		{
+			name:   "autoincrement",
+			config: generators.Config{Type: generators.TypeAutoincrement, AutoType: "long"},
+			empty:  false,
+		},
+		{
+			name:   "boolean",
+			config: generators.Config{Type: generators.TypeBoolean},
+			empty:  false,
+		},
+		{
+			name:   "objectId",
+			config: generators.Config{Type: generators.TypeObjectID},
+			empty:  false,
+		},
+		{
+			name:   "UUID",
+			config: generators.Config{Type: generators.TypeUUID},
+			empty:  false,
+		},
+		{
+			name:   "date",
+			config: generators.Config{Type: generators.TypeDate, StartDate: time.Unix(0, 0), EndDate: time.Now()},
+			empty:  false,
+		},
+		{
+			name:   "string",
+			config: generators.Config{Type: generators.TypeString, MinLength: "1"},
+			empty:  false,
+		},
+		{
+			name:   "string from parts",
+			config: generators.Config{Type: generators.TypeStringFromParts, Parts: []generators.Config{{Type: generators.TypeInt}}},
+			empty:  false,
+		},
+		{
+			name:   "coordinates",
+			config: generators.Config{Type: generators.TypeCoordinates},
+			empty:  false,
+		},
+		{
+			name:   "contant",
+			config: generators.Config{Type: generators.TypeConstant, ConstVal: "hello"},
+			empty:  false,
+		},
+		{
+			name:   "enum",
+			config: generators.Config{Type: generators.TypeEnum, Values: []any{true, false}},
+			empty:  false,
+		},
+		{
+			name:   "faker",
+			config: generators.Config{Type: generators.TypeFaker, Method: generators.MethodAnimal},
+			empty:  false,
+		},
+		{
+			name:   "timestamp",
+			config: generators.Config{Type: generators.TypeTimestamp, StartDate: time.Unix(0, 0), EndDate: time.Unix(100, 0)},
+			empty:  false,
+		},
+		{
+			name:   "regex",
+			config: generators.Config{Type: generators.TypeRegex, Flags: "i"},
+			empty:  false,
+		},
+		{
+			name:   "javascript",
+			config: generators.Config{Type: generators.TypeJavascript},
+			empty:  false,
+		},
+		{
+			name:   "dbPointer",
+			config: generators.Config{Type: generators.TypeDBPointer, Database: "db", Collection: "coll"},
+			empty:  false,
+		},
+		{
+			name:   "minKey",
+			config: generators.Config{Type: generators.TypeMinKey},
+			empty:  false,
+		},
+		{
+			name:   "null",
+			config: generators.Config{Type: generators.TypeNull},
+			empty:  false,
+		},
+		{
+			name:   "array",
+			config: generators.Config{Type: generators.TypeArray, ArrayContent: &generators.Config{Type: generators.TypePosition}},
+			empty:  false,
+		},
+		{
+			name:   "binary",
+			config: generators.Config{Type: generators.TypeBinary, MinLength: "2"},
+			empty:  true,
+		},
+		{
+			name:   "object",
+			config: generators.Config{Type: generators.TypeObject, ObjectContent: generators.Content{}},
+			empty:  true,
+		},
+	}
+
+	ci := generators.NewCollInfo(1000, []int{3, 6}, defaultSeed, generators.References{})
+
+	for _, tt := range encodeToStringTests {
+		t.Run(tt.name, func(t *testing.T) {
+			var content = generators.Content{
+				{Name: "str_from_parts", Config: generators.Config{
+					Type: generators.TypeStringFromParts,
+					Parts: []generators.Config{
+						tt.config,
+					},
+				}},
+			}
+
+			g, err := ci.NewDocumentGenerator(content)
+			if err != nil {
+				t.Errorf("expected no error for config %v \n%v", tt.config, err)
+			}
+
+			doc := bson.Raw(g.Generate())
+			v := doc.Lookup("str_from_parts").StringValue()
+			if !tt.empty && v == "" {
+				t.Error("encode to string should generate a non empty string")
+			}
+			if tt.empty && v != "" {
+				t.Error("encode to string should generate an empty string")
+			}
+		})
+	}
+}
+
+func TestBSONTypes(t *testing.T) {
+
+	content := generators.Content{
+		{Name: "timestamp", Config: generators.Config{Type: generators.TypeTimestamp, StartDate: time.Unix(1000, 0), EndDate: time.Unix(2000, 0), Min: "5", Max: "10"}},
+		{Name: "regex", Config: generators.Config{Type: generators.TypeRegex, MinLength: "1", MaxLength: "5", Flags: "xi"}},
+		{Name: "javascript", Config: generators.Config{Type: generators.TypeJavascript}},
+		{Name: "symbol", Config: generators.Config{Type: generators.TypeSymbol, MinLength: "3", MaxLength: "3"}},
+		{Name: "dbPointer", Config: generators.Config{Type: generators.TypeDBPointer}},
+		{Name: "otherDBPointer", Config: generators.Config{Type: generators.TypeDBPointer, Collection: "other"}},
+		{Name: "minKey", Config: generators.Config{Type: generators.TypeMinKey}},
+		{Name: "maxKey", Config: generators.Config{Type: generators.TypeMaxKey}},
+		{Name: "null", Config: generators.Config{Type: generators.TypeNull}},
+		{Name: "array", Config: generators.Config{Type: generators.TypeArray, MinLength: "2", MaxLength: "2", ArrayContent: &generators.Config{Type: generators.TypeNull}}},
+		{Name: "regexArray", Config: generators.Config{Type: generators.TypeArray, MinLength: "2", MaxLength: "2", ArrayContent: &generators.Config{Type: generators.TypeRegex}}},
+	}
+
+	ci := generators.NewCollInfo(1000, []int{3, 6}, defaultSeed, generators.References{})
+	ci.Namespace = "db.coll"
+	docGenerator, err := ci.NewDocumentGenerator(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < ci.Count; i++ {
+		doc := bson.Raw(docGenerator.Generate())
+		if err := doc.Validate(); err != nil {
+			t.Fatalf("invalid document %v: %v", doc, err)
+		}
+
+		var d struct {
+			Timestamp  primitive.Timestamp  `bson:"timestamp"`
+			Regex      primitive.Regex      `bson:"regex"`
+			Javascript primitive.JavaScript `bson:"javascript"`
+			Symbol     primitive.Symbol     `bson:"symbol"`
+			DBPointer  primitive.DBPointer  `bson:"dbPointer"`
+			Other      primitive.DBPointer  `bson:"otherDBPointer"`
+			MinKey     primitive.MinKey     `bson:"minKey"`
+			MaxKey     primitive.MaxKey     `bson:"maxKey"`
+			Null       *int                 `bson:"null"`
+			Array      []any                `bson:"array"`
+			RegexArray []primitive.Regex    `bson:"regexArray"`
+		}
+		if err := bson.Unmarshal(doc, &d); err != nil {
+			t.Fatalf("fail to unmarshal doc: %v", err)
+		}
+
+		if d.Timestamp.T < 1000 || d.Timestamp.T > 2000 || d.Timestamp.I < 5 || d.Timestamp.I > 10 {
+			t.Errorf("timestamp %v is out of bounds", d.Timestamp)
+		}
+		if len(d.Regex.Pattern) < 1 || len(d.Regex.Pattern) > 5 || d.Regex.Options != "ix" {
+			t.Errorf("invalid regex %v", d.Regex)
+		}
+		if !strings.HasPrefix(string(d.Javascript), "function() {") {
+			t.Errorf("invalid javascript code %s", d.Javascript)
+		}
+		if len(d.Symbol) != 3 {
+			t.Errorf("invalid symbol %s", d.Symbol)
+		}
+		if d.DBPointer.DB != "db.coll" {
+			t.Errorf("expected a DBPointer to 'db.coll', but got %v", d.DBPointer)
+		}
+		if d.Other.DB != "db.other" {
+			t.Errorf("expected a DBPointer to 'db.other', but got %v", d.Other)
+		}
+		if _, err := doc.LookupErr("null"); err != nil || d.Null != nil {
+			t.Errorf("expected an explicit null value, but got %v", doc.Lookup("null"))
+		}
+		if len(d.Array) != 2 || d.Array[0] != nil || d.Array[1] != nil {
+			t.Errorf("expected an array of null values, but got %v", d.Array)
+		}
+		if len(d.RegexArray) != 2 {
+			t.Errorf("expected an array of regex, but got %v", d.RegexArray)
+		}
+	}
+}
+
+func TestMissingAndNullValues(t *testing.T) {
+
+	count := 20000
+	intConfig := generators.Config{Type: generators.TypeInt, MissingPercentage: 20, NullValuePercentage: 30}
+
+	content := generators.Content{
+		{Name: "top", Config: intConfig},
+		{Name: "legacy", Config: generators.Config{Type: generators.TypeInt, NullPercentage: 20, NullValuePercentage: 30}},
+		{Name: "object", Config: generators.Config{Type: generators.TypeObject, ObjectContent: generators.Content{{Name: "nested", Config: intConfig}}}},
+		{Name: "array", Config: generators.Config{Type: generators.TypeArray, MinLength: "0", MaxLength: "1", ArrayContent: &intConfig}},
+	}
+
+	ci := generators.NewCollInfo(count, []int{3, 6}, defaultSeed, generators.References{})
+	docGenerator, err := ci.NewDocumentGenerator(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []string{"top", "legacy", "object.nested"}
+	missing, null := map[string]int{}, map[string]int{}
+
+	for i := 0; i < count; i++ {
+		doc := bson.Raw(docGenerator.Generate())
+		if err := doc.Validate(); err != nil {
+			t.Fatalf("invalid document %v: %v", doc, err)
+		}
+		for _, path := range paths {
+			v, err := doc.LookupErr(strings.Split(path, ".")...)
+			switch {
+			case err != nil:
+				missing[path]++
+			case v.Type == bson.TypeNull:
+				null[path]++
+			case v.Type != bson.TypeInt32:
+				t.Fatalf("expected an int or null for %s, but got %v", path, v)
+			}
+		}
+	}
+
+	for _, path := range paths {
+		missingShare := float64(missing[path]) / float64(count) * 100
+		nullShare := float64(null[path]) / float64(count) * 100
+		if math.Abs(missingShare-20) > 1.5 || math.Abs(nullShare-30) > 1.5 {
+			t.Errorf("%s: expected 20%% of missing and 30%% of null values, but got %v%% and %v%%", path, missingShare, nullShare)
+		}
+	}
+}
+
+func TestMissingAndNullArrayElements(t *testing.T) {
+
+	count := 20000
+	content := generators.Content{
+		{Name: "missing", Config: generators.Config{Type: generators.TypeArray, MinLength: "2", MaxLength: "6", ArrayContent: &generators.Config{
+			Type: generators.TypeInt, MissingPercentage: 20, NullValuePercentage: 30,
+		}}},
+		// 'nullPercentage' is ignored for the content of an array
+		{Name: "legacy", Config: generators.Config{Type: generators.TypeArray, MinLength: "3", MaxLength: "3", ArrayContent: &generators.Config{
+			Type: generators.TypeInt, NullPercentage: 50,
+		}}},
+	}
+
+	ci := generators.NewCollInfo(count, []int{3, 6}, defaultSeed, generators.References{})
+	docGenerator, err := ci.NewDocumentGenerator(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	elements, null := 0, 0
+	for i := 0; i < count; i++ {
+		doc := bson.Raw(docGenerator.Generate())
+		if err := doc.Validate(); err != nil {
+			t.Fatalf("invalid document %v: %v", doc, err)
+		}
+		values, _ := doc.Lookup("missing").Array().Values()
+		if len(values) < 2 || len(values) > 6 {
+			t.Fatalf("expected between 2 and 6 elements, but got %v", values)
+		}
+		for _, v := range values {
+			if v.Type == bson.TypeNull {
+				null++
+			}
+		}
+		elements += len(values)
+
+		values, _ = doc.Lookup("legacy").Array().Values()
+		if len(values) != 3 {
+			t.Fatalf("expected 3 elements, but got %v", values)
+		}
+		for _, v := range values {
+			if v.Type != bson.TypeInt32 {
+				t.Fatalf("expected an int, but got %v", v)
+			}
+		}
+	}
+
+	// the first 2 elements are always present, and the 0 to 4
+	// following ones are missing 20% of the time
+	if mean := float64(elements) / float64(count); math.Abs(mean-(2+2*0.8)) > 0.05 {
+		t.Errorf("expected %v elements per array on average, but got %v", 2+2*0.8, mean)
+	}
+	if share := float64(null) / float64(elements) * 100; math.Abs(share-30) > 1.5 {
+		t.Errorf("expected 30%% of null elements, but got %v%%", share)
+	}
+}
+
+func TestEnumWeights(t *testing.T) {
+
+	count := 20000
+	enum := generators.Config{
+		Type:    generators.TypeEnum,
+		Values:  []any{"active", "suspended", "deleted", "never"},
+		Weights: []float64{90, 9, 1, 0},
+	}
+	content := generators.Content{
+		{Name: "status", Config: enum},
+		{Name: "statusStr", Config: generators.Config{Type: generators.TypeStringFromParts, Parts: []generators.Config{enum}}},
+	}
+
+	ci := generators.NewCollInfo(count, []int{3, 6}, defaultSeed, generators.References{})
+	docGenerator, err := ci.NewDocumentGenerator(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{"status", "statusStr"} {
+		docGenerator.Seek(0)
+		occurrences := map[string]int{}
+		for i := 0; i < count; i++ {
+			occurrences[bson.Raw(docGenerator.Generate()).Lookup(field).StringValue()]++
+		}
+		for i, v := range enum.Values {
+			share := float64(occurrences[v.(string)]) / float64(count) * 100
+			if math.Abs(share-enum.Weights[i]) > 1 {
+				t.Errorf("field %s: expected '%s' in %v%% of the documents, but got %v%%", field, v, enum.Weights[i], share)
+			}
+		}
+		if occurrences["never"] != 0 {
+			t.Errorf("field %s: value with a weight of 0 shouldn't be picked", field)
+		}
+	}
+}
+
+func TestOneOf(t *testing.T) {
+
+	count := 20000
+	oneOf := generators.Config{
+		Type: generators.TypeOneOf,
+		Generators: []generators.Config{
+			{Type: generators.TypeDouble, Min: "0", Max: "100"},
+			{Type: generators.TypeString, MinLength: "3", MaxLength: "5"},
+			{Type: generators.TypeEnum, Values: []any{int32(1), int32(2)}},
+		},
+		Weights: []float64{60, 30, 10},
+	}
+	withMissing := oneOf
+	withMissing.MissingPercentage = 10
+	content := generators.Content{
+		{Name: "price", Config: withMissing},
+		{Name: "prices", Config: generators.Config{Type: generators.TypeArray, MinLength: "5", MaxLength: "5", ArrayContent: &oneOf}},
+	}
+
+	ci := generators.NewCollInfo(count, []int{3, 6}, defaultSeed, generators.References{})
+	docGenerator, err := ci.NewDocumentGenerator(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[bsontype.Type]float64{
+		bson.TypeDouble: 60,
+		bson.TypeString: 30,
+		bson.TypeInt32:  10,
+	}
+	occurrences := map[bsontype.Type]int{}
+	missing := 0
+	elements := map[bsontype.Type]int{}
+	nbElements := 0
+
+	for i := 0; i < count; i++ {
+		doc := bson.Raw(docGenerator.Generate())
+		if err := doc.Validate(); err != nil {
+			t.Fatalf("invalid document %v: %v", doc, err)
+		}
+		price, err := doc.LookupErr("price")
+		if err != nil {
+			missing++
+		} else {
+			occurrences[price.Type]++
+		}
+		values, _ := doc.Lookup("prices").Array().Values()
+		for _, v := range values {
+			elements[v.Type]++
+		}
+		nbElements += 5
+	}
+
+	for bsonType, weight := range expected {
+		if share := float64(occurrences[bsonType]) / float64(count-missing) * 100; math.Abs(share-weight) > 1 {
+			t.Errorf("expected field of type %v in %v%% of the documents, but got %v%%", bsonType, weight, share)
+		}
+		if share := float64(elements[bsonType]) / float64(nbElements) * 100; math.Abs(share-weight) > 1 {
+			t.Errorf("expected element of type %v in %v%% of the arrays, but got %v%%", bsonType, weight, share)
+		}
+	}
+	if share := float64(missing) / float64(count) * 100; math.Abs(share-10) > 1 {
+		t.Errorf("expected field to be missing in 10%% of the documents, but got %v%%", share)
+	}
+}
+
+func TestVariants(t *testing.T) {
+
+	count := 20000
+	content := generators.Content{
+		{Name: "_id", Config: generators.Config{Type: generators.TypeAutoincrement, AutoType: generators.TypeInt}},
+	}
+	variants := []generators.Variant{
+		{
+			Percentage: 70,
+			Version:    int32(1),
+			Content: generators.Content{
+				{Name: "name", Config: generators.Config{Type: generators.TypeString, MinLength: "3", MaxLength: "5"}},
+			},
+		},
+		{
+			Percentage: 30,
+			Version:    int32(2),
+			Content: generators.Content{
+				{Name: "firstName", Config: generators.Config{Type: generators.TypeString, MinLength: "3", MaxLength: "5"}},
+				{Name: "name", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "10"}},
+			},
+		},
+	}
+
+	ci := generators.NewCollInfo(count, []int{3, 6}, defaultSeed, generators.References{})
+	docGenerator, err := ci.NewVariantsDocumentGenerator(content, variants, "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	occurrences := map[int32]int{}
+	docs := make([]bson.Raw, 0, count)
+	for i := 0; i < count; i++ {
+		doc := bson.Raw(docGenerator.Generate())
+		if err := doc.Validate(); err != nil {
+			t.Fatalf("invalid document %v: %v", doc, err)
+		}
+		docs = append(docs, append(bson.Raw(nil), doc...))
+
+		elements, _ := doc.Elements()
+		version := doc.Lookup("v").Int32()
+		occurrences[version]++
+
+		keys := make([]string, 0, len(elements))
+		for _, e := range elements {
+			keys = append(keys, e.Key())
+		}
+		expected := map[int32]string{1: "[_id v name]", 2: "[_id v firstName name]"}[version]
+		if fmt.Sprint(keys) != expected {
+			t.Errorf("expected fields %s for version %d, but got %v", expected, version, keys)
+		}
+		if doc.Lookup("_id").Int32() != int32(i) {
+			t.Errorf("expected _id %d, but got %v", i, doc.Lookup("_id"))
+		}
+	}
+	if share := float64(occurrences[1]) / float64(count) * 100; math.Abs(share-70) > 1 {
+		t.Errorf("expected version 1 in 70%% of the documents, but got %v%%", share)
+	}
+
+	// seeking to a document gives the same document as a sequential generation
+	for _, n := range []int{1500, 12345, 0} {
+		docGenerator.Seek(n)
+		if got := bson.Raw(docGenerator.Generate()); !bytes.Equal(got, docs[n]) {
+			t.Errorf("after Seek(%d), expected %v, but got %v", n, docs[n], got)
+		}
+	}
+
+	invalidVariants := []struct {
+		name         string
+		variants     []generators.Variant
+		versionField string
+	}{
+		{
+			name: "no variants",
+		},
+		{
+			name:     "percentages not summing to 100",
+			variants: []generators.Variant{{Percentage: 50}, {Percentage: 40}},
+		},
+		{
+			name:         "missing version",
+			variants:     []generators.Variant{{Percentage: 100}},
+			versionField: "v",
+		},
+		{
+			name:     "field already in content",
+			variants: []generators.Variant{{Percentage: 100, Content: generators.Content{{Name: "_id", Config: generators.Config{Type: generators.TypeObjectID}}}}},
+		},
+		{
+			name:         "version field already in content",
+			variants:     []generators.Variant{{Percentage: 100, Version: 1}},
+			versionField: "_id",
+		},
+		{
+			name:     "invalid generator",
+			variants: []generators.Variant{{Percentage: 100, Content: generators.Content{{Name: "a", Config: generators.Config{Type: "unknown"}}}}},
+		},
+		{
+			name: "aggregator in a variant",
+			variants: []generators.Variant{{Percentage: 100, Content: generators.Content{{Name: "a", Config: generators.Config{
+				Type: generators.TypeCountAggregator, Database: "db", Collection: "c", Query: bson.M{"n": 1},
+			}}}}},
+		},
+	}
+	for _, tt := range invalidVariants {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ci.NewVariantsDocumentGenerator(content, tt.variants, tt.versionField); err == nil {
+				t.Error("expected an error, but got none")
+			}
+		})
+	}
+}
+
+func TestConditions(t *testing.T) {
+
+	count := 1000
+	content := generators.Content{
+		{Name: "status", Config: generators.Config{Type: generators.TypeEnum, Values: []any{"active", "cancelled"}, RandomOrder: true}},
+		{Name: "age", Config: generators.Config{Type: generators.TypeInt, Min: "10", Max: "30", NullPercentage: 10}},
+		{Name: "cancelledAt", Config: generators.Config{Type: generators.TypeDate, StartDate: time.Unix(0, 0), EndDate: time.Unix(100000, 0), Condition: bson.M{"status": "cancelled"}}},
+		{Name: "adult", Config: generators.Config{Type: generators.TypeConstant, ConstVal: true, Condition: bson.M{"age": bson.M{"$gte": 18}}}},
+		{Name: "noAge", Config: generators.Config{Type: generators.TypeConstant, ConstVal: true, Condition: bson.M{"age": nil}}},
+		{Name: "info", Config: generators.Config{
+			Type: generators.TypeObject,
+			ObjectContent: generators.Content{
+				{Name: "n", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "9"}},
+				{Name: "big", Config: generators.Config{Type: generators.TypeBoolean, Condition: bson.M{"info.n": bson.M{"$gt": 4}}}},
+			},
+		}},
+		{Name: "discount", Config: generators.Config{
+			Type: generators.TypeSwitch,
+			Cases: []generators.Case{
+				{Condition: bson.M{"status": "cancelled"}, Generator: generators.Config{Type: generators.TypeConstant, ConstVal: "none"}},
+				{Condition: bson.M{"age": bson.M{"$in": []any{json.Number("10"), json.Number("11")}}}, Generator: generators.Config{Type: generators.TypeInt, Min: "50", Max: "60"}},
+			},
+		}},
+		{Name: "tags", Config: generators.Config{
+			Type:      generators.TypeArray,
+			MinLength: "2",
+			MaxLength: "2",
+			ArrayContent: &generators.Config{
+				Type: generators.TypeSwitch,
+				Cases: []generators.Case{
+					{Condition: bson.M{"status": bson.M{"$ne": "cancelled"}}, Generator: generators.Config{Type: generators.TypeEnum, Values: []any{"a", "b"}}},
+				},
+				Default: &generators.Config{Type: generators.TypeBoolean},
+			},
+		}},
+	}
+
+	ci := generators.NewCollInfo(count, []int{3, 6}, defaultSeed, generators.References{})
+	docGenerator, err := ci.NewDocumentGenerator(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < count; i++ {
+		doc := bson.Raw(docGenerator.Generate())
+		if err := doc.Validate(); err != nil {
+			t.Fatalf("invalid document %v: %v", doc, err)
+		}
+		cancelled := doc.Lookup("status").StringValue() == "cancelled"
+		age, hasAge := doc.Lookup("age").Int32OK()
+
+		if _, err := doc.LookupErr("cancelledAt"); (err == nil) != cancelled {
+			t.Errorf("cancelledAt should only exist for cancelled documents: %v", doc)
+		}
+		if _, err := doc.LookupErr("adult"); (err == nil) != (hasAge && age >= 18) {
+			t.Errorf("adult should only exist for age >= 18: %v", doc)
+		}
+		if _, err := doc.LookupErr("noAge"); (err == nil) == hasAge {
+			t.Errorf("noAge should only exist when age is missing: %v", doc)
+		}
+		if _, err := doc.LookupErr("info", "big"); (err == nil) != (doc.Lookup("info", "n").Int32() > 4) {
+			t.Errorf("info.big should only exist when info.n > 4: %v", doc)
+		}
+
+		discount, err := doc.LookupErr("discount")
+		switch {
+		case cancelled:
+			if err != nil || discount.StringValue() != "none" {
+				t.Errorf("expected discount 'none' for a cancelled document: %v", doc)
+			}
+		case hasAge && age <= 11:
+			if err != nil || discount.Type != bson.TypeInt32 {
+				t.Errorf("expected an int discount for age %d: %v", age, doc)
+			}
+		default:
+			if err == nil {
+				t.Errorf("expected no discount: %v", doc)
+			}
+		}
+
+		values, _ := doc.Lookup("tags").Array().Values()
+		for _, v := range values {
+			if expected := map[bool]bsontype.Type{true: bson.TypeBoolean, false: bson.TypeString}[cancelled]; v.Type != expected {
+				t.Errorf("expected tags of type %v: %v", expected, doc)
+			}
+		}
+	}
+}
+
+func TestExpressions(t *testing.T) {
+
+	start := time.Date(2020, time.January, 31, 10, 0, 0, 0, time.UTC)
+	content := generators.Content{
+		{Name: "price", Config: generators.Config{Type: generators.TypeConstant, ConstVal: 2.5}},
+		{Name: "quantity", Config: generators.Config{Type: generators.TypeConstant, ConstVal: int32(4)}},
+		{Name: "big", Config: generators.Config{Type: generators.TypeConstant, ConstVal: int64(1) << 40}},
+		{Name: "name", Config: generators.Config{Type: generators.TypeConstant, ConstVal: "Jane Doe"}},
+		{Name: "start", Config: generators.Config{Type: generators.TypeConstant, ConstVal: start}},
+		{Name: "order", Config: generators.Config{Type: generators.TypeObject, ObjectContent: generators.Content{
+			{Name: "status", Config: generators.Config{Type: generators.TypeConstant, ConstVal: "paid"}},
+		}}},
+		{Name: "my-field", Config: generators.Config{Type: generators.TypeConstant, ConstVal: int32(7)}},
+		// never generated, as no case matches
+		{Name: "missing", Config: generators.Config{Type: generators.TypeSwitch, Cases: []generators.Case{
+			{Condition: bson.M{"price": 0}, Generator: generators.Config{Type: generators.TypeBoolean}},
+		}}},
+	}
+
+	expressionTests := []struct {
+		expression string
+		expected   any
+	}{
+		{expression: "price * quantity", expected: 10.0},
+		{expression: "quantity * quantity + 1", expected: int32(17)},
+		{expression: "quantity - 2 * 3", expected: int32(-2)},
+		{expression: "(quantity - 2) * 3", expected: int32(6)},
+		{expression: "quantity / 8", expected: 0.5},
+		{expression: "quantity % 3", expected: int32(1)},
+		{expression: "big + quantity", expected: int64(1)<<40 + 4},
+		{expression: "2147483647 + quantity", expected: int64(2147483651)},
+		{expression: "-quantity", expected: int32(-4)},
+		{expression: "round(10 / 3, 2)", expected: 3.33},
+		{expression: "abs(-3.5) + floor(2.7) + ceil(0.2)", expected: 6.5},
+		{expression: "max(quantity, 10, price)", expected: int32(10)},
+		{expression: "min(quantity, missing, price)", expected: 2.5},
+		{expression: "quantity > 3 && !(price >= 3)", expected: true},
+		{expression: "name == 'Jane Doe' || false", expected: true},
+		{expression: "if(quantity != 4, 'a', 'b')", expected: "b"},
+		{expression: "ifNull(missing, 'default')", expected: "default"},
+		{expression: "lower(name) + '@company.com'", expected: "jane doe@company.com"},
+		{expression: "concat(upper(substr(name, 0, 1)), '.', replace(name, ' ', '_'), length(name))", expected: "J.Jane_Doe8"},
+		{expression: "trim('  x ')", expected: "x"},
+		{expression: "name | lower | replace(' ', '-') | padRight(10, '*')", expected: "jane-doe**"},
+		{expression: "toString(price) + toString(quantity)", expected: "2.54"},
+		{expression: "toInt('42') + toLong(3.9)", expected: int64(45)},
+		{expression: "toDouble('1.5') + toDouble(true)", expected: 2.5},
+		{expression: "toBool(0)", expected: false},
+		{expression: "order.status", expected: "paid"},
+		{expression: "field('my-field') * 2", expected: int32(14)},
+		{expression: "start + 1000", expected: start.Add(time.Second)},
+		{expression: "dateAdd(start, 1, 'month')", expected: time.Date(2020, time.March, 2, 10, 0, 0, 0, time.UTC)},
+		{expression: "dateAdd(start, -2, 'day')", expected: start.AddDate(0, 0, -2)},
+		{expression: "dateAdd(start, 3, 'hour') - start", expected: int64(3 * time.Hour / time.Millisecond)},
+		{expression: "dateDiff(toDate('1990-06-15'), start, 'year')", expected: int64(29)},
+		{expression: "dateDiff(start, toDate('2020-01-30'), 'day')", expected: int64(-1)},
+		{expression: "year(start) * 100 + month(start)", expected: int32(202001)},
+		{expression: "day(start) + hour(start) + dayOfWeek(start)", expected: int32(47)},
+		{expression: "toDate('2020-01-31T10:00:00Z') == start", expected: true},
+		{expression: "substr(name, 5, 9223372036854775807)", expected: "Doe"},
+		{expression: "pad(name, 1000000000000)", expected: nil},
+		{expression: "name * 2", expected: nil},
+		{expression: "quantity / 0", expected: nil},
+		{expression: "missing + 1", expected: nil},
+	}
+
+	exprContent := append(generators.Content{}, content...)
+	for i, tt := range expressionTests {
+		exprContent = append(exprContent, generators.Field{
+			Name:   fmt.Sprintf("e%d", i),
+			Config: generators.Config{Type: generators.TypeExpression, Expression: tt.expression},
+		})
+	}
+	// expressions can use other expressions
+	exprContent = append(exprContent, generators.Field{
+		Name:   "fromExpression",
+		Config: generators.Config{Type: generators.TypeExpression, Expression: "e0 + 1"},
+	})
+
+	ci := generators.NewCollInfo(1, []int{3, 6}, defaultSeed, generators.References{})
+	docGenerator, err := ci.NewDocumentGenerator(exprContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := bson.Raw(docGenerator.Generate())
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("invalid document %v: %v", doc, err)
+	}
+
+	for i, tt := range expressionTests {
+		t.Run(tt.expression, func(t *testing.T) {
+			expected := bson.RawValue{Type: bson.TypeNull}
+			if tt.expected != nil {
+				bsonType, value, err := bson.MarshalValue(tt.expected)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected = bson.RawValue{Type: bsonType, Value: value}
+			}
+			got := doc.Lookup(fmt.Sprintf("e%d", i))
+			if !got.Equal(expected) {
+				t.Errorf("expected %v (%v), but got %v (%v)", expected, expected.Type, got, got.Type)
+			}
+		})
+	}
+	if got := doc.Lookup("fromExpression").Double(); got != 11 {
+		t.Errorf("expected 11, but got %v", got)
+	}
+
+	invalidExpressions := []struct {
+		name   string
+		config generators.Config
+	}{
+		{name: "empty expression", config: generators.Config{Type: generators.TypeExpression}},
+		{name: "syntax error", config: generators.Config{Type: generators.TypeExpression, Expression: "price * (quantity"}},
+		{name: "unterminated string", config: generators.Config{Type: generators.TypeExpression, Expression: "'abc"}},
+		{name: "unexpected token", config: generators.Config{Type: generators.TypeExpression, Expression: "price quantity"}},
+		{name: "unknown function", config: generators.Config{Type: generators.TypeExpression, Expression: "sum(price)"}},
+		{name: "wrong number of arguments", config: generators.Config{Type: generators.TypeExpression, Expression: "lower(name, 1)"}},
+		{name: "field with a non string argument", config: generators.Config{Type: generators.TypeExpression, Expression: "field(price)"}},
+		{name: "field declared after", config: generators.Config{Type: generators.TypeExpression, Expression: "after + 1"}},
+		{name: "own field", config: generators.Config{Type: generators.TypeExpression, Expression: "invalid + 1"}},
+		{name: "maxDistinctValue", config: generators.Config{Type: generators.TypeExpression, Expression: "price", MaxDistinctValue: 10}},
+		{name: "own object", config: generators.Config{Type: generators.TypeObject, ObjectContent: generators.Content{
+			{Name: "a", Config: generators.Config{Type: generators.TypeExpression, Expression: "invalid.b"}},
+			{Name: "b", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "10"}},
+		}}},
+	}
+	for _, tt := range invalidExpressions {
+		t.Run(tt.name, func(t *testing.T) {
+			invalidContent := append(append(generators.Content{}, content...),
+				generators.Field{Name: "invalid", Config: tt.config},
+				generators.Field{Name: "after", Config: generators.Config{Type: generators.TypeBoolean}},
+			)
+			if _, err := ci.NewDocumentGenerator(invalidContent); err == nil {
+				t.Error("expected an error, but got none")
+			}
+		})
+	}
+}
+
+func TestNowDoesNotDependOnTheClock(t *testing.T) {
+
+	content := generators.Content{
+		{Name: "birthDate", Config: generators.Config{Type: generators.TypeDate, StartDate: time.Unix(86400, 0), EndDate: time.Unix(1000000000, 0)}},
+		{Name: "now", Config: generators.Config{Type: generators.TypeExpression, Expression: "now()"}},
+		{Name: "age", Config: generators.Config{Type: generators.TypeExpression, Expression: "dateDiff(birthDate, now(), 'year')"}},
+		{Name: "setNow", Config: generators.Config{Type: generators.TypeExpression, Expression: "now()", ReferenceDate: time.Date(2030, time.May, 4, 12, 0, 0, 0, time.UTC)}},
+		{Name: "template", Config: generators.Config{Type: generators.TypeTemplate, Template: "{{year(now())}}"}},
+		{Name: "setTemplate", Config: generators.Config{Type: generators.TypeTemplate, Template: "{{year(now())}}", ReferenceDate: time.Date(2030, time.May, 4, 12, 0, 0, 0, time.UTC)}},
+	}
+
+	// now() doesn't read the clock, so two runs give the same documents,
+	// and the default reference date isn't the current day
+	var docs [2][]bson.Raw
+	for run := range docs {
+		ci := generators.NewCollInfo(100, []int{3, 6}, defaultSeed, generators.References{})
+		docGenerator, err := ci.NewDocumentGenerator(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < ci.Count; i++ {
+			docs[run] = append(docs[run], append(bson.Raw(nil), docGenerator.Generate()...))
+		}
+	}
+
+	for i, doc := range docs[0] {
+		if !bytes.Equal(doc, docs[1][i]) {
+			t.Fatalf("doc %d differs: %v and %v", i, doc, docs[1][i])
+		}
+		if got := doc.Lookup("now").Time(); !got.Equal(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("expected now() to be the default reference date, but got %v", got)
+		}
+		if got := doc.Lookup("setNow").Time(); !got.Equal(time.Date(2030, time.May, 4, 12, 0, 0, 0, time.UTC)) {
+			t.Errorf("expected now() to be the reference date, but got %v", got)
+		}
+		if got := doc.Lookup("template").StringValue(); got != "2024" {
+			t.Errorf("expected now() to be in 2024 in a template, but got %s", got)
+		}
+		if got := doc.Lookup("setTemplate").StringValue(); got != "2030" {
+			t.Errorf("expected now() to be in 2030 in a template, but got %s", got)
+		}
+		// the birthday of 2024 isn't reached on January 1st
+		birthDate := doc.Lookup("birthDate").Time()
+		if age := doc.Lookup("age").Int64(); age != int64(2024-birthDate.Year()-1) {
+			t.Errorf("expected age %d for birth date %v, but got %d", 2024-birthDate.Year()-1, birthDate, age)
+		}
+	}
+}
+
+func TestTemplates(t *testing.T) {
+
+	content := generators.Content{
+		{Name: "firstName", Config: generators.Config{Type: generators.TypeConstant, ConstVal: "Jane"}},
+		{Name: "lastName", Config: generators.Config{Type: generators.TypeConstant, ConstVal: "Doe"}},
+		{Name: "address", Config: generators.Config{Type: generators.TypeObject, ObjectContent: generators.Content{
+			{Name: "city", Config: generators.Config{Type: generators.TypeConstant, ConstVal: "Saint-Étienne"}},
+			{Name: "zip", Config: generators.Config{Type: generators.TypeConstant, ConstVal: int32(42)}},
+		}}},
+		{Name: "tags", Config: generators.Config{Type: generators.TypeConstant, ConstVal: []any{"a", "b"}}},
+		{Name: "nothing", Config: generators.Config{Type: generators.TypeNull}},
+	}
+
+	templateTests := []struct {
+		name      string
+		template  string
+		variables map[string]generators.Config
+		expected  any
+	}{
+		{name: "text only", template: "hello", expected: "hello"},
+		{name: "field", template: "{{firstName}} {{ lastName }}", expected: "Jane Doe"},
+		{name: "filters", template: "{{firstName | lower}}.{{lastName|lower}}@company.com", expected: "jane.doe@company.com"},
+		{name: "functions", template: "{{lower(firstName)}}.{{lower(lastName)}}@company.com", expected: "jane.doe@company.com"},
+		{name: "nested path", template: "/{{address.city | slug}}/{{address.zip | pad(5, '0')}}", expected: "/saint-etienne/00042"},
+		{name: "array element", template: "{{tags.1 | upper}}", expected: "B"},
+		{name: "chained filters", template: "{{lastName | upper | padRight(6, '.')}}|", expected: "DOE...|"},
+		{name: "capitalize", template: "{{'jane doe' | capitalize}}", expected: "Jane doe"},
+		{name: "slug", template: "{{' Hello,  Wörld! ' | slug}}", expected: "hello-world"},
+		{name: "pad longer value", template: "{{firstName | pad(2)}}", expected: "Jane"},
+		{name: "expression", template: "{{address.zip * 2 + 1}}", expected: "85"},
+		{name: "null placeholder", template: "{{nothing}}-{{firstName}}", expected: nil},
+		{name: "default value", template: "{{nothing | ifNull('none')}}", expected: "none"},
+		{
+			name:      "generator",
+			template:  "{{$id}}-{{$id | lower}}",
+			variables: map[string]generators.Config{"id": {Type: generators.TypeConstant, ConstVal: "AB"}},
+			expected:  "AB-ab",
+		},
+		{
+			name:      "generator with a number",
+			template:  "{{$n | pad(3, '0')}}",
+			variables: map[string]generators.Config{"n": {Type: generators.TypeConstant, ConstVal: int32(7)}},
+			expected:  "007",
+		},
+		{
+			name:      "null generator",
+			template:  "{{$n}}",
+			variables: map[string]generators.Config{"n": {Type: generators.TypeNull}},
+			expected:  nil,
+		},
+		{
+			name:      "generator using fields",
+			template:  "{{$email}}",
+			variables: map[string]generators.Config{"email": {Type: generators.TypeExpression, Expression: "lower(firstName) + '@company.com'"}},
+			expected:  "jane@company.com",
+		},
+	}
+
+	ci := generators.NewCollInfo(1, []int{3, 6}, defaultSeed, generators.References{})
+	for _, tt := range templateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			templateContent := append(append(generators.Content{}, content...), generators.Field{
+				Name:   "value",
+				Config: generators.Config{Type: generators.TypeTemplate, Template: tt.template, Variables: tt.variables},
+			})
+			docGenerator, err := ci.NewDocumentGenerator(templateContent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc := bson.Raw(docGenerator.Generate())
+			if err := doc.Validate(); err != nil {
+				t.Fatalf("invalid document %v: %v", doc, err)
+			}
+			got := doc.Lookup("value")
+			if tt.expected == nil {
+				if got.Type != bson.TypeNull {
+					t.Errorf("expected null, but got %v", got)
+				}
+				return
+			}
+			if got.Type != bson.TypeString || got.StringValue() != tt.expected {
+				t.Errorf("expected %v, but got %v", tt.expected, got)
+			}
+		})
+	}
+
+	// a generator has the same value in all the placeholders of a document
+	// but a different value in each document
+	docGenerator, err := ci.NewDocumentGenerator(generators.Content{
+		{Name: "value", Config: generators.Config{
+			Type:      generators.TypeTemplate,
+			Template:  "{{$n}}={{$n}}",
+			Variables: map[string]generators.Config{"n": {Type: generators.TypeInt, Min: "0", Max: "1000000"}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		value := bson.Raw(docGenerator.Generate()).Lookup("value").StringValue()
+		parts := strings.Split(value, "=")
+		if len(parts) != 2 || parts[0] != parts[1] {
+			t.Errorf("expected the same value in both placeholders, but got %s", value)
+		}
+		values[value] = true
+	}
+	if len(values) < 90 {
+		t.Errorf("expected different values for each document, but got %d distinct values", len(values))
+	}
+
+	invalidTemplates := []struct {
+		name   string
+		config generators.Config
+	}{
+		{name: "empty template", config: generators.Config{Type: generators.TypeTemplate}},
+		{name: "unclosed placeholder", config: generators.Config{Type: generators.TypeTemplate, Template: "{{firstName"}},
+		{name: "empty placeholder", config: generators.Config{Type: generators.TypeTemplate, Template: "{{}}"}},
+		{name: "invalid placeholder", config: generators.Config{Type: generators.TypeTemplate, Template: "{{firstName +}}"}},
+		{name: "unknown filter", config: generators.Config{Type: generators.TypeTemplate, Template: "{{firstName | reverse}}"}},
+		{name: "missing filter", config: generators.Config{Type: generators.TypeTemplate, Template: "{{firstName | }}"}},
+		{name: "wrong number of arguments", config: generators.Config{Type: generators.TypeTemplate, Template: "{{firstName | pad}}"}},
+		{name: "field declared after", config: generators.Config{Type: generators.TypeTemplate, Template: "{{after}}"}},
+		{name: "own field", config: generators.Config{Type: generators.TypeTemplate, Template: "{{invalid}}"}},
+		{name: "undefined generator", config: generators.Config{Type: generators.TypeTemplate, Template: "{{$n}}"}},
+		{name: "invalid generator", config: generators.Config{
+			Type:      generators.TypeTemplate,
+			Template:  "{{$n}}",
+			Variables: map[string]generators.Config{"n": {Type: generators.TypeInt, Min: "10", Max: "0"}},
+		}},
+		{name: "maxDistinctValue", config: generators.Config{Type: generators.TypeTemplate, Template: "{{firstName}}", MaxDistinctValue: 10}},
+		{name: "generator in an expression", config: generators.Config{Type: generators.TypeExpression, Expression: "$n + 1"}},
+	}
+	for _, tt := range invalidTemplates {
+		t.Run(tt.name, func(t *testing.T) {
+			invalidContent := append(append(generators.Content{}, content...),
+				generators.Field{Name: "invalid", Config: tt.config},
+				generators.Field{Name: "after", Config: generators.Config{Type: generators.TypeBoolean}},
+			)
+			if _, err := ci.NewDocumentGenerator(invalidContent); err == nil {
+				t.Error("expected an error, but got none")
+			}
+		})
+	}
+}
+
+func TestReferenceCardinality(t *testing.T) {
+
+	nbParents := 100
+	references := generators.References{}
+	parentCi := generators.NewCollInfo(nbParents, []int{3, 6}, defaultSeed, references)
+	_, err := parentCi.NewDocumentGenerator(generators.Content{
+		{Name: "_id", Config: generators.Config{
+			Type:       generators.TypeReference,
+			ID:         1,
+			RefContent: &generators.Config{Type: generators.TypeAutoincrement, AutoType: generators.TypeInt},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cardinalityTests := []struct {
+		name    string
+		count   int
+		config  generators.Config
+		correct bool
+	}{
+		{
+			name:    "min and max",
+			count:   300,
+			config:  generators.Config{MinChildren: 1, MaxChildren: 5},
+			correct: true,
+		},
+		{
+			name:    "all parents at max",
+			count:   500,
+			config:  generators.Config{MinChildren: 1, MaxChildren: 5},
+			correct: true,
+		},
+		{
+			name:    "min only",
+			count:   450,
+			config:  generators.Config{MinChildren: 2},
+			correct: true,
+		},
+		{
+			name:    "max only",
+			count:   1000,
+			config:  generators.Config{MaxChildren: 20},
+			correct: true,
+		},
+		{
+			name:    "normal distribution",
+			count:   1000,
+			config:  generators.Config{MaxChildren: 20, Distribution: generators.DistributionNormal, Mean: "10", StdDev: "3"},
+			correct: true,
+		},
+		{
+			name:    "poisson distribution",
+			count:   300,
+			config:  generators.Config{MinChildren: 1, Distribution: generators.DistributionPoisson, Lambda: "2"},
+			correct: true,
+		},
+		{
+			name:    "too few documents",
+			count:   100,
+			config:  generators.Config{MinChildren: 2},
+			correct: false,
+		},
+		{
+			name:    "too many documents",
+			count:   501,
+			config:  generators.Config{MinChildren: 1, MaxChildren: 5},
+			correct: false,
+		},
+		{
+			name:    "min greater than max",
+			count:   300,
+			config:  generators.Config{MinChildren: 5, MaxChildren: 1},
+			correct: false,
+		},
+		{
+			name:    "negative min",
+			count:   300,
+			config:  generators.Config{MinChildren: -1},
+			correct: false,
+		},
+		{
+			name:    "invalid distribution",
+			count:   300,
+			config:  generators.Config{MaxChildren: 5, Distribution: "unknown"},
+			correct: false,
+		},
+		{
+			name:    "missing values",
+			count:   300,
+			config:  generators.Config{MinChildren: 1, MissingPercentage: 10},
+			correct: false,
+		},
+		{
+			name:    "maxDistinctValue",
+			count:   300,
+			config:  generators.Config{MinChildren: 1, MaxDistinctValue: 10},
+			correct: false,
+		},
+		{
+			name:  "with refContent",
+			count: 300,
+			config: generators.Config{
+				MinChildren: 1,
+				RefContent:  &generators.Config{Type: generators.TypeAutoincrement, AutoType: generators.TypeInt},
+			},
+			correct: false,
+		},
+		{
+			name:    "inOrder",
+			count:   300,
+			config:  generators.Config{MinChildren: 1, InOrder: true},
+			correct: false,
+		},
+	}
+
+	for _, tt := range cardinalityTests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			config := tt.config
+			config.Type = generators.TypeReference
+			config.ID = 1
+			if config.RefContent != nil {
+				config.ID = 2
+			}
+
+			ci := generators.NewCollInfo(tt.count, []int{3, 6}, defaultSeed, references)
+			docGenerator, err := ci.NewDocumentGenerator(generators.Content{{Name: "parentId", Config: config}})
+			if !tt.correct {
+				if err == nil {
+					t.Error("expected an error, but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			docs := make([][]byte, tt.count)
+			children := make([]int, nbParents)
+			for i := range docs {
+				docs[i] = append([]byte(nil), docGenerator.Generate()...)
+				children[bson.Raw(docs[i]).Lookup("parentId").Int32()]++
+			}
+			maxChildren := tt.config.MaxChildren
+			if maxChildren == 0 {
+				maxChildren = tt.count
+			}
+			for parent, n := range children {
+				if n < tt.config.MinChildren || n > maxChildren {
+					t.Errorf("expected between %d and %d children for parent %d, but got %d", tt.config.MinChildren, maxChildren, parent, n)
+				}
+			}
+
+			// the same documents are generated after a seek
+			docGenerator, err = ci.NewDocumentGenerator(generators.Content{{Name: "parentId", Config: config}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := tt.count / 2
+			docGenerator.Seek(start)
+			for i := start; i < tt.count; i++ {
+				if got := docGenerator.Generate(); !bytes.Equal(docs[i], got) {
+					t.Fatalf("doc %d differs after seeking to %d: expected %v but got %v", i, start, bson.Raw(docs[i]), bson.Raw(got))
+				}
+			}
+		})
+	}
+
+	// children of the normal distribution are centered on the mean
+	ci := generators.NewCollInfo(1000, []int{3, 6}, defaultSeed, references)
+	docGenerator, err := ci.NewDocumentGenerator(generators.Content{{Name: "parentId", Config: generators.Config{
+		Type: generators.TypeReference, ID: 1, MaxChildren: 20, Distribution: generators.DistributionNormal, Mean: "10", StdDev: "2",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	children := make([]int, nbParents)
+	for i := 0; i < 1000; i++ {
+		children[bson.Raw(docGenerator.Generate()).Lookup("parentId").Int32()]++
+	}
+	aroundMean := 0
+	for _, n := range children {
+		if n >= 6 && n <= 14 {
+			aroundMean++
+		}
+	}
+	if aroundMean < 90 {
+		t.Errorf("expected most parents to have between 6 and 14 children, but only %d have", aroundMean)
+	}
+}
+
+func TestReferenceFields(t *testing.T) {
+
+	nbParents := 50
+	references := generators.References{}
+
+	parentContent := generators.Content{
+		{Name: "_id", Config: generators.Config{
+			Type:       generators.TypeReference,
+			ID:         1,
+			RefContent: &generators.Config{Type: generators.TypeObjectID},
+		}},
+		{Name: "name", Config: generators.Config{Type: generators.TypeFaker, Method: generators.MethodName}},
+		{Name: "address", Config: generators.Config{Type: generators.TypeObject, ObjectContent: generators.Content{
+			{Name: "city", Config: generators.Config{Type: generators.TypeFaker, Method: generators.MethodCity}},
+			{Name: "zip", Config: generators.Config{Type: generators.TypeInt, Min: "1", Max: "99999"}},
+		}}},
+		{Name: "code", Config: generators.Config{Type: generators.TypeString, MinLength: "2", MaxLength: "2"}},
+		{Name: "maybe", Config: generators.Config{
+			Type:           generators.TypeReference,
+			ID:             2,
+			RefContent:     &generators.Config{Type: generators.TypeInt, Min: "1", Max: "10"},
+			NullPercentage: 10,
+		}},
+	}
+	parentCi := generators.NewCollInfo(nbParents, []int{3, 6}, defaultSeed, references)
+	parentGenerator, err := parentCi.NewDocumentGenerator(parentContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parentCi.NewUniqueFieldsFilter(parentContent, [][]string{{"code"}}); err != nil {
+		t.Fatal(err)
+	}
+	parents := make([]bson.Raw, nbParents)
+	for i := range parents {
+		parents[i] = append(bson.Raw(nil), parentGenerator.Generate()...)
 	}
-	// all possible faker methods
-	fakerVal := []string{
-		"CellPhoneNumber",
-		"CityPrefix",
-		"CitySuffix",
-		"CompanyBs",
-		"CompanyCatchPhrase",
-		"CompanyName",
-		"DomainWord",
-		"FreeEmail",
-		"PhoneNumber",
-		"PostCode",
-		"SafeEmail",
-		"SecondaryAddress",
-		"StateAbbr",
-		"StreetAddress",
-		"UserName",
-		generators.MethodAnimal,
-		generators.MethodAnimalType,
-		generators.MethodBS,
-		generators.MethodBeerAlcohol,
-		generators.MethodBeerBlg,
-		generators.MethodBeerHop,
-		generators.MethodBeerIbu,
-		generators.MethodBeerMalt,
-		generators.MethodBeerName,
-		generators.MethodBeerStyle,
-		generators.MethodBeerYeast,
-		generators.MethodBuzzWord,
-		generators.MethodCarMaker,
-		generators.MethodCarModel,
-		generators.MethodCat,
-		generators.MethodChromeUserAgent,
-		generators.MethodColor,
-		generators.MethodCity,
-		generators.MethodCompany,
-		generators.MethodCompanySuffix,
-		generators.MethodCountry,
-		generators.MethodCountryAbr,
-		generators.MethodCreditCardCvv,
-		generators.MethodCreditCardExp,
-		generators.MethodCreditCardType,
-		generators.MethodCurrencyLong,
-		generators.MethodCurrencyShort,
-		generators.MethodDog,
-		generators.MethodDomainName,
-		generators.MethodDomainSuffix,
-		generators.MethodEmail,
-		generators.MethodEmoji,
-		generators.MethodEmojiAlias,
-		generators.MethodEmojiCategory,
-		generators.MethodEmojiDescription,
-		generators.MethodEmojiTag,
-		generators.MethodFileExtension,
-		generators.MethodFarmAnimal,
-		generators.MethodFirefoxUserAgent,
-		generators.MethodFirstName,
-		generators.MethodCarFuelType,
-		generators.MethodGender,
-		generators.MethodHTTPMethod,
-		generators.MethodHackerAbbreviation,
-		generators.MethodHackerAdjective,
-		generators.MethodHackeringVerb,
-		generators.MethodHackerNoun,
-		generators.MethodHackerPhrase,
-		generators.MethodHackerVerb,
-		generators.MethodHexColor,
-		generators.MethodHipsterWord,
-		generators.MethodIPv4Address,
-		generators.MethodIPv6Address,
-		generators.MethodJobDescriptor,
-		generators.MethodJobLevel,
-		generators.MethodJobTitle,
-		generators.MethodLanguage,
-		generators.MethodLanguageAbbreviation,
-		generators.MethodLastName,
-		generators.MethodLetter,
-		generators.MethodMacAddress,
-		generators.MethodFileMimeType,
-		generators.MethodMonth,
-		generators.MethodName,
-		generators.MethodNamePrefix,
-		generators.MethodNameSuffix,
-		generators.MethodOperaUserAgent,
-		generators.MethodPetName,
-		generators.MethodPhone,
-		generators.MethodPhoneFormatted,
-		generators.MethodProgrammingLanguage,
-		generators.MethodProgrammingLanguageBest,
-		generators.MethodQuestion,
-		generators.MethodQuote,
-		generators.MethodSSN,
-		generators.MethodSafariUserAgent,
-		generators.MethodSafeColor,
-		generators.MethodState,
-		generators.MethodStateAbr,
-		generators.MethodStreet,
-		generators.MethodStreetName,
-		generators.MethodStreetNumber,
-		generators.MethodStreetPrefix,
-		generators.MethodStreetSuffix,
-		generators.MethodTimeZone,
-		generators.MethodTimeZoneAbv,
-		generators.MethodTimeZoneFull,
-		generators.MethodCarTransmissionType,
-		generators.MethodURL,
-		generators.MethodUserAgent,
-		generators.MethodUsername,
-		generators.MethodCarType,
-		generators.MethodWeekDay,
-		generators.MethodWord,
-		generators.MethodZip,
-	}
 
-	for _, f := range fakerVal {
-		newGeneratorTests = append(newGeneratorTests, testCase{
-			name: fmt.Sprintf(`faker generator with method "%s"`, f),
-			config: generators.Config{
-				Type:   generators.TypeFaker,
-				Method: f,
+	fieldsTests := []struct {
+		name    string
+		content generators.Content
+		correct bool
+	}{
+		{
+			name: "fields",
+			content: generators.Content{
+				{Name: "customerId", Config: generators.Config{Type: generators.TypeReference, ID: 1}},
+				{Name: "customer", Config: generators.Config{Type: generators.TypeReference, ID: 1, Fields: []string{"name", "_id", "address.city", "unknown"}}},
 			},
 			correct: true,
-			version: []int{3, 6},
+		},
+		{
+			name: "nested with number of children",
+			content: generators.Content{
+				{Name: "customerId", Config: generators.Config{Type: generators.TypeReference, ID: 1, MinChildren: 1, MaxChildren: 4}},
+				{Name: "order", Config: generators.Config{Type: generators.TypeObject, ObjectContent: generators.Content{
+					{Name: "customer", Config: generators.Config{Type: generators.TypeReference, ID: 1, Fields: []string{"_id", "address"}}},
+				}}},
+			},
+			correct: true,
+		},
+		{
+			name: "empty fields",
+			content: generators.Content{
+				{Name: "customer", Config: generators.Config{Type: generators.TypeReference, ID: 1, Fields: []string{}}},
+			},
+			correct: false,
+		},
+		{
+			name: "invalid field",
+			content: generators.Content{
+				{Name: "customer", Config: generators.Config{Type: generators.TypeReference, ID: 1, Fields: []string{"address."}}},
+			},
+			correct: false,
+		},
+		{
+			name: "field used in uniqueFields",
+			content: generators.Content{
+				{Name: "customer", Config: generators.Config{Type: generators.TypeReference, ID: 1, Fields: []string{"_id", "code"}}},
+			},
+			correct: false,
+		},
+		{
+			name: "nullable reference",
+			content: generators.Content{
+				{Name: "customer", Config: generators.Config{Type: generators.TypeReference, ID: 2, Fields: []string{"_id"}}},
+			},
+			correct: false,
+		},
+		{
+			name: "inside an array",
+			content: generators.Content{
+				{Name: "customers", Config: generators.Config{Type: generators.TypeArray, Size: 2, ArrayContent: &generators.Config{
+					Type: generators.TypeReference, ID: 1, Fields: []string{"_id"},
+				}}},
+			},
+			correct: false,
+		},
+		{
+			name: "different number of children",
+			content: generators.Content{
+				{Name: "customerId", Config: generators.Config{Type: generators.TypeReference, ID: 1, MinChildren: 1, MaxChildren: 4}},
+				{Name: "customer", Config: generators.Config{Type: generators.TypeReference, ID: 1, Fields: []string{"_id"}, MinChildren: 2, MaxChildren: 4}},
+			},
+			correct: false,
+		},
+		{
+			name: "with maxDistinctValue",
+			content: generators.Content{
+				{Name: "customer", Config: generators.Config{Type: generators.TypeReference, ID: 1, Fields: []string{"_id"}, MaxDistinctValue: 5}},
+			},
+			correct: false,
+		},
+	}
+
+	for _, tt := range fieldsTests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ci := generators.NewCollInfo(150, []int{3, 6}, defaultSeed, references)
+			docGenerator, err := ci.NewDocumentGenerator(tt.content)
+			if !tt.correct {
+				if err == nil {
+					t.Error("expected an error, but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			byID := map[primitive.ObjectID]bson.Raw{}
+			for _, p := range parents {
+				byID[p.Lookup("_id").ObjectID()] = p
+			}
+			for i := 0; i < ci.Count; i++ {
+				doc := bson.Raw(docGenerator.Generate())
+				customer, ok := doc.Lookup("customer").DocumentOK()
+				if !ok {
+					customer = doc.Lookup("order", "customer").Document()
+				}
+				parent, ok := byID[customer.Lookup("_id").ObjectID()]
+				if !ok {
+					t.Fatalf("doc %d references an unknown document: %v", i, doc)
+				}
+				if id := doc.Lookup("customerId"); id.ObjectID() != parent.Lookup("_id").ObjectID() {
+					t.Errorf("doc %d: expected 'customerId' to be the '_id' of the copied document, but got %v", i, doc)
+				}
+				elements, _ := customer.Elements()
+				for _, e := range elements {
+					switch e.Key() {
+					case "address":
+						nested, _ := e.Value().Document().Elements()
+						for _, n := range nested {
+							if !bytes.Equal(n.Value().Value, parent.Lookup("address", n.Key()).Value) {
+								t.Errorf("doc %d: 'address.%s' differs from the referenced document: %v", i, n.Key(), doc)
+							}
+						}
+					case "_id", "name":
+						if !bytes.Equal(e.Value().Value, parent.Lookup(e.Key()).Value) {
+							t.Errorf("doc %d: '%s' differs from the referenced document: %v", i, e.Key(), doc)
+						}
+					default:
+						t.Errorf("doc %d: unexpected field '%s' in copy %v", i, e.Key(), customer)
+					}
+				}
+			}
 		})
 	}
+}
 
-	ci := generators.NewCollInfo(100, nil, defaultSeed, map[int][][]byte{}, map[int]bsontype.Type{})
+func TestReferenceValuesAcrossChunks(t *testing.T) {
 
-	for _, tt := range newGeneratorTests {
+	// values of references aren't stored but generated again by chunks, so
+	// use collections spanning several chunks
+	nbParents, nbChildren, nbDistinct := 2500, 7000, 1500
+	references := generators.References{}
+
+	parentCi := generators.NewCollInfo(nbParents, []int{3, 6}, defaultSeed, references)
+	parentGenerator, err := parentCi.NewDocumentGenerator(generators.Content{
+		{Name: "_id", Config: generators.Config{
+			Type:       generators.TypeReference,
+			ID:         1,
+			RefContent: &generators.Config{Type: generators.TypeString, MinLength: "12", MaxLength: "12", Unique: true},
+		}},
+		{Name: "tag", Config: generators.Config{Type: generators.TypeString, MinLength: "12", MaxLength: "12", MaxDistinctValue: nbDistinct}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parents := map[string]string{}
+	tags := map[string]bool{}
+	firstTags := make([]string, nbDistinct)
+	for i := 0; i < nbParents; i++ {
+		doc := bson.Raw(parentGenerator.Generate())
+		id, tag := doc.Lookup("_id").StringValue(), doc.Lookup("tag").StringValue()
+		if _, ok := parents[id]; ok {
+			t.Fatalf("duplicated value '%s' for reference", id)
+		}
+		parents[id] = tag
+		tags[tag] = true
+		if i < nbDistinct {
+			firstTags[i] = tag
+		} else if expected := firstTags[i-nbDistinct]; tag != expected {
+			t.Errorf("doc %d: expected tag '%s', but got '%s'", i, expected, tag)
+		}
+	}
+	if len(tags) != nbDistinct {
+		t.Errorf("expected %d distinct tags, but got %d", nbDistinct, len(tags))
+	}
+
+	content := generators.Content{
+		{Name: "parentId", Config: generators.Config{Type: generators.TypeReference, ID: 1, MinChildren: 1, MaxChildren: 5}},
+		{Name: "parent", Config: generators.Config{Type: generators.TypeReference, ID: 1, Fields: []string{"_id", "tag"}}},
+	}
+	ci := generators.NewCollInfo(nbChildren, []int{3, 6}, defaultSeed, references)
+	docGenerator, err := ci.NewDocumentGenerator(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := make([][]byte, nbChildren)
+	children := map[string]int{}
+	for i := range docs {
+		docs[i] = append([]byte(nil), docGenerator.Generate()...)
+		doc := bson.Raw(docs[i])
+		id := doc.Lookup("parentId").StringValue()
+		tag, ok := parents[id]
+		if !ok {
+			t.Fatalf("doc %d references an unknown document: %v", i, doc)
+		}
+		children[id]++
+		if copyID, copyTag := doc.Lookup("parent", "_id").StringValue(), doc.Lookup("parent", "tag").StringValue(); copyID != id || copyTag != tag {
+			t.Errorf("doc %d: expected a copy of parent '%s' with tag '%s', but got %v", i, id, tag, doc)
+		}
+	}
+	if len(children) != nbParents {
+		t.Errorf("expected all the %d parents to have children, but only %d have", nbParents, len(children))
+	}
+	for id, n := range children {
+		if n < 1 || n > 5 {
+			t.Errorf("expected between 1 and 5 children for parent '%s', but got %d", id, n)
+		}
+	}
+
+	// the same documents are generated after a seek
+	docGenerator, err = ci.NewDocumentGenerator(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := 4321
+	docGenerator.Seek(start)
+	for i := start; i < nbChildren; i++ {
+		if got := docGenerator.Generate(); !bytes.Equal(docs[i], got) {
+			t.Fatalf("doc %d differs after seeking to %d: expected %v but got %v", i, start, bson.Raw(docs[i]), bson.Raw(got))
+		}
+	}
+}
+
+func TestRandomReferences(t *testing.T) {
+
+	nbParents, nbChildren := 2500, 3000
+	references := generators.References{}
+
+	parentCi := generators.NewCollInfo(nbParents, []int{3, 6}, defaultSeed, references)
+	parentGenerator, err := parentCi.NewDocumentGenerator(generators.Content{
+		{Name: "_id", Config: generators.Config{
+			Type:       generators.TypeReference,
+			ID:         1,
+			RefContent: &generators.Config{Type: generators.TypeString, MinLength: "12", MaxLength: "12", Unique: true},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, nbParents)
+	known := map[string]bool{}
+	for i := range ids {
+		ids[i] = bson.Raw(parentGenerator.Generate()).Lookup("_id").StringValue()
+		known[ids[i]] = true
+	}
+
+	content := generators.Content{
+		{Name: "parentId", Config: generators.Config{Type: generators.TypeReference, ID: 1}},
+		{Name: "parent", Config: generators.Config{Type: generators.TypeReference, ID: 1, Fields: []string{"_id"}}},
+		{Name: "inOrder", Config: generators.Config{Type: generators.TypeReference, ID: 1, InOrder: true}},
+		{Name: "parentIds", Config: generators.Config{Type: generators.TypeArray, Size: 3, ArrayContent: &generators.Config{Type: generators.TypeReference, ID: 1}}},
+	}
+	ci := generators.NewCollInfo(nbChildren, []int{3, 6}, defaultSeed, references)
+	docGenerator, err := ci.NewDocumentGenerator(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := make([][]byte, nbChildren)
+	children := map[string]int{}
+	for i := range docs {
+		docs[i] = append([]byte(nil), docGenerator.Generate()...)
+		doc := bson.Raw(docs[i])
+		id := doc.Lookup("parentId").StringValue()
+		if !known[id] {
+			t.Fatalf("doc %d references an unknown document: %v", i, doc)
+		}
+		children[id]++
+		if copyID := doc.Lookup("parent", "_id").StringValue(); copyID != id {
+			t.Errorf("doc %d: expected a copy of parent '%s', but got %v", i, id, doc)
+		}
+		if expected, got := ids[i%nbParents], doc.Lookup("inOrder").StringValue(); got != expected {
+			t.Errorf("doc %d: expected 'inOrder' to be '%s', but got '%s'", i, expected, got)
+		}
+		elements, _ := doc.Lookup("parentIds").Array().Values()
+		for _, e := range elements {
+			if !known[e.StringValue()] {
+				t.Fatalf("doc %d references an unknown document: %v", i, doc)
+			}
+		}
+		if elements[0].StringValue() == elements[1].StringValue() && elements[1].StringValue() == elements[2].StringValue() {
+			t.Errorf("doc %d: expected the elements of 'parentIds' to be picked independently, but got %v", i, doc)
+		}
+	}
+	// parents picked at random: about exp(-1.2) = 30% of the
+	// parents have no children, and some have several
+	maxChildren := 0
+	for _, n := range children {
+		if n > maxChildren {
+			maxChildren = n
+		}
+	}
+	if len(children) < nbParents*60/100 || len(children) > nbParents*80/100 {
+		t.Errorf("expected about 70%% of the %d parents to have children, but %d have", nbParents, len(children))
+	}
+	if maxChildren < 4 {
+		t.Errorf("expected some parents to have at least 4 children, but the maximum is %d", maxChildren)
+	}
+
+	// the same documents are generated after a seek
+	docGenerator, err = ci.NewDocumentGenerator(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := 1234
+	docGenerator.Seek(start)
+	for i := start; i < nbChildren; i++ {
+		if got := docGenerator.Generate(); !bytes.Equal(docs[i], got) {
+			t.Fatalf("doc %d differs after seeking to %d: expected %v but got %v", i, start, bson.Raw(docs[i]), bson.Raw(got))
+		}
+	}
+
+	// the values are always used in turn by the field with 'refContent'
+	ci = generators.NewCollInfo(10, []int{3, 6}, defaultSeed, references)
+	_, err = ci.NewDocumentGenerator(generators.Content{
+		{Name: "_id", Config: generators.Config{Type: generators.TypeReference, ID: 2, InOrder: true, RefContent: &generators.Config{Type: generators.TypeInt}}},
+	})
+	if err == nil {
+		t.Error("expected an error for 'inOrder' with 'refContent', but got none")
+	}
+}
+
+func TestTree(t *testing.T) {
+
+	treeTests := []struct {
+		name   string
+		count  int
+		fanOut int
+		depth  int
+	}{
+		{name: "single tree", count: 2500, fanOut: 3},
+		{name: "several trees", count: 2500, fanOut: 4, depth: 2},
+		{name: "single node", count: 1, fanOut: 2},
+	}
+
+	for _, tt := range treeTests {
 		t.Run(tt.name, func(t *testing.T) {
-			ci.Version = tt.version
-			var content = map[string]generators.Config{
-				"k": tt.config,
+
+			content := generators.Content{
+				{Name: "_id", Config: generators.Config{
+					Type:       generators.TypeReference,
+					ID:         1,
+					RefContent: &generators.Config{Type: generators.TypeString, MinLength: "8", MaxLength: "8", Unique: true},
+				}},
 			}
-			_, err := ci.NewDocumentGenerator(content)
-			if tt.correct && err != nil {
-				t.Errorf("expected no error for generator with config %v \nbut got \n%v", tt.config, err)
+			for _, node := range []string{generators.TreeParent, generators.TreeAncestors, generators.TreeChildren, generators.TreePath, generators.TreeDepth, generators.TreeLeft, generators.TreeRight} {
+				content = append(content, generators.Field{Name: node, Config: generators.Config{Type: generators.TypeTree, ID: 1, Node: node, FanOut: tt.fanOut, Depth: tt.depth, Separator: "/"}})
 			}
-			if !tt.correct && err == nil {
-				t.Errorf("generator with config %v should fail", tt.config)
+			ci := generators.NewCollInfo(tt.count, []int{3, 6}, defaultSeed, nil)
+			docGenerator, err := ci.NewDocumentGenerator(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			docs := map[string]bson.Raw{}
+			var ids []string
+			for i := 0; i < tt.count; i++ {
+				doc := bson.Raw(append([]byte(nil), docGenerator.Generate()...))
+				id := doc.Lookup("_id").StringValue()
+				docs[id] = doc
+				ids = append(ids, id)
+			}
+
+			ancestorsOf := func(doc bson.Raw) []string {
+				var ancestors []string
+				values, _ := doc.Lookup("ancestors").Array().Values()
+				for _, v := range values {
+					ancestors = append(ancestors, v.StringValue())
+				}
+				return ancestors
+			}
+
+			roots, nbChildren := 0, 0
+			sizes := map[string]int{}
+			bounds := map[int32]bool{}
+			for _, id := range ids {
+				doc := docs[id]
+				ancestors := ancestorsOf(doc)
+				if depth := doc.Lookup("depth").Int32(); int(depth) != len(ancestors) {
+					t.Errorf("expected a depth of %d, but got %v", len(ancestors), doc)
+				}
+				if tt.depth != 0 && len(ancestors) > tt.depth {
+					t.Errorf("expected a depth of at most %d, but got %v", tt.depth, doc)
+				}
+				if len(ancestors) == 0 {
+					roots++
+					if doc.Lookup("parent").Type != bson.TypeNull || doc.Lookup("path").Type != bson.TypeNull {
+						t.Errorf("expected a null parent and path for a root, but got %v", doc)
+					}
+				} else {
+					parentID := doc.Lookup("parent").StringValue()
+					if parentID != ancestors[len(ancestors)-1] {
+						t.Errorf("expected the parent to be the last ancestor, but got %v", doc)
+					}
+					parent, ok := docs[parentID]
+					if !ok {
+						t.Fatalf("unknown parent '%s' in %v", parentID, doc)
+					}
+					if expected := fmt.Sprint(append(ancestorsOf(parent), parentID)); expected != fmt.Sprint(ancestors) {
+						t.Errorf("expected ancestors %s, but got %v", expected, doc)
+					}
+					if expected := "/" + strings.Join(ancestors, "/") + "/"; doc.Lookup("path").StringValue() != expected {
+						t.Errorf("expected path '%s', but got %v", expected, doc)
+					}
+					found := false
+					values, _ := parent.Lookup("children").Array().Values()
+					for _, v := range values {
+						found = found || v.StringValue() == id
+					}
+					if !found {
+						t.Errorf("expected '%s' to be a child of its parent %v", id, parent)
+					}
+				}
+				values, _ := doc.Lookup("children").Array().Values()
+				if len(values) > tt.fanOut {
+					t.Errorf("expected at most %d children, but got %v", tt.fanOut, doc)
+				}
+				for _, v := range values {
+					if docs[v.StringValue()].Lookup("parent").StringValue() != id {
+						t.Errorf("expected child '%s' to have '%s' as parent", v.StringValue(), id)
+					}
+				}
+				nbChildren += len(values)
+
+				left, right := doc.Lookup("left").Int32(), doc.Lookup("right").Int32()
+				bounds[left], bounds[right] = true, true
+				sizes[id]++
+				for _, a := range ancestors {
+					sizes[a]++
+					ancestor := docs[a]
+					if left <= ancestor.Lookup("left").Int32() || right >= ancestor.Lookup("right").Int32() {
+						t.Errorf("expected the bounds of %v to be inside the bounds of its ancestor %v", doc, ancestor)
+					}
+				}
+			}
+			if tt.depth == 0 && roots != 1 {
+				t.Errorf("expected a single root, but got %d", roots)
+			}
+			if nbChildren != tt.count-roots {
+				t.Errorf("expected %d children in total, but got %d", tt.count-roots, nbChildren)
+			}
+			for i := int32(1); i <= int32(2*tt.count); i++ {
+				if !bounds[i] {
+					t.Fatalf("expected left and right values to use all the values from 1 to %d, but %d is missing", 2*tt.count, i)
+				}
+			}
+			for _, id := range ids {
+				doc := docs[id]
+				if width := doc.Lookup("right").Int32() - doc.Lookup("left").Int32() + 1; int(width) != 2*sizes[id] {
+					t.Errorf("expected bounds covering the %d nodes of the subtree, but got %v", sizes[id], doc)
+				}
+			}
+		})
+	}
+
+	invalidTests := []struct {
+		name    string
+		content generators.Content
+	}{
+		{
+			name: "invalid node",
+			content: generators.Content{
+				{Name: "parent", Config: generators.Config{Type: generators.TypeTree, ID: 1, Node: "sibling", FanOut: 2}},
+			},
+		},
+		{
+			name: "invalid fanOut",
+			content: generators.Content{
+				{Name: "parent", Config: generators.Config{Type: generators.TypeTree, ID: 1, Node: generators.TreeParent}},
+			},
+		},
+		{
+			name: "fanOut of 1 without depth",
+			content: generators.Content{
+				{Name: "parent", Config: generators.Config{Type: generators.TypeTree, ID: 1, Node: generators.TreeParent, FanOut: 1}},
+			},
+		},
+		{
+			name: "negative depth",
+			content: generators.Content{
+				{Name: "parent", Config: generators.Config{Type: generators.TypeTree, ID: 1, Node: generators.TreeParent, FanOut: 2, Depth: -1}},
+			},
+		},
+		{
+			name: "unknown reference",
+			content: generators.Content{
+				{Name: "parent", Config: generators.Config{Type: generators.TypeTree, ID: 2, Node: generators.TreeParent, FanOut: 2}},
+			},
+		},
+		{
+			name: "different fanOut",
+			content: generators.Content{
+				{Name: "parent", Config: generators.Config{Type: generators.TypeTree, ID: 1, Node: generators.TreeParent, FanOut: 2}},
+				{Name: "depth", Config: generators.Config{Type: generators.TypeTree, ID: 1, Node: generators.TreeDepth, FanOut: 3}},
+			},
+		},
+		{
+			name: "inside an array",
+			content: generators.Content{
+				{Name: "parents", Config: generators.Config{
+					Type:         generators.TypeArray,
+					MinLength:    "2",
+					MaxLength:    "2",
+					ArrayContent: &generators.Config{Type: generators.TypeTree, ID: 1, Node: generators.TreeParent, FanOut: 2},
+				}},
+			},
+		},
+		{
+			name: "with maxDistinctValue",
+			content: generators.Content{
+				{Name: "parent", Config: generators.Config{Type: generators.TypeTree, ID: 1, Node: generators.TreeParent, FanOut: 2, MaxDistinctValue: 5}},
+			},
+		},
+	}
+
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := append(generators.Content{
+				{Name: "_id", Config: generators.Config{Type: generators.TypeReference, ID: 1, RefContent: &generators.Config{Type: generators.TypeObjectID}}},
+			}, tt.content...)
+			ci := generators.NewCollInfo(100, []int{3, 6}, defaultSeed, nil)
+			if _, err := ci.NewDocumentGenerator(content); err == nil {
+				t.Error("expected an error, but got none")
 			}
 		})
 	}
+
+	// the ids have to be the values of a field present in all the documents
+	ci := generators.NewCollInfo(100, []int{3, 6}, defaultSeed, nil)
+	_, err := ci.NewDocumentGenerator(generators.Content{
+		{Name: "_id", Config: generators.Config{Type: generators.TypeReference, ID: 1, NullPercentage: 10, RefContent: &generators.Config{Type: generators.TypeObjectID}}},
+		{Name: "parent", Config: generators.Config{Type: generators.TypeTree, ID: 1, Node: generators.TreeParent, FanOut: 2}},
+	})
+	if err == nil {
+		t.Error("expected an error for ids that can be null, but got none")
+	}
 }
 
-func TestNewGeneratorFromMap(t *testing.T) {
+func TestEdges(t *testing.T) {
 
-	contentList := loadCollConfig(t, "ref.json")
+	nbNodes, nbEdges := 2500, 12000
+	references := generators.References{}
+	nodesCi := generators.NewCollInfo(nbNodes, []int{3, 6}, defaultSeed, references)
+	_, err := nodesCi.NewDocumentGenerator(generators.Content{
+		{Name: "_id", Config: generators.Config{
+			Type:       generators.TypeReference,
+			ID:         1,
+			RefContent: &generators.Config{Type: generators.TypeAutoincrement, AutoType: "int"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	generatorFromMapTests := []struct {
-		name         string
-		config       map[string]generators.Config
-		correct      bool
-		nbGenerators int
+	content := generators.Content{
+		{Name: "from", Config: generators.Config{
+			Type:         generators.TypeReference,
+			ID:           1,
+			Edge:         generators.EdgeFrom,
+			MinChildren:  1,
+			MaxChildren:  100,
+			Distribution: generators.DistributionZipf,
+			Reciprocity:  "0.25",
+		}},
+		{Name: "to", Config: generators.Config{Type: generators.TypeReference, ID: 1, Edge: generators.EdgeTo}},
+	}
+	ci := generators.NewCollInfo(nbEdges, []int{3, 6}, defaultSeed, references)
+	docGenerator, err := ci.NewDocumentGenerator(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type edge struct{ from, to int32 }
+	docs := make([][]byte, nbEdges)
+	edges := map[edge]bool{}
+	outs, ins := map[int32]int{}, map[int32]int{}
+	for i := range docs {
+		docs[i] = append([]byte(nil), docGenerator.Generate()...)
+		doc := bson.Raw(docs[i])
+		e := edge{doc.Lookup("from").Int32(), doc.Lookup("to").Int32()}
+		if e.from == e.to {
+			t.Errorf("doc %d: unexpected self-loop %v", i, doc)
+		}
+		if edges[e] {
+			t.Errorf("doc %d: duplicated edge %v", i, doc)
+		}
+		edges[e] = true
+		outs[e.from]++
+		ins[e.to]++
+	}
+	reciprocal := 0
+	for e := range edges {
+		if edges[edge{e.to, e.from}] {
+			reciprocal++
+		}
+	}
+	if reciprocal != nbEdges/4 {
+		t.Errorf("expected %d reciprocal edges, but got %d", nbEdges/4, reciprocal)
+	}
+
+	// the statistics are the ones of the generated edges
+	stats := ci.GraphStats()
+	if len(stats) != 1 {
+		t.Fatalf("expected statistics for a single graph, but got %v", stats)
+	}
+	if s := stats[0]; s.ID != 1 || s.Nodes != nbNodes || s.Edges != nbEdges || s.Reciprocity != 0.25 {
+		t.Errorf("invalid graph statistics %+v", s)
+	}
+	for name, degrees := range map[string]map[int32]int{"out": outs, "in": ins} {
+		expected := generators.DegreeStats{Min: nbEdges, Mean: float64(nbEdges) / float64(nbNodes)}
+		if len(degrees) < nbNodes {
+			expected.Min = 0
+		}
+		for _, n := range degrees {
+			if n < expected.Min {
+				expected.Min = n
+			}
+			if n > expected.Max {
+				expected.Max = n
+			}
+		}
+		got := stats[0].OutDegree
+		if name == "in" {
+			got = stats[0].InDegree
+		}
+		if got.Min != expected.Min || got.Max != expected.Max || got.Mean != expected.Mean || got.Median < got.Min || got.P99 < got.Median || got.Max < got.P99 {
+			t.Errorf("expected %s degrees %+v, but got %+v", name, expected, got)
+		}
+	}
+
+	// the same documents are generated after a seek
+	docGenerator, err = ci.NewDocumentGenerator(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := 7777
+	docGenerator.Seek(start)
+	for i := start; i < nbEdges; i++ {
+		if doc := docGenerator.Generate(); !bytes.Equal(doc, docs[i]) {
+			t.Fatalf("doc %d: expected %v after a seek, but got %v", i, bson.Raw(docs[i]), bson.Raw(doc))
+		}
+	}
+
+	invalidTests := []struct {
+		name    string
+		count   int
+		content generators.Content
 	}{
 		{
-			name: "invalid generator",
-			config: map[string]generators.Config{
-				"key": {
-					Type:      generators.TypeString,
-					MinLength: "-1",
-				},
+			name: "invalid edge",
+			content: generators.Content{
+				{Name: "from", Config: generators.Config{Type: generators.TypeReference, ID: 1, Edge: "source"}},
 			},
-			correct:      false,
-			nbGenerators: 0,
-		}, {
-			name:         "ref.json[0]",
-			config:       contentList[0],
-			correct:      true,
-			nbGenerators: 14,
-		}, {
-			name:         "ref.json[1]",
-			config:       contentList[1],
-			correct:      true,
-			nbGenerators: 3,
+		},
+		{
+			name: "invalid reciprocity",
+			content: generators.Content{
+				{Name: "from", Config: generators.Config{Type: generators.TypeReference, ID: 1, Edge: generators.EdgeFrom, Reciprocity: "1.5"}},
+			},
+		},
+		{
+			name: "maxChildren greater than half the nodes",
+			content: generators.Content{
+				{Name: "from", Config: generators.Config{Type: generators.TypeReference, ID: 1, Edge: generators.EdgeFrom, MaxChildren: nbNodes / 2}},
+			},
+		},
+		{
+			name:  "too many edges",
+			count: nbNodes * (nbNodes - 1),
+			content: generators.Content{
+				{Name: "from", Config: generators.Config{Type: generators.TypeReference, ID: 1, Edge: generators.EdgeFrom}},
+			},
+		},
+		{
+			name: "different settings",
+			content: generators.Content{
+				{Name: "from", Config: generators.Config{Type: generators.TypeReference, ID: 1, Edge: generators.EdgeFrom, Reciprocity: "0.5"}},
+				{Name: "to", Config: generators.Config{Type: generators.TypeReference, ID: 1, Edge: generators.EdgeTo, Reciprocity: "0.2"}},
+			},
+		},
+		{
+			name: "with null values",
+			content: generators.Content{
+				{Name: "from", Config: generators.Config{Type: generators.TypeReference, ID: 1, Edge: generators.EdgeFrom, NullPercentage: 10}},
+			},
+		},
+		{
+			name: "with maxDistinctValue",
+			content: generators.Content{
+				{Name: "from", Config: generators.Config{Type: generators.TypeReference, ID: 1, Edge: generators.EdgeFrom, MaxDistinctValue: 10}},
+			},
+		},
+		{
+			name: "nested",
+			content: generators.Content{
+				{Name: "edge", Config: generators.Config{
+					Type: generators.TypeObject,
+					ObjectContent: generators.Content{
+						{Name: "from", Config: generators.Config{Type: generators.TypeReference, ID: 1, Edge: generators.EdgeFrom}},
+					},
+				}},
+			},
+		},
+		{
+			name: "with refContent",
+			content: generators.Content{
+				{Name: "from", Config: generators.Config{Type: generators.TypeReference, ID: 2, Edge: generators.EdgeFrom, RefContent: &generators.Config{Type: generators.TypeObjectID}}},
+			},
+		},
+	}
+
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			count := tt.count
+			if count == 0 {
+				count = nbEdges
+			}
+			ci := generators.NewCollInfo(count, []int{3, 6}, defaultSeed, references)
+			if _, err := ci.NewDocumentGenerator(tt.content); err == nil {
+				t.Error("expected an error, but got none")
+			}
+		})
+	}
+}
+
+func TestSameSeedSameOutput(t *testing.T) {
+
+	sameSeedTests := []struct {
+		name   string
+		config generators.Config
+	}{
+		{
+			name:   "objectId",
+			config: generators.Config{Type: generators.TypeObjectID},
+		},
+		{
+			name:   "string uuid",
+			config: generators.Config{Type: generators.TypeUUID},
+		},
+		{
+			name:   "binary uuid",
+			config: generators.Config{Type: generators.TypeUUID, UUIDFormat: generators.TypeBinary},
+		},
+		{
+			name:   "faker",
+			config: generators.Config{Type: generators.TypeFaker, Method: generators.MethodName},
 		},
 	}
 
-	ci := generators.NewCollInfo(1, []int{3, 4}, defaultSeed, map[int][][]byte{}, map[int]bsontype.Type{})
+	generate := func(t *testing.T, seed uint64, config generators.Config) [][]byte {
+		ci := generators.NewCollInfo(100, []int{3, 6}, seed, generators.References{})
+		docGenerator, err := ci.NewDocumentGenerator(generators.Content{{Name: "k", Config: config}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs := make([][]byte, ci.Count)
+		for i := range docs {
+			docs[i] = append([]byte(nil), docGenerator.Generate()...)
+		}
+		return docs
+	}
 
-	for _, tt := range generatorFromMapTests {
+	for _, tt := range sameSeedTests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := ci.NewDocumentGenerator(tt.config)
-			if tt.correct {
-				if err != nil {
-					t.Errorf("expected no error for config %v \n%v", tt.config, err)
+			first, second := generate(t, 42, tt.config), generate(t, 42, tt.config)
+			for i := range first {
+				if !bytes.Equal(first[i], second[i]) {
+					t.Errorf("doc %d differs for the same seed: %v vs %v", i, bson.Raw(first[i]), bson.Raw(second[i]))
 				}
-			} else if !tt.correct && err == nil {
-				t.Errorf("expected an error for config %v but got none", tt.config)
+			}
+			other := generate(t, 43, tt.config)
+			if bytes.Equal(first[0], other[0]) {
+				t.Errorf("expected different documents for different seeds, got %v", bson.Raw(first[0]))
 			}
 		})
 	}
 }
 
-func loadCollConfig(t *testing.T, filename string) []map[string]generators.Config {
-	bytes, err := os.ReadFile("testdata/" + filename)
+func TestObjectIDDependsOnNamespace(t *testing.T) {
+
+	generate := func(t *testing.T, namespace string) map[primitive.ObjectID]bool {
+		ci := generators.NewCollInfo(100, []int{3, 6}, 42, generators.References{})
+		ci.Namespace = namespace
+		docGenerator, err := ci.NewDocumentGenerator(generators.Content{{Name: "_id", Config: generators.Config{Type: generators.TypeObjectID}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make(map[primitive.ObjectID]bool, ci.Count)
+		for i := 0; i < ci.Count; i++ {
+			ids[bson.Raw(docGenerator.Generate()).Lookup("_id").ObjectID()] = true
+		}
+		return ids
+	}
+
+	first, second := generate(t, "db.first"), generate(t, "db.second")
+	for id := range first {
+		if second[id] {
+			t.Errorf("ObjectId %s is generated in both collections", id.Hex())
+		}
+		if year := id.Timestamp().Year(); year < 2020 || year >= 2030 {
+			t.Errorf("expected a timestamp in [2020, 2030), but got %v", id.Timestamp())
+		}
+	}
+}
+
+func TestAddFieldKeepsOtherValues(t *testing.T) {
+
+	generate := func(t *testing.T, config string) []bson.Raw {
+		var content generators.Content
+		err := json.Unmarshal([]byte(config), &content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ci := generators.NewCollInfo(20, []int{3, 6}, 42, generators.References{})
+		docGenerator, err := ci.NewDocumentGenerator(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs := make([]bson.Raw, ci.Count)
+		for i := range docs {
+			docs[i] = append(bson.Raw(nil), docGenerator.Generate()...)
+		}
+		return docs
+	}
+
+	before := generate(t, `{
+		"int": {"type": "int", "min": 0, "max": 1000, "nullPercentage": 20},
+		"obj": {"type": "object", "objectContent": {
+			"name": {"type": "faker", "method": "Name"}
+		}},
+		"arr": {"type": "array", "arrayContent": {"type": "string", "maxLength": 5}},
+		"str": {"type": "stringFromParts", "parts": [{"type": "uuid"}, {"type": "long"}]}
+	}`)
+	after := generate(t, `{
+		"new": {"type": "double"},
+		"int": {"type": "int", "min": 0, "max": 1000, "nullPercentage": 20},
+		"obj": {"type": "object", "objectContent": {
+			"other": {"type": "binary"},
+			"name": {"type": "faker", "method": "Name"}
+		}},
+		"arr": {"type": "array", "arrayContent": {"type": "string", "maxLength": 5}},
+		"str": {"type": "stringFromParts", "parts": [{"type": "uuid"}, {"type": "long"}]}
+	}`)
+
+	for i := range before {
+		for _, path := range [][]string{{"int"}, {"obj", "name"}, {"arr"}, {"str"}} {
+			b, _ := before[i].LookupErr(path...)
+			a, _ := after[i].LookupErr(path...)
+			if !b.Equal(a) {
+				t.Errorf("doc %d: value of %v changed after adding a field: %v vs %v", i, path, b, a)
+			}
+		}
+	}
+}
+
+func TestSeek(t *testing.T) {
+
+	count := 3*generators.ChunkSize + 500
+	content := loadCollConfig(t, "full-bson.json")[0]
+	content.Set("autoincrement", generators.Config{Type: generators.TypeAutoincrement, AutoType: generators.TypeLong})
+	content.Set("objectIdArray", generators.Config{Type: generators.TypeArray, ArrayContent: &generators.Config{Type: generators.TypeObjectID}})
+
+	ci := generators.NewCollInfo(count, []int{3, 6}, 42, generators.References{})
+
+	docGenerator, err := ci.NewDocumentGenerator(content)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	var cc []struct {
-		Content map[string]generators.Config `json:"content"`
+	expected := make([][]byte, count)
+	for i := range expected {
+		expected[i] = append([]byte(nil), docGenerator.Generate()...)
 	}
-	err = json.Unmarshal(bytes, &cc)
+
+	// generate chunks in reverse order, and start one of them
+	// in the middle of a chunk
+	seekTests := []struct {
+		start int
+		end   int
+	}{
+		{start: 3 * generators.ChunkSize, end: count},
+		{start: 2*generators.ChunkSize + 10, end: 3 * generators.ChunkSize},
+		{start: generators.ChunkSize, end: 2 * generators.ChunkSize},
+		{start: 0, end: generators.ChunkSize},
+	}
+
+	docGenerator, err = ci.NewDocumentGenerator(content)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	list := make([]map[string]generators.Config, 0, len(cc))
-	for _, c := range cc {
-		list = append(list, c.Content)
+	for _, tt := range seekTests {
+		docGenerator.Seek(tt.start)
+		for i := tt.start; i < tt.end; i++ {
+			if got := docGenerator.Generate(); !bytes.Equal(expected[i], got) {
+				t.Fatalf("doc %d differs after seeking to %d: expected %v but got %v", i, tt.start, bson.Raw(expected[i]), bson.Raw(got))
+			}
+		}
 	}
-	return list
 }
 
-func TestEncodeToString(t *testing.T) {
+func TestAutoincrementInArray(t *testing.T) {
 
-	encodeToStringTests := []struct {
+	count := 3*generators.ChunkSize + 500
+	content := generators.Content{{Name: "k", Config: generators.Config{
+		Type:      generators.TypeArray,
+		MinLength: "3",
+		MaxLength: "3",
+		ArrayContent: &generators.Config{
+			Type:     generators.TypeAutoincrement,
+			AutoType: generators.TypeInt,
+		},
+	}}}
+
+	ci := generators.NewCollInfo(count, []int{3, 6}, 42, generators.References{})
+	docGenerator, err := ci.NewDocumentGenerator(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// generate the chunks in reverse order, like several
+	// DocumentGenerators running concurrently
+	values := make(map[int32]int)
+	for start := count - count%generators.ChunkSize; start >= 0; start -= generators.ChunkSize {
+		docGenerator.Seek(start)
+		for i := start; i < start+generators.ChunkSize && i < count; i++ {
+			elements, err := bson.Raw(docGenerator.Generate()).Lookup("k").Array().Values()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range elements {
+				if doc, ok := values[v.Int32()]; ok {
+					t.Fatalf("value %d of document %d is also in document %d", v.Int32(), i, doc)
+				}
+				values[v.Int32()] = i
+			}
+		}
+	}
+	// values are consecutive, as if the documents were generated in order
+	for v := int32(0); v < int32(3*count); v++ {
+		if doc, ok := values[v]; !ok || doc != int(v)/3 {
+			t.Fatalf("expected value %d in document %d, but got %v, %v", v, v/3, doc, ok)
+		}
+	}
+}
+
+func TestUniqueValues(t *testing.T) {
+
+	count := 3*generators.ChunkSize + 500
+
+	uniqueTests := []struct {
 		name   string
 		config generators.Config
-		empty  bool
 	}{
 		{
 			name:   "int",
-			config: generators.Config{Type: generators.TypeInt},
-			empty:  false,
+			config: generators.Config{Type: generators.TypeInt, Min: "-10", Max: json.Number(fmt.Sprint(count - 11)), Unique: true},
 		},
 		{
 			name:   "long",
-			config: generators.Config{Type: generators.TypeLong},
-			empty:  false,
+			config: generators.Config{Type: generators.TypeLong, Min: "100", Max: "1000000000000", Unique: true},
 		},
 		{
 			name:   "double",
-			config: generators.Config{Type: generators.TypeDouble},
-			empty:  false,
+			config: generators.Config{Type: generators.TypeDouble, Min: "0", Max: "1", Unique: true},
 		},
 		{
-			name:   "decimal",
-			config: generators.Config{Type: generators.TypeDecimal},
-			empty:  false,
+			name:   "date",
+			config: generators.Config{Type: generators.TypeDate, StartDate: time.Unix(0, 0), EndDate: time.Unix(int64(count), 0), Unique: true},
 		},
 		{
-			name:   "autoincrement",
-			config: generators.Config{Type: generators.TypeAutoincrement, AutoType: "long"},
-			empty:  false,
+			name:   "binary",
+			config: generators.Config{Type: generators.TypeBinary, MinLength: "2", MaxLength: "4", Unique: true},
 		},
 		{
-			name:   "boolean",
-			config: generators.Config{Type: generators.TypeBoolean},
-			empty:  false,
+			name:   "string",
+			config: generators.Config{Type: generators.TypeString, MinLength: "2", MaxLength: "2", Unique: true},
+		},
+		{
+			name:   "long with full range",
+			config: generators.Config{Type: generators.TypeLong, Min: "-9223372036854775808", Max: "9223372036854775807", Unique: true},
+		},
+		{
+			name:   "double with default bounds",
+			config: generators.Config{Type: generators.TypeDouble, Unique: true},
 		},
 		{
 			name:   "objectId",
 			config: generators.Config{Type: generators.TypeObjectID},
-			empty:  false,
 		},
 		{
-			name:   "UUID",
-			config: generators.Config{Type: generators.TypeUUID},
-			empty:  false,
+			name:   "int with nullPercentage",
+			config: generators.Config{Type: generators.TypeInt, Min: "0", Max: json.Number(fmt.Sprint(count - 1)), Unique: true, NullPercentage: 10},
 		},
+	}
+
+	for _, tt := range uniqueTests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ci := generators.NewCollInfo(count, []int{3, 6}, 42, generators.References{})
+			content := generators.Content{{Name: "k", Config: tt.config}}
+
+			docGenerator, err := ci.NewDocumentGenerator(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// generate the chunks in reverse order, like several
+			// DocumentGenerators running concurrently
+			values := make(map[string]bool, count)
+			for start := count - count%generators.ChunkSize; start >= 0; start -= generators.ChunkSize {
+				docGenerator.Seek(start)
+				for i := start; i < start+generators.ChunkSize && i < count; i++ {
+					v, err := bson.Raw(docGenerator.Generate()).LookupErr("k")
+					if err != nil {
+						continue
+					}
+					if values[string(v.Value)] {
+						t.Fatalf("value %v of document %d is not unique", v, i)
+					}
+					values[string(v.Value)] = true
+
+					if tt.config.Type == generators.TypeInt && (v.Int32() < -10 || v.Int32() > int32(count)) {
+						t.Errorf("value %v of document %d is out of bounds", v, i)
+					}
+				}
+			}
+			if tt.config.NullPercentage == 0 && len(values) != count {
+				t.Errorf("expected %d values, but got %d", count, len(values))
+			}
+		})
+	}
+}
+
+func TestDistribution(t *testing.T) {
+
+	count := 20000
+
+	distributionTests := []struct {
+		name   string
+		config generators.Config
+		// expected mean of the values, with a 5% tolerance
+		mean float64
+		// expected share of values equal to min
+		atMin float64
+	}{
 		{
-			name:   "date",
-			config: generators.Config{Type: generators.TypeDate, StartDate: time.Unix(0, 0), EndDate: time.Now()},
-			empty:  false,
+			name:   "uniform double",
+			config: generators.Config{Type: generators.TypeDouble, Min: "10", Max: "20"},
+			mean:   15,
 		},
 		{
-			name:   "string",
-			config: generators.Config{Type: generators.TypeString, MinLength: "1"},
-			empty:  false,
+			name:   "normal int",
+			config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "100", Distribution: generators.DistributionNormal, Mean: "40", StdDev: "5"},
+			mean:   40,
 		},
 		{
-			name:   "string from parts",
-			config: generators.Config{Type: generators.TypeStringFromParts, Parts: []generators.Config{{Type: generators.TypeInt}}},
-			empty:  false,
+			name:   "normal long with default parameters",
+			config: generators.Config{Type: generators.TypeLong, Min: "-1000", Max: "3000", Distribution: generators.DistributionNormal},
+			mean:   1000,
 		},
 		{
-			name:   "coordinates",
-			config: generators.Config{Type: generators.TypeCoordinates},
-			empty:  false,
+			name:   "normal double clamped",
+			config: generators.Config{Type: generators.TypeDouble, Min: "0", Max: "100", Distribution: generators.DistributionNormal, Mean: "0", StdDev: "10"},
+			mean:   10 * math.Sqrt(2/math.Pi) / 2,
+			atMin:  0.5,
 		},
 		{
-			name:   "contant",
-			config: generators.Config{Type: generators.TypeConstant, ConstVal: "hello"},
-			empty:  false,
+			name:   "logNormal double",
+			config: generators.Config{Type: generators.TypeDouble, Min: "5", Max: "1000", Distribution: generators.DistributionLogNormal, Mean: "1", StdDev: "0.5"},
+			mean:   5 + math.Exp(1+0.5*0.5/2),
 		},
 		{
-			name:   "enum",
-			config: generators.Config{Type: generators.TypeEnum, Values: []any{true, false}},
-			empty:  false,
+			name:   "exponential double",
+			config: generators.Config{Type: generators.TypeDouble, Min: "100", Max: "1000", Distribution: generators.DistributionExponential, Lambda: "0.1"},
+			mean:   110,
 		},
 		{
-			name:   "faker",
-			config: generators.Config{Type: generators.TypeFaker, Method: generators.MethodAnimal},
-			empty:  false,
+			name:   "poisson int",
+			config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "100", Distribution: generators.DistributionPoisson, Lambda: "4"},
+			mean:   4,
+			atMin:  math.Exp(-4),
 		},
 		{
-			name:   "array",
-			config: generators.Config{Type: generators.TypeArray, ArrayContent: &generators.Config{Type: generators.TypePosition}},
-			empty:  false,
+			name:   "poisson long with large lambda",
+			config: generators.Config{Type: generators.TypeLong, Min: "0", Max: "10000", Distribution: generators.DistributionPoisson, Lambda: "500"},
+			mean:   500,
 		},
 		{
-			name:   "binary",
-			config: generators.Config{Type: generators.TypeBinary, MinLength: "2"},
-			empty:  true,
+			name:   "zipf int",
+			config: generators.Config{Type: generators.TypeInt, Min: "1", Max: "1000000", Distribution: generators.DistributionZipf, Exponent: "3"},
+			// zeta(2) / zeta(3)
+			mean:  1.644934 / 1.2020569,
+			atMin: 1 / 1.2020569,
 		},
 		{
-			name:   "object",
-			config: generators.Config{Type: generators.TypeObject, ObjectContent: map[string]generators.Config{}},
-			empty:  true,
+			name: "exponential date",
+			config: generators.Config{
+				Type:         generators.TypeDate,
+				StartDate:    time.Unix(0, 0),
+				EndDate:      time.Unix(1000000, 0),
+				Distribution: generators.DistributionExponential,
+				Lambda:       "0.0001",
+			},
+			mean: 10000,
 		},
 	}
 
-	ci := generators.NewCollInfo(1000, []int{3, 6}, defaultSeed, map[int][][]byte{}, map[int]bsontype.Type{})
-
-	for _, tt := range encodeToStringTests {
+	for _, tt := range distributionTests {
 		t.Run(tt.name, func(t *testing.T) {
-			var content = map[string]generators.Config{
-				"str_from_parts": {
-					Type: generators.TypeStringFromParts,
-					Parts: []generators.Config{
-						tt.config,
-					},
-				},
-			}
 
-			g, err := ci.NewDocumentGenerator(content)
+			ci := generators.NewCollInfo(count, []int{3, 6}, 42, generators.References{})
+			docGenerator, err := ci.NewDocumentGenerator(generators.Content{{Name: "k", Config: tt.config}})
 			if err != nil {
-				t.Errorf("expected no error for config %v \n%v", tt.config, err)
+				t.Fatal(err)
 			}
 
-			doc := bson.Raw(g.Generate())
-			v := doc.Lookup("str_from_parts").StringValue()
-			if !tt.empty && v == "" {
-				t.Error("encode to string should generate a non empty string")
+			min, max := 0.0, 1000000.0
+			if tt.config.Type != generators.TypeDate {
+				min, _ = tt.config.Min.Float64()
+				max, _ = tt.config.Max.Float64()
 			}
-			if tt.empty && v != "" {
-				t.Error("encode to string should generate an empty string")
+
+			sum, atMin := 0.0, 0
+			for i := 0; i < count; i++ {
+				var v float64
+				raw := bson.Raw(docGenerator.Generate()).Lookup("k")
+				switch raw.Type {
+				case bson.TypeInt32:
+					v = float64(raw.Int32())
+				case bson.TypeInt64:
+					v = float64(raw.Int64())
+				case bson.TypeDouble:
+					v = raw.Double()
+				case bson.TypeDateTime:
+					// in seconds
+					v = float64(raw.DateTime()) / 1000
+				}
+				if v < min || v > max {
+					t.Fatalf("value %v of document %d is out of bounds", v, i)
+				}
+				if v == min {
+					atMin++
+				}
+				sum += v
+			}
+
+			mean := sum / float64(count)
+			if math.Abs(mean-tt.mean) > 0.05*math.Max(math.Abs(tt.mean), 1) {
+				t.Errorf("expected a mean of %v, but got %v", tt.mean, mean)
+			}
+			share := float64(atMin) / float64(count)
+			if math.Abs(share-tt.atMin) > 0.02 {
+				t.Errorf("expected %v%% of the values to be equal to min, but got %v%%", tt.atMin*100, share*100)
+			}
+		})
+	}
+}
+
+func TestUniqueFields(t *testing.T) {
+
+	uniqueFieldsTests := []struct {
+		name          string
+		count         int
+		content       generators.Content
+		uniqueFields  [][]string
+		correct       bool
+		nilFilter     bool
+		expectedError string
+	}{
+		{
+			name:  "all combinations",
+			count: 100,
+			content: generators.Content{
+				{Name: "a", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "9"}},
+				{Name: "s", Config: generators.Config{Type: generators.TypeString, MinLength: "3", MaxLength: "3"}},
+				{Name: "b", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "9"}},
+			},
+			uniqueFields: [][]string{{"a", "b"}},
+			correct:      true,
+		},
+		{
+			name:  "missing field counts as null",
+			count: 110,
+			content: generators.Content{
+				{Name: "a", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "9"}},
+				{Name: "b", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "9", NullPercentage: 20}},
+				{Name: "c", Config: generators.Config{Type: generators.TypeBoolean}},
+			},
+			uniqueFields: [][]string{{"a", "b"}},
+			correct:      true,
+		},
+		{
+			name:  "several tuples",
+			count: 20,
+			content: generators.Content{
+				{Name: "a", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "19"}},
+				{Name: "b", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "4"}},
+				{Name: "c", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "3"}},
+			},
+			uniqueFields: [][]string{{"a"}, {"b", "c"}},
+			correct:      true,
+		},
+		{
+			name:  "not enough combinations",
+			count: 101,
+			content: generators.Content{
+				{Name: "a", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "9"}},
+				{Name: "b", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "9"}},
+			},
+			uniqueFields:  [][]string{{"a", "b"}},
+			correct:       false,
+			expectedError: "couldn't find a new combination of values for 'uniqueFields' [a, b] in document 101 after 1000 attempts. Make sure that the fields can take enough distinct values",
+		},
+		{
+			name:  "unique field",
+			count: 100,
+			content: generators.Content{
+				{Name: "a", Config: generators.Config{Type: generators.TypeAutoincrement, AutoType: generators.TypeInt}},
+				{Name: "b", Config: generators.Config{Type: generators.TypeBoolean}},
+			},
+			uniqueFields: [][]string{{"a", "b"}},
+			correct:      true,
+			nilFilter:    true,
+		},
+		{
+			name:  "only unique fields with nullPercentage",
+			count: 100,
+			content: generators.Content{
+				{Name: "a", Config: generators.Config{Type: generators.TypeObjectID, NullPercentage: 10}},
+			},
+			uniqueFields:  [][]string{{"a"}},
+			correct:       false,
+			expectedError: "invalid 'uniqueFields': fields [a] can't be generated again to avoid duplicates, because their values are already unique",
+		},
+		{
+			name:  "unknown field",
+			count: 10,
+			content: generators.Content{
+				{Name: "a", Config: generators.Config{Type: generators.TypeBoolean}},
+			},
+			uniqueFields:  [][]string{{"a", "b.c"}},
+			correct:       false,
+			expectedError: "invalid 'uniqueFields': field 'b.c' has to be a top level field of 'content'",
+		},
+		{
+			name:  "conditional field",
+			count: 10,
+			content: generators.Content{
+				{Name: "a", Config: generators.Config{Type: generators.TypeBoolean}},
+				{Name: "b", Config: generators.Config{Type: generators.TypeBoolean, Condition: bson.M{"a": true}}},
+			},
+			uniqueFields:  [][]string{{"b"}},
+			correct:       false,
+			expectedError: "invalid 'uniqueFields': field 'b' depends on other fields, so it can't be generated again to avoid duplicates",
+		},
+		{
+			name:  "field used in a condition",
+			count: 10,
+			content: generators.Content{
+				{Name: "a", Config: generators.Config{Type: generators.TypeBoolean}},
+				{Name: "b", Config: generators.Config{Type: generators.TypeBoolean, Condition: bson.M{"a": true}}},
+			},
+			uniqueFields:  [][]string{{"a"}},
+			correct:       false,
+			expectedError: "invalid 'uniqueFields': field 'a' is used by another field, so it can't be generated again to avoid duplicates",
+		},
+		{
+			name:  "field used in an expression",
+			count: 10,
+			content: generators.Content{
+				{Name: "a", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "100"}},
+				{Name: "b", Config: generators.Config{Type: generators.TypeExpression, Expression: "a * 2"}},
+			},
+			uniqueFields:  [][]string{{"a"}},
+			correct:       false,
+			expectedError: "invalid 'uniqueFields': field 'a' is used by another field, so it can't be generated again to avoid duplicates",
+		},
+		{
+			name:  "field used in a template",
+			count: 10,
+			content: generators.Content{
+				{Name: "a", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "100"}},
+				{Name: "b", Config: generators.Config{Type: generators.TypeTemplate, Template: "id-{{a}}"}},
+			},
+			uniqueFields:  [][]string{{"a"}},
+			correct:       false,
+			expectedError: "invalid 'uniqueFields': field 'a' is used by another field, so it can't be generated again to avoid duplicates",
+		},
+		{
+			name:  "expression",
+			count: 10,
+			content: generators.Content{
+				{Name: "a", Config: generators.Config{Type: generators.TypeInt, Min: "0", Max: "100"}},
+				{Name: "b", Config: generators.Config{Type: generators.TypeExpression, Expression: "a * 2"}},
+			},
+			uniqueFields:  [][]string{{"b"}},
+			correct:       false,
+			expectedError: "invalid 'uniqueFields': field 'b' depends on other fields, so it can't be generated again to avoid duplicates",
+		},
+		{
+			name:  "empty tuple",
+			count: 10,
+			content: generators.Content{
+				{Name: "a", Config: generators.Config{Type: generators.TypeBoolean}},
+			},
+			uniqueFields:  [][]string{{}},
+			correct:       false,
+			expectedError: "invalid 'uniqueFields': a tuple can't be empty",
+		},
+	}
+
+	for _, tt := range uniqueFieldsTests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ci := generators.NewCollInfo(tt.count, []int{3, 6}, 42, generators.References{})
+			docGenerator, err := ci.NewDocumentGenerator(tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filter, err := ci.NewUniqueFieldsFilter(tt.content, tt.uniqueFields)
+			if err == nil {
+				if tt.nilFilter != (filter == nil) {
+					t.Fatalf("expected nil filter: %v, but got %v", tt.nilFilter, filter)
+				}
+				if filter == nil {
+					return
+				}
+				combinations := make(map[string]bool, tt.count)
+				for i := 0; i < tt.count; i++ {
+					doc, filterErr := filter.Filter(docGenerator.Generate())
+					if filterErr != nil {
+						err = filterErr
+						break
+					}
+					assertFieldOrder(t, doc, tt.content)
+					for _, fields := range tt.uniqueFields {
+						assertNewCombination(t, combinations, doc, fields, i)
+					}
+				}
+			}
+			if tt.correct && err != nil {
+				t.Errorf("expected no error but got %v", err)
+			}
+			if !tt.correct {
+				if err == nil {
+					t.Errorf("expected an error but got none")
+				} else if err.Error() != tt.expectedError {
+					t.Errorf("expected error '%s', but got '%v'", tt.expectedError, err)
+				}
 			}
 		})
 	}
 }
 
+func assertNewCombination(t *testing.T, combinations map[string]bool, doc bson.Raw, fields []string, index int) {
+	t.Helper()
+	key := fmt.Sprint(fields)
+	for _, name := range fields {
+		v, err := doc.LookupErr(name)
+		if err != nil {
+			key += "|null"
+			continue
+		}
+		key += "|" + v.String()
+	}
+	if combinations[key] {
+		t.Fatalf("combination %s of document %d is not unique", key, index)
+	}
+	combinations[key] = true
+}
+
+func assertFieldOrder(t *testing.T, doc bson.Raw, content generators.Content) {
+	t.Helper()
+	elements, err := doc.Elements()
+	if err != nil {
+		t.Fatal(err)
+	}
+	position := -1
+	for _, e := range elements {
+		for i, field := range content {
+			if field.Name == e.Key() {
+				if i < position {
+					t.Fatalf("field '%s' is not in the order of the content in %v", e.Key(), doc)
+				}
+				position = i
+			}
+		}
+	}
+}
+
+func TestFieldOrder(t *testing.T) {
+
+	config := []byte(`{
+		"z": {"type": "int", "min": 0, "max": 10},
+		"a": {"type": "object", "objectContent": {
+			"y": {"type": "boolean"},
+			"b": {"type": "string", "minLength": 1, "maxLength": 3}
+		}},
+		"m": {"type": "ref", "id": 1, "refContent": {"type": "long", "min": 0, "max": 10}},
+		"c": {"type": "ref", "id": 1}
+	}`)
+
+	var content generators.Content
+	err := json.Unmarshal(config, &content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ci := generators.NewCollInfo(10, []int{3, 6}, defaultSeed, generators.References{})
+	docGenerator, err := ci.NewDocumentGenerator(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := func(doc bson.Raw) []string {
+		elements, err := doc.Elements()
+		if err != nil {
+			t.Fatal(err)
+		}
+		k := make([]string, 0, len(elements))
+		for _, e := range elements {
+			k = append(k, e.Key())
+		}
+		return k
+	}
+
+	for i := 0; i < 10; i++ {
+		doc := bson.Raw(docGenerator.Generate())
+		if got, want := fmt.Sprint(keys(doc)), "[z a m c]"; got != want {
+			t.Errorf("expected fields %s but got %s", want, got)
+		}
+		if got, want := fmt.Sprint(keys(doc.Lookup("a").Document())), "[y b]"; got != want {
+			t.Errorf("expected embedded fields %s but got %s", want, got)
+		}
+	}
+}
+
 func BenchmarkGeneratorAll(b *testing.B) {
 
 	contentList := loadCollConfig(nil, "full-bson.json")
 
-	ci := generators.NewCollInfo(1000, []int{3, 2}, defaultSeed, map[int][][]byte{}, map[int]bsontype.Type{})
+	ci := generators.NewCollInfo(1000, []int{3, 2}, defaultSeed, generators.References{})
 	docGenerator, err := ci.NewDocumentGenerator(contentList[0])
 	if err != nil {
 		b.Fail()
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+
+	"github.com/MichaelTJones/pcg"
 )
 
 // Generator for creating random int32 between `Min` and `Max`
@@ -14,7 +16,7 @@ type intGenerator struct {
 	max int32
 }
 
-func newIntGenerator(config *Config, base base) (g Generator, err error) {
+func newIntGenerator(config *Config, base base, pcg64 *pcg.PCG64, count int) (g Generator, err error) {
 
 	min, max := int64(0), int64(math.MaxInt32-2)
 
@@ -35,10 +37,23 @@ func newIntGenerator(config *Config, base base) (g Generator, err error) {
 	if min > max {
 		return nil, errors.New("make sure that 'max' >= 'min'")
 	}
+	dist, err := newDistribution(config, float64(min), float64(max), pcg64)
+	if err != nil {
+		return nil, err
+	}
+	if config.Unique {
+		seq, err := newUniqueSequence(uint64(max-min)+1, count, 1, pcg64)
+		if err != nil {
+			return nil, err
+		}
+		return &uniqueIntGenerator{base: base, min: int32(min), uniqueSequence: seq}, nil
+	}
 	if min == max {
 		return newConstantGenerator(base, max)
 	}
-
+	if dist != nil {
+		return &intDistributionGenerator{base: base, distribution: dist}, nil
+	}
 	return &intGenerator{
 		base: base,
 		min:  int32(min),
@@ -57,3 +72,40 @@ func (g *intGenerator) EncodeValueAsString() {
 func (g *intGenerator) boundedInt32() int32 {
 	return int32(g.pcg32.Bounded(uint32(g.max-g.min))) + g.min
 }
+
+// Generator for creating unique int32 between `Min` and `Max`
+type uniqueIntGenerator struct {
+	base
+	*uniqueSequence
+	min int32
+}
+
+func (g *uniqueIntGenerator) EncodeValue() {
+	g.buffer.Write(int32Bytes(g.nextInt32()))
+}
+
+func (g *uniqueIntGenerator) EncodeValueAsString() {
+	g.buffer.WriteString(strconv.Itoa(int(g.nextInt32())))
+}
+
+func (g *uniqueIntGenerator) nextInt32() int32 {
+	return int32(int64(g.next()) + int64(g.min))
+}
+
+// Generator for creating int32 between `Min` and `Max` following a distribution
+type intDistributionGenerator struct {
+	base
+	*distribution
+}
+
+func (g *intDistributionGenerator) EncodeValue() {
+	g.buffer.Write(int32Bytes(g.nextInt32()))
+}
+
+func (g *intDistributionGenerator) EncodeValueAsString() {
+	g.buffer.WriteString(strconv.Itoa(int(g.nextInt32())))
+}
+
+func (g *intDistributionGenerator) nextInt32() int32 {
+	return int32(math.Round(g.next()))
+}
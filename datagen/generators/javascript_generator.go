@@ -0,0 +1,43 @@
+package generators
+
+import (
+	"github.com/MichaelTJones/pcg"
+)
+
+// javascript code is written as 'function() { return "<letters>"; }'
+const (
+	javascriptPrefix = `function() { return "`
+	javascriptSuffix = `"; }`
+)
+
+// Generator for creating random bson javascript code. Each function returns
+// a random string of a length within [`MinLength`, `MaxLength`]
+type javascriptGenerator struct {
+	*stringGenerator
+}
+
+func newJavascriptGenerator(config *Config, base base, pcg64 *pcg.PCG64) (Generator, error) {
+	g, err := newStringGenerator(config, base, pcg64, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &javascriptGenerator{stringGenerator: g.(*stringGenerator)}, nil
+}
+
+func (g *javascriptGenerator) EncodeValue() {
+	length := g.randomLength()
+	// javascript code is stored like a string
+	g.buffer.Write(uint32Bytes(uint32(len(javascriptPrefix)+len(javascriptSuffix)) + length + 1))
+	g.writeCode(length)
+	g.buffer.WriteSingleByte(byte(0))
+}
+
+func (g *javascriptGenerator) EncodeValueAsString() {
+	g.writeCode(g.randomLength())
+}
+
+func (g *javascriptGenerator) writeCode(length uint32) {
+	g.buffer.WriteString(javascriptPrefix)
+	g.writeLetters(length)
+	g.buffer.WriteString(javascriptSuffix)
+}
@@ -0,0 +1,18 @@
+package generators
+
+// Generator for creating bson MinKey or MaxKey. These
+// types don't have a value, only a bson type
+type keyGenerator struct {
+	base
+	str string
+}
+
+func newKeyGenerator(base base, str string) (Generator, error) {
+	return &keyGenerator{base: base, str: str}, nil
+}
+
+func (g *keyGenerator) EncodeValue() {}
+
+func (g *keyGenerator) EncodeValueAsString() {
+	g.buffer.WriteString(g.str)
+}
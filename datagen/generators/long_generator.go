@@ -17,7 +17,7 @@ type longGenerator struct {
 	pcg64 *pcg.PCG64
 }
 
-func newLongGenerator(config *Config, base base, pcg64 *pcg.PCG64) (g Generator, err error) {
+func newLongGenerator(config *Config, base base, pcg64 *pcg.PCG64, count int) (g Generator, err error) {
 
 	min, max := int64(0), int64(math.MaxInt64-2)
 
@@ -38,9 +38,29 @@ func newLongGenerator(config *Config, base base, pcg64 *pcg.PCG64) (g Generator,
 	if min > max {
 		return nil, errors.New("make sure that 'max' >= 'min'")
 	}
+	dist, err := newDistribution(config, float64(min), float64(max), pcg64)
+	if err != nil {
+		return nil, err
+	}
+	if config.Unique {
+		size := uint64(max-min) + 1
+		// the full int64 range holds 2^64 values, which overflows. Ignoring
+		// one of them doesn't matter
+		if size == 0 {
+			size = math.MaxUint64
+		}
+		seq, err := newUniqueSequence(size, count, 1, pcg64)
+		if err != nil {
+			return nil, err
+		}
+		return &uniqueLongGenerator{base: base, min: min, uniqueSequence: seq}, nil
+	}
 	if min == max {
 		return newConstantGenerator(base, max)
 	}
+	if dist != nil {
+		return &longDistributionGenerator{base: base, distribution: dist, min: min, max: max}, nil
+	}
 	return &longGenerator{
 		base:  base,
 		min:   min,
@@ -60,3 +80,51 @@ func (g *longGenerator) EncodeValueAsString() {
 func (g *longGenerator) boundedInt64() int64 {
 	return int64(g.pcg64.Bounded(uint64(g.max-g.min))) + g.min
 }
+
+// Generator for creating unique int64 between `Min` and `Max`
+type uniqueLongGenerator struct {
+	base
+	*uniqueSequence
+	min int64
+}
+
+func (g *uniqueLongGenerator) EncodeValue() {
+	g.buffer.Write(int64Bytes(g.nextInt64()))
+}
+
+func (g *uniqueLongGenerator) EncodeValueAsString() {
+	g.buffer.WriteString(strconv.FormatInt(g.nextInt64(), 10))
+}
+
+func (g *uniqueLongGenerator) nextInt64() int64 {
+	return int64(g.next() + uint64(g.min))
+}
+
+// Generator for creating int64 between `Min` and `Max` following a distribution
+type longDistributionGenerator struct {
+	base
+	*distribution
+	min int64
+	max int64
+}
+
+func (g *longDistributionGenerator) EncodeValue() {
+	g.buffer.Write(int64Bytes(g.nextInt64()))
+}
+
+func (g *longDistributionGenerator) EncodeValueAsString() {
+	g.buffer.WriteString(strconv.FormatInt(g.nextInt64(), 10))
+}
+
+func (g *longDistributionGenerator) nextInt64() int64 {
+	// bounds may not be exactly representable as float64, so
+	// clamp the value again to avoid an overflow
+	v := math.Round(g.next())
+	if v <= float64(g.min) {
+		return g.min
+	}
+	if v >= float64(g.max) {
+		return g.max
+	}
+	return int64(v)
+}
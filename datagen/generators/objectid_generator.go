@@ -1,80 +1,96 @@
 package generators
 
 import (
-	"crypto/md5"
 	"encoding/hex"
-	"os"
-	"sync/atomic"
-	"time"
+	"hash/fnv"
+	"math"
 
 	"github.com/MichaelTJones/pcg"
 )
 
 // Generator for creating bson.ObjectId
+//
+// To keep the output reproducible, ObjectIds don't depend on the current
+// time or on the host: the 4 bytes usually holding a timestamp are derived
+// from the seed, and the 8 remaining bytes hold a counter starting at a
+// random value derived from the seed and from the namespace of the collection.
+// Each chunk of documents gets its own range of 2^32 counters
 type objectIDGenerator struct {
 	base
+	timestamp uint32
+	first     uint64
+	counter   uint64
 }
 
-func newObjectIDGenerator(base base) (Generator, error) {
-	return &objectIDGenerator{base: base}, nil
+// timestamps derived from the seed are within the ten years
+// following objectIDBaseTime, ie 2020-01-01T00:00:00Z
+const (
+	objectIDBaseTime  = 1577836800
+	objectIDTimeRange = 10 * 365 * 24 * 3600
+)
+
+func newObjectIDGenerator(base base, seed uint64, namespace string, pcg64 *pcg.PCG64) (Generator, error) {
+	// the stream of a field only depends on the seed and on the path
+	// of the field, so mix the namespace in to avoid getting the same
+	// ObjectIds in each collection
+	h := fnv.New64a()
+	h.Write([]byte(namespace))
+	first := pcg64.Random() ^ splitmix64(h.Sum64())
+
+	return &objectIDGenerator{
+		base:      base,
+		timestamp: objectIDTimestamp(seed),
+		first:     first,
+		counter:   first,
+	}, nil
+}
+
+func objectIDTimestamp(seed uint64) uint32 {
+	// when no seed is specified, the current unix time is used as seed,
+	// so keep it and ObjectIds have a meaningful timestamp
+	if seed >= objectIDBaseTime && seed <= math.MaxUint32 {
+		return uint32(seed)
+	}
+	return objectIDBaseTime + uint32(splitmix64(seed)%objectIDTimeRange)
 }
 
 // Value add a bson.ObjectId to the DocBuffer.
 func (g *objectIDGenerator) EncodeValue() {
-	g.buffer.Write(g.randomObjectID())
+	g.buffer.Write(g.nextObjectID())
 }
 
 func (g *objectIDGenerator) EncodeValueAsString() {
 
 	dst := make([]byte, hex.EncodedLen(12))
-	hex.Encode(dst, g.randomObjectID())
+	hex.Encode(dst, g.nextObjectID())
 	g.buffer.Write(dst)
 }
 
-var (
-	// machine ID to generate unique object ID
-	machineID = readMachineID()
-	// process ID to generate unique object ID
-	processID = os.Getpid()
-	// objectIdCounter is atomically incremented when generating a new ObjectId
-	objectIDCounter = getRandomUint32()
-)
-
-func (g *objectIDGenerator) randomObjectID() []byte {
+func (g *objectIDGenerator) nextObjectID() []byte {
 
-	t := uint32(time.Now().Unix())
-	i := atomic.AddUint32(&objectIDCounter, 1)
+	t := g.timestamp
+	i := g.counter
+	g.counter++
 
 	return []byte{
-		byte(t >> 24),
+		byte(t >> 24), // Timestamp, 4 bytes, big endian
 		byte(t >> 16),
 		byte(t >> 8),
 		byte(t),
-		machineID[0], // Machine, first 3 bytes of md5(hostname)
-		machineID[1],
-		machineID[2],
-		byte(processID >> 8), // Pid, 2 bytes, specs don't specify endianness, but we use big endian.
-		byte(processID),
-		byte(i >> 16), // Increment, 3 bytes, big endian
+		byte(i >> 56), // Counter, 8 bytes, big endian
+		byte(i >> 48),
+		byte(i >> 40),
+		byte(i >> 32),
+		byte(i >> 24),
+		byte(i >> 16),
 		byte(i >> 8),
 		byte(i),
 	}
 }
 
-// readMachineId generates and returns a machine id.
-func readMachineID() []byte {
-	id := uint32Bytes(getRandomUint32())
-	hostname, err := os.Hostname()
-	if err == nil {
-		h := md5.New()
-		h.Write([]byte(hostname))
-		id = h.Sum(nil)
-	}
-	return id[0:3]
-}
-
-func getRandomUint32() uint32 {
-	seed := uint64(time.Now().Unix())
-	pcg32 := pcg.NewPCG32().Seed(seed, seed)
-	return pcg32.Random()
+// seek moves the counter to the n-th document. Values of a reference are generated one
+// by one, so inside a chunk, the counter is the one of the n-th document if the previous
+// documents of the chunk hold a single ObjectId
+func (g *objectIDGenerator) seek(n int) {
+	g.counter = g.first + uint64(n/ChunkSize)<<32 + uint64(n%ChunkSize)
 }
@@ -0,0 +1,76 @@
+package generators
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Generator for creating values with one of several generators, picked at random
+// for each value. The generators can be of different types, so the bson type of
+// the value is written by the generator picked
+type oneOfGenerator struct {
+	base
+	generators       []Generator
+	cumulatedWeights []float64
+	// generator picked for the current value
+	current Generator
+}
+
+func newOneOfGenerator(config *Config, base base, ci *CollInfo, buffer *DocBuffer, path string) (Generator, error) {
+	if len(config.Generators) == 0 {
+		return nil, errors.New("'generators' can't be null or empty")
+	}
+
+	cumulatedWeights, err := cumulateWeights(config.Weights, len(config.Generators))
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'weights' for 'generators': %v", err)
+	}
+	// by default, generators are picked with the same probability
+	if cumulatedWeights == nil {
+		cumulatedWeights = make([]float64, len(config.Generators))
+		for i := range cumulatedWeights {
+			cumulatedWeights[i] = float64(i + 1)
+		}
+	}
+
+	generators := make([]Generator, 0, len(config.Generators))
+	for i := range config.Generators {
+		// generators write the key of the field themselves
+		g, err := ci.newGenerator(buffer, string(base.key), fmt.Sprintf("%s.$%d", path, i), &config.Generators[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid generator %d in 'generators': %v", i, err)
+		}
+		generators = append(generators, g)
+	}
+
+	return &oneOfGenerator{
+		base:             base,
+		generators:       generators,
+		cumulatedWeights: cumulatedWeights,
+	}, nil
+}
+
+func (g *oneOfGenerator) Exists() bool {
+	if !g.base.Exists() {
+		return false
+	}
+	g.current = g.pick()
+	return true
+}
+
+// pick returns a random generator
+func (g *oneOfGenerator) pick() Generator {
+	return g.generators[weightedIndex(g.pcg32, g.cumulatedWeights)]
+}
+
+func (g *oneOfGenerator) selected() Generator { return g.current }
+
+func (g *oneOfGenerator) choices() []Generator { return g.generators }
+
+func (g *oneOfGenerator) EncodeValue() {
+	encodeElement(g.buffer, g.current)
+}
+
+func (g *oneOfGenerator) EncodeValueAsString() {
+	g.pick().EncodeValueAsString()
+}
@@ -0,0 +1,524 @@
+package generators
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// References holds the values of the references, and the collections they come
+// from, by reference id. It has to be shared by the CollInfo of all the collections
+type References map[int]*reference
+
+// reference holds the values of the field with 'refContent' of a reference
+type reference struct {
+	values *valueSource
+	// true if the n-th document of the collection the values
+	// come from holds the n-th value
+	inAllDocuments bool
+	// collection holding the values, if its documents can be
+	// copied by the references with 'fields'
+	collection *referencedCollection
+}
+
+// referencedCollection is a collection holding the values of a reference,
+// ie a collection with a top level reference field with 'refContent'
+type referencedCollection struct {
+	// creates a generator of the documents of the collection, the n-th
+	// document holding the n-th value of the reference
+	newDocumentGenerator func() (*DocumentGenerator, error)
+	count                int
+	ci                   *CollInfo
+	// top level fields of 'uniqueFields' that can be generated again, so
+	// their final values aren't known when the documents are generated
+	regenerated map[string]bool
+}
+
+// registerReferencedCollections makes the documents of ci available to the references
+// of other collections, for all the reference fields of content with 'refContent'
+func (ci *CollInfo) registerReferencedCollections(content Content, newDocumentGenerator func() (*DocumentGenerator, error)) {
+	for _, f := range content {
+		config := f.Config
+		if (config.Type != TypeReference && config.Type != TypeRef) || config.RefContent == nil {
+			continue
+		}
+		// only the collection the values come from can be copied, and it's
+		// registered again each time a generator of its documents is created
+		r, ok := ci.references[config.ID]
+		if !ok || r.values.ci != ci || r.collection != nil {
+			continue
+		}
+		if !r.inAllDocuments {
+			continue
+		}
+		r.collection = &referencedCollection{
+			newDocumentGenerator: newDocumentGenerator,
+			count:                ci.Count,
+			ci:                   ci,
+			regenerated:          make(map[string]bool),
+		}
+	}
+}
+
+// newCursor returns a cursor over copies of the documents of the collection, with
+// only the fields at paths. Like the values of a reference, the documents aren't
+// stored, but generated again by chunks when needed
+func (rc *referencedCollection) newCursor(paths []string) (*valueCursor, error) {
+
+	fields := make([][]string, 0, len(paths))
+	for _, path := range paths {
+		parts := strings.Split(path, ".")
+		for _, part := range parts {
+			if part == "" {
+				return nil, fmt.Errorf("invalid field '%s' in 'fields'", path)
+			}
+		}
+		if rc.regenerated[parts[0]] {
+			return nil, fmt.Errorf("field '%s' of the referenced documents can't be copied, as it's used in 'uniqueFields'", path)
+		}
+		fields = append(fields, parts)
+	}
+
+	g, err := rc.newDocumentGenerator()
+	if err != nil {
+		return nil, err
+	}
+	return newValueCursor(rc.count, func(chunk int, data []byte, ends []int) ([]byte, []int) {
+		g.Seek(chunk * ChunkSize)
+		end := (chunk + 1) * ChunkSize
+		if end > rc.count {
+			end = rc.count
+		}
+		for i := chunk * ChunkSize; i < end; i++ {
+			doc := g.Generate()
+			data = append(data, byte(bson.TypeEmbeddedDocument))
+			data = append(data, projectDocument(doc[4:len(doc)-1], fields)...)
+			ends = append(ends, len(data))
+		}
+		return data, ends
+	}), nil
+}
+
+// projectDocument returns an embedded document holding the fields of elements at
+// paths, in the order of elements. elements are the elements of a document
+func projectDocument(elements []byte, paths [][]string) []byte {
+
+	doc := make([]byte, 4, 64)
+	for len(elements) > 0 {
+		elem, rest, ok := bsoncore.ReadElement(elements)
+		if !ok {
+			break
+		}
+		elements = rest
+
+		var nested [][]string
+		whole := false
+		for _, p := range paths {
+			if p[0] != elem.Key() {
+				continue
+			}
+			if len(p) == 1 {
+				whole = true
+				break
+			}
+			nested = append(nested, p[1:])
+		}
+		switch {
+		case whole:
+			doc = append(doc, elem...)
+		case nested != nil && elem.Value().Type == bson.TypeEmbeddedDocument:
+			data := elem.Value().Data
+			sub := projectDocument(data[4:len(data)-1], nested)
+			if len(sub) > 5 {
+				doc = bsoncore.AppendDocumentElement(doc, elem.Key(), sub)
+			}
+		}
+	}
+	doc = append(doc, 0)
+	copy(doc, int32Bytes(int32(len(doc))))
+	return doc
+}
+
+// hasCardinality returns true if config is a reference with a
+// number of children per parent
+func hasCardinality(config *Config) bool {
+	return config.MinChildren != 0 || config.MaxChildren != 0 || config.Distribution != ""
+}
+
+// Generator for creating references to the documents of a parent collection, with
+// a number of documents per parent between 'minChildren' and 'maxChildren', with
+// a copy of some fields of the parent document, or as an end of the edges of a graph.
+//
+// The parent depends on the index of the document being generated, so all the
+// fields using the same reference in a document get the same parent
+type referenceGenerator struct {
+	base
+	// value of the reference, or copy of the document, of each parent
+	values *valueCursor
+	// parent of each document with 'minChildren', 'maxChildren' or 'distribution', or nil
+	parents *parentCursor
+	// for the edges of a graph, nodes of each document. The nodes of the documents
+	// being generated are close to each other, but spread over several chunks of
+	// values, so there is a cursor per chunk, used in turn
+	edges *edgeCursor
+	nodes []*valueCursor
+	// true for the end of the edges, false for their start
+	to bool
+	// without parents nor edges, picks the parent of each document at random,
+	// or nil if the documents use the values in turn
+	random *randomParents
+}
+
+func newReferenceGenerator(config *Config, base base, ci *CollInfo, path string) (Generator, error) {
+
+	if config.RefContent != nil {
+		return nil, errors.New("'minChildren', 'maxChildren', 'distribution', 'fields' and 'edge' can only be used in the collections using the values of the reference, not with 'refContent'")
+	}
+	if ci.valuesPerDoc > 1 {
+		return nil, errors.New("'minChildren', 'maxChildren', 'distribution', 'fields' and 'edge' can't be used for a field inside an array")
+	}
+
+	r := ci.references[config.ID]
+	newCursor := r.values.newCursor
+	if config.Fields != nil {
+		if len(config.Fields) == 0 {
+			return nil, errors.New("'fields' can't be empty")
+		}
+		if r.collection == nil || r.collection.ci == ci {
+			return nil, fmt.Errorf("documents of reference %d can't be copied: the field with 'refContent' has to be a top level field present in all the documents of another collection", config.ID)
+		}
+		newCursor = func() (*valueCursor, error) { return r.collection.newCursor(config.Fields) }
+	}
+	values, err := newCursor()
+	if err != nil {
+		return nil, err
+	}
+
+	if hasCardinality(config) || config.Edge != "" {
+		if path != string(base.key) {
+			return nil, errors.New("'minChildren', 'maxChildren', 'distribution' and 'edge' can only be used for a top level field")
+		}
+		if config.NullPercentage != 0 || config.MissingPercentage != 0 || config.NullValuePercentage != 0 || config.Condition != nil {
+			return nil, errors.New("a reference with 'minChildren', 'maxChildren', 'distribution' or 'edge' has to be present in all documents, so it can't be null, missing or have a condition")
+		}
+	}
+
+	c, hasParents := ci.cardinalities[config.ID]
+	if config.InOrder && (hasParents || config.Edge != "") {
+		return nil, errors.New("'inOrder' can't be used with 'minChildren', 'maxChildren', 'distribution' or 'edge'")
+	}
+
+	g := &referenceGenerator{
+		base:   base,
+		values: values,
+	}
+	if config.Edge != "" {
+		e, ok := ci.edges[config.ID]
+		if !ok {
+			return nil, errors.New("'edge' can only be used for a top level field")
+		}
+		g.edges, g.to = e.newCursor(), config.Edge == EdgeTo
+		// sources are in the same block of ChunkSize nodes, and
+		// their targets are at most span nodes after them
+		g.nodes = []*valueCursor{values}
+		for len(g.nodes) < (e.span+ChunkSize-1)/ChunkSize+3 {
+			cursor, err := newCursor()
+			if err != nil {
+				return nil, err
+			}
+			g.nodes = append(g.nodes, cursor)
+		}
+		return g, nil
+	}
+	if hasParents {
+		g.parents = c.newCursor()
+	}
+	if !hasParents && !config.InOrder {
+		g.random = ci.newRandomParents(config.ID)
+	}
+	return g, nil
+}
+
+// randomParents picks at random the parent of each document of a collection.
+//
+// Like for a cardinality, parents are split in blocks of ChunkSize parents, and
+// documents in consecutive ranges, one per block, holding a share of the documents
+// proportional to the number of parents of the block. Each document picks its parent
+// at random among the parents of its block, so the number of children of a parent
+// varies, but the parents of consecutive documents are generated by chunk only once.
+//
+// The parent only depends on the seed, the reference and the index of the document,
+// so all the fields using the same reference in a document get the same parent
+type randomParents struct {
+	key   uint64
+	count int
+}
+
+// newRandomParents returns the random parents of the documents of ci for reference id
+func (ci *CollInfo) newRandomParents(id int) *randomParents {
+	return &randomParents{
+		key:   splitmix64(ci.Seed ^ pathSequence(fmt.Sprintf("$reference.%d", id))),
+		count: ci.Count,
+	}
+}
+
+// parent returns the parent, among nbParents parents, of the document at index doc
+func (p *randomParents) parent(doc, nbParents int) int {
+	hi, lo := bits.Mul64(uint64(doc), uint64(nbParents))
+	position, _ := bits.Div64(hi, lo, uint64(p.count))
+	first := int(position) - int(position)%ChunkSize
+	size := nbParents - first
+	if size > ChunkSize {
+		size = ChunkSize
+	}
+	parent, _ := bits.Mul64(splitmix64(p.key^uint64(doc)), uint64(size))
+	return first + int(parent)
+}
+
+// assignReferences sets the number of children per parent of the references of
+// content with 'minChildren', 'maxChildren' or 'distribution'. All the fields of a
+// collection using the same reference share the same parents, so the number of
+// children only has to be set on one of them
+func (ci *CollInfo) assignReferences(content Content) error {
+	for _, f := range content {
+		config := f.Config
+		if (config.Type != TypeReference && config.Type != TypeRef) || config.RefContent != nil || config.Edge != "" || !hasCardinality(&config) {
+			continue
+		}
+		settings := fmt.Sprint(config.MinChildren, config.MaxChildren, config.Distribution, config.Mean, config.StdDev, config.Lambda, config.Exponent)
+		if c, ok := ci.cardinalities[config.ID]; ok {
+			if c.settings != settings {
+				return fmt.Errorf("invalid generator for field '%s'\n  cause: all the fields using reference %d have to use the same 'minChildren', 'maxChildren' and 'distribution'", f.Name, config.ID)
+			}
+			continue
+		}
+		r, ok := ci.references[config.ID]
+		if !ok {
+			// the error is returned when the generator is created
+			continue
+		}
+		c, err := ci.newCardinality(&config, ci.Count, r.values.size, ci.Count)
+		if err != nil {
+			return fmt.Errorf("invalid generator for field '%s'\n  cause: %v", f.Name, err)
+		}
+		c.settings = settings
+		ci.cardinalities[config.ID] = c
+	}
+	return ci.assignEdges(content)
+}
+
+// cardinality gives the parent of each document of a collection, with a number of
+// children per parent drawn between config.MinChildren and config.MaxChildren,
+// following config.Distribution.
+//
+// Nothing is stored for the whole collection: parents are split in blocks of ChunkSize
+// parents, and documents in consecutive ranges, one per block, holding a share of the
+// documents proportional to the number of parents of the block. The number of children
+// of the parents of a block only depends on the seed and on the block, and the documents
+// of a range are shuffled among the parents of its block with a permutation. So the parent
+// of a document is computed again when needed, and the children of a parent are spread
+// over the range of documents of its block
+type cardinality struct {
+	settings  string
+	config    Config
+	count     int
+	nbParents int
+	// maximum number of children of a parent without config.MaxChildren
+	limit    int
+	seed     uint64
+	sequence uint64
+}
+
+// newCardinality returns the cardinality of count documents among nbParents parents
+func (ci *CollInfo) newCardinality(config *Config, count, nbParents, limit int) (*cardinality, error) {
+
+	if config.MinChildren < 0 || config.MaxChildren < 0 {
+		return nil, errors.New("'minChildren' and 'maxChildren' have to be positive")
+	}
+	if config.MaxChildren != 0 && config.MinChildren > config.MaxChildren {
+		return nil, errors.New("make sure that 'maxChildren' >= 'minChildren'")
+	}
+
+	min, max := config.MinChildren, config.MaxChildren
+	if config.MaxChildren == 0 && (nbParents == 0 || nbParents*min > count) {
+		return nil, fmt.Errorf("the collection has %d documents, but %d parents with at least %d children each need at least %d documents",
+			count, nbParents, min, nbParents*min)
+	}
+	if config.MaxChildren == 0 && saturatingMul(nbParents, limit) < count {
+		return nil, fmt.Errorf("the collection has %d documents, but %d parents with at most %d children each can only hold %d documents",
+			count, nbParents, limit, nbParents*limit)
+	}
+	if config.MaxChildren != 0 && (nbParents*min > count || nbParents*max < count) {
+		return nil, fmt.Errorf("the collection has %d documents, but %d parents with between %d and %d children each need between %d and %d documents",
+			count, nbParents, min, max, nbParents*min, nbParents*max)
+	}
+	c := &cardinality{
+		config:    *config,
+		count:     count,
+		nbParents: nbParents,
+		limit:     limit,
+		seed:      ci.Seed,
+		// use a dedicated stream, like for the values of a reference. Field
+		// names can't start with '$', so this path can't be the one of a field
+		sequence: pathSequence(fmt.Sprintf("$reference.%d.children", config.ID)),
+	}
+	// check the parameters of the distribution
+	if _, err := c.newCursor().distribution(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// firstDocument returns the index of the first document of the range of block
+func (c *cardinality) firstDocument(block int) int {
+	parents := block * ChunkSize
+	if parents > c.nbParents {
+		parents = c.nbParents
+	}
+	return int(uint64(c.count) * uint64(parents) / uint64(c.nbParents))
+}
+
+func (c *cardinality) newCursor() *parentCursor {
+	return &parentCursor{
+		cardinality: c,
+		stream:      newStream(c.seed, c.sequence),
+		block:       -1,
+	}
+}
+
+// parentCursor gives the parent of documents. It keeps the number of children of
+// the parents of the last block used, so it can't be used concurrently
+type parentCursor struct {
+	*cardinality
+	stream *stream
+	block  int
+	// range of documents of the block
+	first, last int
+	// cumulated number of children of the parents of the block
+	ends []int
+	perm *permutation
+}
+
+// parent returns the index of the parent of the document at index doc
+func (p *parentCursor) parent(doc int) int {
+	parent, _ := p.child(doc)
+	return parent
+}
+
+// child returns the index of the parent of the document at index doc, and
+// the rank of the document among the children of this parent
+func (p *parentCursor) child(doc int) (int, int) {
+	if doc < p.first || doc >= p.last || p.block == -1 {
+		nbBlocks := (p.nbParents + ChunkSize - 1) / ChunkSize
+		p.load(sort.Search(nbBlocks, func(b int) bool { return p.firstDocument(b+1) > doc }))
+	}
+	position := int(p.perm.at(uint64(doc - p.first)))
+	i := sort.SearchInts(p.ends, position+1)
+	if i > 0 {
+		position -= p.ends[i-1]
+	}
+	return p.block*ChunkSize + i, position
+}
+
+// distribution returns the distribution of the number of children, or nil if
+// it's uniform. It uses the stream of p, so it has to be created after a reset
+func (p *parentCursor) distribution() (*distribution, error) {
+	min, max := p.config.MinChildren, p.config.MaxChildren
+	if max == 0 {
+		max = p.limit
+	}
+	return newDistribution(&p.config, float64(min), float64(max), p.stream.pcg64)
+}
+
+// load draws the number of children of the parents of block, and then
+// adjusts them so the total is the number of documents of its range
+func (p *parentCursor) load(block int) {
+
+	p.stream.reset(p.seed, uint64(block))
+	p.block, p.first, p.last = block, p.firstDocument(block), p.firstDocument(block+1)
+
+	nb := p.nbParents - block*ChunkSize
+	if nb > ChunkSize {
+		nb = ChunkSize
+	}
+	min, max := p.config.MinChildren, p.config.MaxChildren
+	if max == 0 {
+		max = p.limit
+	}
+	// parameters are checked when the cardinality is created
+	dist, _ := p.distribution()
+
+	children := make([]int, nb)
+	total := 0
+	for i := range children {
+		switch {
+		case dist != nil:
+			children[i] = int(math.Round(dist.next()))
+		case p.config.MaxChildren != 0:
+			children[i] = min + int(p.stream.pcg32.Bounded(uint32(max-min+1)))
+		default:
+			// without bounds nor distribution, the documents over the minimum
+			// are spread randomly among the parents
+			children[i] = min
+		}
+		total += children[i]
+	}
+	for target := p.last - p.first; total != target; {
+		i := p.stream.pcg32.Bounded(uint32(nb))
+		if total < target && children[i] < max {
+			children[i]++
+			total++
+		}
+		if total > target && children[i] > min {
+			children[i]--
+			total--
+		}
+	}
+
+	p.ends = p.ends[:0]
+	total = 0
+	for _, n := range children {
+		total += n
+		p.ends = append(p.ends, total)
+	}
+	// shuffle the documents, so the children of a
+	// parent aren't next to each other
+	p.perm = newPermutation(uint64(total), p.stream.pcg64)
+}
+
+// Type returns the type of the value of the parent of the current document
+func (g *referenceGenerator) Type() bsontype.Type {
+	return bsontype.Type(g.value()[0])
+}
+
+func (g *referenceGenerator) EncodeValue() {
+	g.buffer.Write(g.value()[1:])
+}
+
+// EncodeValueAsString does nothing, as values are bson encoded
+func (g *referenceGenerator) EncodeValueAsString() {}
+
+// value returns the value of the parent of the document being generated
+func (g *referenceGenerator) value() []byte {
+	if g.edges != nil {
+		node, to := g.edges.nodes(g.buffer.index)
+		if g.to {
+			node = to
+		}
+		return g.nodes[node/ChunkSize%len(g.nodes)].at(node)
+	}
+	if g.parents != nil {
+		return g.values.at(g.parents.parent(g.buffer.index))
+	}
+	if g.random != nil {
+		return g.values.at(g.random.parent(g.buffer.index, g.values.size))
+	}
+	return g.values.at(g.buffer.index % g.values.size)
+}
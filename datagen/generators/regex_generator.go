@@ -0,0 +1,63 @@
+package generators
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MichaelTJones/pcg"
+)
+
+// flags allowed in a bson regular expression
+const regexFlags = "ilmsux"
+
+// Generator for creating random bson regular expressions. The pattern is
+// a random string of a length within [`MinLength`, `MaxLength`]
+type regexGenerator struct {
+	*stringGenerator
+	flags []byte
+}
+
+func newRegexGenerator(config *Config, base base, pcg64 *pcg.PCG64) (Generator, error) {
+
+	for _, f := range config.Flags {
+		if !strings.ContainsRune(regexFlags, f) {
+			return nil, fmt.Errorf("invalid regex flag '%c', must be one of %v", f, strings.Split(regexFlags, ""))
+		}
+		if strings.Count(config.Flags, string(f)) > 1 {
+			return nil, fmt.Errorf("regex flag '%c' is set more than once", f)
+		}
+	}
+	// flags have to be stored in alphabetical order
+	flags := []byte(config.Flags)
+	sort.Slice(flags, func(i, j int) bool { return flags[i] < flags[j] })
+
+	// an empty pattern matches everything, so avoid it by default
+	patternConfig := *config
+	if patternConfig.MinLength == "" {
+		patternConfig.MinLength = "1"
+	}
+	g, err := newStringGenerator(&patternConfig, base, pcg64, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &regexGenerator{
+		stringGenerator: g.(*stringGenerator),
+		flags:           flags,
+	}, nil
+}
+
+func (g *regexGenerator) EncodeValue() {
+	// pattern and flags are both cstrings
+	g.writeLetters(g.randomLength())
+	g.buffer.WriteSingleByte(byte(0))
+	g.buffer.Write(g.flags)
+	g.buffer.WriteSingleByte(byte(0))
+}
+
+func (g *regexGenerator) EncodeValueAsString() {
+	g.buffer.WriteSingleByte('/')
+	g.writeLetters(g.randomLength())
+	g.buffer.WriteSingleByte('/')
+	g.buffer.Write(g.flags)
+}
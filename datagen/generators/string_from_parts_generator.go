@@ -10,13 +10,13 @@ type stringFromPartGenerator struct {
 	parts []Generator
 }
 
-func newStringFromPartsGenerator(config *Config, base base, ci *CollInfo, buffer *DocBuffer) (Generator, error) {
+func newStringFromPartsGenerator(config *Config, base base, ci *CollInfo, buffer *DocBuffer, path string) (Generator, error) {
 	if len(config.Parts) == 0 {
 		return nil, errors.New("'parts' can't be null or empty")
 	}
 
 	parts := make([]Generator, 0, len(config.Parts))
-	for _, part := range config.Parts {
+	for i, part := range config.Parts {
 
 		// if thoses attributes are set, the parts becom a 'fromArray' generator
 		// with pre-computed BSON values that we can't convert back as string
@@ -26,7 +26,7 @@ func newStringFromPartsGenerator(config *Config, base base, ci *CollInfo, buffer
 			return nil, errors.New("parts generator can't have 'unique' or 'maxDistinctValue' attributes")
 		}
 
-		g, err := ci.newGenerator(buffer, "", &part)
+		g, err := ci.newGenerator(buffer, "", fmt.Sprintf("%s.$%d", path, i), &part)
 		if err != nil {
 			return nil, fmt.Errorf("invalid parts generator: %v", err)
 		}
@@ -3,8 +3,9 @@ package generators
 import (
 	"errors"
 	"fmt"
-	"math"
 	"strconv"
+
+	"github.com/MichaelTJones/pcg"
 )
 
 // Generator for creating random string of a length within [`MinLength`, `MaxLength`]
@@ -14,7 +15,7 @@ type stringGenerator struct {
 	maxLength uint32
 }
 
-func newStringGenerator(config *Config, base base, nbDoc int) (g Generator, err error) {
+func newStringGenerator(config *Config, base base, pcg64 *pcg.PCG64, count, valuesPerDoc int) (g Generator, err error) {
 
 	min, max := uint64(0), uint64(8)
 
@@ -37,11 +38,7 @@ func newStringGenerator(config *Config, base base, nbDoc int) (g Generator, err
 	}
 
 	if config.Unique {
-		values, err := uniqueValues(nbDoc, int(max))
-		if err != nil {
-			return nil, err
-		}
-		return newFromArrayGeneratorWithPregeneratedValues(base, values, false)
+		return newUniqueStringGenerator(base, uint32(max), pcg64, count, valuesPerDoc)
 	}
 	return &stringGenerator{
 		base:      base,
@@ -60,28 +57,25 @@ const (
 )
 
 func (g *stringGenerator) EncodeValue() {
-	length := g.minLength
-	if g.minLength != g.maxLength {
-		length = g.pcg32.Bounded(g.maxLength-g.minLength+1) + g.minLength
-	}
+	length := g.randomLength()
 	g.buffer.Write(uint32Bytes(length + 1))
-	cache, remain := g.pcg32.Random(), letterIdxMax
-	for i := 0; i < int(length); i++ {
-		if remain == 0 {
-			cache, remain = g.pcg32.Random(), letterIdxMax
-		}
-		g.buffer.WriteSingleByte(letterBytes[cache&letterIdxMask])
-		cache >>= letterIdxBits
-		remain--
-	}
+	g.writeLetters(length)
 	g.buffer.WriteSingleByte(byte(0))
 }
 
 func (g *stringGenerator) EncodeValueAsString() {
-	length := g.minLength
-	if g.minLength != g.maxLength {
-		length = g.pcg32.Bounded(g.maxLength-g.minLength+1) + g.minLength
+	g.writeLetters(g.randomLength())
+}
+
+func (g *stringGenerator) randomLength() uint32 {
+	if g.minLength == g.maxLength {
+		return g.minLength
 	}
+	return g.pcg32.Bounded(g.maxLength-g.minLength+1) + g.minLength
+}
+
+// writeLetters writes length random letters from letterBytes
+func (g *stringGenerator) writeLetters(length uint32) {
 	cache, remain := g.pcg32.Random(), letterIdxMax
 	for i := 0; i < int(length); i++ {
 		if remain == 0 {
@@ -93,49 +87,57 @@ func (g *stringGenerator) EncodeValueAsString() {
 	}
 }
 
-type unique struct {
-	values       [][]byte
-	currentIndex int
+// Generator for creating unique strings of a fixed length. The first
+// characters of each string, up to 10, hold a unique number written
+// with the 64 letters of letterBytes, and the remaining characters are random
+type uniqueStringGenerator struct {
+	base
+	*uniqueSequence
+	length       uint32
+	prefixLength int
 }
 
-// recursively generate all possible combinations with repeat
-func (u *unique) recur(data []byte, stringSize int, index int, docCount int) {
-	for i := 0; i < len(letterBytes); i++ {
-		if u.currentIndex < docCount {
-			data[index+4] = letterBytes[i]
-			if index == stringSize-1 {
-				tmp := make([]byte, len(data))
-				copy(tmp, data)
-				u.values[u.currentIndex] = tmp
-				u.currentIndex++
-			} else {
-				u.recur(data, stringSize, index+1, docCount)
-			}
-		}
-	}
-}
+// strings of length 10 can already hold 64^10 = 2^60 unique numbers
+const maxUniquePrefixLength = 10
 
-// generate an array of length 'docCount' containing unique string
-// array will look like (for stringSize=3)
-// [ "aaa", "aab", "aac", ...]
-func uniqueValues(docCount int, stringSize int) ([][]byte, error) {
-	if stringSize == 0 {
-		return nil, fmt.Errorf("with unique generator, MinLength has to be > 0")
+func newUniqueStringGenerator(base base, length uint32, pcg64 *pcg.PCG64, count, valuesPerDoc int) (Generator, error) {
+	if length == 0 {
+		return nil, errors.New("with unique generator, MaxLength has to be > 0")
 	}
-	// if string size >= 5, there is at least 1073741824 possible string, so don't bother checking collection count
-	if stringSize < 5 {
-		maxNumber := int(math.Pow(float64(len(letterBytes)), float64(stringSize)))
-		if docCount > maxNumber {
-			return nil, fmt.Errorf("doc count is greater than possible value for string of size %d, max is %vd( %d^%d) ", stringSize, maxNumber, len(letterBytes), stringSize)
-		}
+	prefixLength := int(length)
+	if prefixLength > maxUniquePrefixLength {
+		prefixLength = maxUniquePrefixLength
 	}
-	u := &unique{
-		values:       make([][]byte, docCount),
-		currentIndex: 0,
+	seq, err := newUniqueSequence(uint64(1)<<(letterIdxBits*prefixLength), count, valuesPerDoc, pcg64)
+	if err != nil {
+		return nil, err
 	}
-	data := make([]byte, stringSize+5)
-	copy(data[0:4], int32Bytes(int32(stringSize)+1))
+	return &uniqueStringGenerator{
+		base:           base,
+		uniqueSequence: seq,
+		length:         length,
+		prefixLength:   prefixLength,
+	}, nil
+}
 
-	u.recur(data, stringSize, 0, docCount)
-	return u.values, nil
+func (g *uniqueStringGenerator) EncodeValue() {
+	g.buffer.Write(uint32Bytes(g.length + 1))
+	g.writeString()
+	g.buffer.WriteSingleByte(byte(0))
+}
+
+func (g *uniqueStringGenerator) EncodeValueAsString() {
+	g.writeString()
+}
+
+func (g *uniqueStringGenerator) writeString() {
+	// most significant letter first, so strings are sorted
+	// like the numbers they hold
+	v := g.next()
+	for i := g.prefixLength - 1; i >= 0; i-- {
+		g.buffer.WriteSingleByte(letterBytes[(v>>(letterIdxBits*i))&letterIdxMask])
+	}
+	for i := g.prefixLength; i < int(g.length); i++ {
+		g.buffer.WriteSingleByte(letterBytes[g.pcg32.Random()&letterIdxMask])
+	}
 }
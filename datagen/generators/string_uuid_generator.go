@@ -1,21 +1,23 @@
 package generators
 
 import (
+	"github.com/MichaelTJones/pcg"
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
 type stringUUIDGenerator struct {
 	base
+	pcg64 *pcg.PCG64
 }
 
-func newStringUUIDGenerator(base base) (Generator, error) {
+func newStringUUIDGenerator(base base, pcg64 *pcg.PCG64) (Generator, error) {
 	base.bsonType = bson.TypeString
-	return &stringUUIDGenerator{base: base}, nil
+	return &stringUUIDGenerator{base: base, pcg64: pcg64}, nil
 }
 
 func (g *stringUUIDGenerator) EncodeValue() {
-	s := uuid.NewString()
+	s := randomUUID(g.pcg64).String()
 
 	g.buffer.Write(int32Bytes(int32(len(s) + 1)))
 	g.buffer.WriteString(s)
@@ -23,5 +25,17 @@ func (g *stringUUIDGenerator) EncodeValue() {
 }
 
 func (g *stringUUIDGenerator) EncodeValueAsString() {
-	g.buffer.WriteString(uuid.NewString())
+	g.buffer.WriteString(randomUUID(g.pcg64).String())
+}
+
+// randomUUID returns a version 4 UUID built from pcg64 instead of
+// crypto/rand, so it can be reproduced from the seed
+func randomUUID(pcg64 *pcg.PCG64) uuid.UUID {
+	var u uuid.UUID
+	copy(u[0:8], uint64Bytes(pcg64.Random()))
+	copy(u[8:16], uint64Bytes(pcg64.Random()))
+
+	u[6] = (u[6] & 0x0f) | 0x40 // version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // variant is 10
+	return u
 }
@@ -0,0 +1,99 @@
+package generators
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Case is a generator used by a `switch` generator when its condition
+// matches the fields already generated in the document
+type Case struct {
+	// Condition on the fields generated before, like a MongoDB query
+	Condition bson.M `json:"condition"`
+	// Generator used to create the value when the condition matches
+	Generator Config `json:"generator"`
+}
+
+// Generator for creating values with the generator of the first case whose
+// condition matches the document, or with the default generator if no
+// condition matches. The field is missing if there is no default generator
+type switchGenerator struct {
+	base
+	conditions  []*condition
+	generators  []Generator
+	defaultCase Generator
+	// generator picked for the current value
+	current Generator
+}
+
+func newSwitchGenerator(config *Config, base base, ci *CollInfo, buffer *DocBuffer, path string) (Generator, error) {
+	if len(config.Cases) == 0 {
+		return nil, errors.New("'cases' can't be null or empty")
+	}
+
+	g := &switchGenerator{base: base}
+	for i := range config.Cases {
+		c, err := newCondition(config.Cases[i].Condition)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition for case %d: %v", i, err)
+		}
+		if c == nil {
+			return nil, fmt.Errorf("condition of case %d can't be null or empty", i)
+		}
+		// generators write the key of the field themselves
+		gen, err := ci.newGenerator(buffer, string(base.key), fmt.Sprintf("%s.$%d", path, i), &config.Cases[i].Generator)
+		if err != nil {
+			return nil, fmt.Errorf("invalid generator for case %d: %v", i, err)
+		}
+		g.conditions = append(g.conditions, c)
+		g.generators = append(g.generators, gen)
+	}
+
+	if config.Default != nil {
+		gen, err := ci.newGenerator(buffer, string(base.key), path+".$default", config.Default)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'default' generator: %v", err)
+		}
+		g.defaultCase = gen
+	}
+	return g, nil
+}
+
+// Exists returns false if no condition matches and there is no default generator
+func (g *switchGenerator) Exists() bool {
+	if !g.base.Exists() {
+		return false
+	}
+	g.current = g.pick()
+	return g.current != nil
+}
+
+func (g *switchGenerator) pick() Generator {
+	for i, c := range g.conditions {
+		if c.match(g.buffer) {
+			return g.generators[i]
+		}
+	}
+	return g.defaultCase
+}
+
+func (g *switchGenerator) selected() Generator { return g.current }
+
+func (g *switchGenerator) choices() []Generator {
+	if g.defaultCase != nil {
+		return append(g.generators[:len(g.generators):len(g.generators)], g.defaultCase)
+	}
+	return g.generators
+}
+
+func (g *switchGenerator) EncodeValue() {
+	encodeElement(g.buffer, g.current)
+}
+
+func (g *switchGenerator) EncodeValueAsString() {
+	if gen := g.pick(); gen != nil {
+		gen.EncodeValueAsString()
+	}
+}
@@ -0,0 +1,195 @@
+package generators
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A template is a string with placeholders between double curly braces, like
+//
+//	{{firstName | lower}}.{{lastName | lower}}@{{$domain}}
+//
+// Each placeholder is an expression, replaced by its value in the string
+type template struct {
+	// texts between the placeholders. There is one more text than
+	// placeholders, as the template starts and ends with a text
+	texts        []string
+	placeholders []*expression
+}
+
+func parseTemplate(src string) (*template, error) {
+	t := &template{}
+	rest, offset := src, 0
+	for {
+		start := strings.Index(rest, "{{")
+		if start == -1 {
+			t.texts = append(t.texts, rest)
+			return t, nil
+		}
+		end := strings.Index(rest[start+2:], "}}")
+		if end == -1 {
+			return nil, fmt.Errorf("'{{' at position %d is never closed", offset+start)
+		}
+		placeholder := rest[start+2 : start+2+end]
+		expr, err := parseExpression(placeholder)
+		if err != nil {
+			return nil, fmt.Errorf("invalid placeholder '{{%s}}' at position %d: %v", placeholder, offset+start, err)
+		}
+		t.texts = append(t.texts, rest[:start])
+		t.placeholders = append(t.placeholders, expr)
+
+		rest, offset = rest[start+2+end+2:], offset+start+2+end+2
+	}
+}
+
+// fields returns the paths of the fields used by the template
+func (t *template) fields() [][]string {
+	var fields [][]string
+	for _, p := range t.placeholders {
+		fields = append(fields, p.fields...)
+	}
+	return fields
+}
+
+// eval returns the template with its placeholders replaced by their value,
+// or nil if one of them is null
+func (t *template) eval(ctx *evalContext) any {
+	var sb strings.Builder
+	for i, p := range t.placeholders {
+		v := p.root.eval(ctx)
+		if v == nil {
+			return nil
+		}
+		sb.WriteString(t.texts[i])
+		sb.WriteString(formatValue(v))
+	}
+	sb.WriteString(t.texts[len(t.texts)-1])
+	return sb.String()
+}
+
+// variable is a generator used in a template, like '$name'. Its value is
+// generated once per document, so it's the same in all the placeholders
+type variable struct {
+	generator Generator
+	buffer    *DocBuffer
+	value     any
+	generated bool
+}
+
+func (v *variable) get() any {
+	if !v.generated {
+		v.value = v.generate()
+		v.generated = true
+	}
+	return v.value
+}
+
+// generate writes the element of the generator at the end of the
+// buffer to read its value, and removes it from the buffer
+func (v *variable) generate() any {
+	pos := v.buffer.Len()
+	encodeElement(v.buffer, v.generator)
+	value, found := lookupGenerated(v.buffer.Bytes()[pos:], []string{string(v.generator.Key())})
+	var result any
+	if found {
+		result = goValue(value)
+	}
+	v.buffer.Truncate(pos)
+	return result
+}
+
+// Generator for creating strings from a template whose placeholders can
+// use the fields generated before in the document and other generators
+type templateGenerator struct {
+	base
+	template  *template
+	variables map[string]*variable
+	// reference date returned by now()
+	now time.Time
+	// value computed for the current document
+	current any
+}
+
+func newTemplateGenerator(config *Config, base base, ci *CollInfo, buffer *DocBuffer, path string) (Generator, error) {
+	if config.Template == "" {
+		return nil, errors.New("'template' can't be null or empty")
+	}
+	t, err := parseTemplate(config.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template '%s': %v", config.Template, err)
+	}
+	for _, field := range t.fields() {
+		if err := ci.checkGeneratedBefore(field, path, "template"); err != nil {
+			return nil, err
+		}
+	}
+
+	// sort the names so generators are always created in the same order
+	names := make([]string, 0, len(config.Variables))
+	for name := range config.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	variables := make(map[string]*variable, len(names))
+	for _, name := range names {
+		variableConfig := config.Variables[name]
+		g, err := ci.newGenerator(buffer, "", fmt.Sprintf("%s.$%s", path, name), &variableConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid generator for '$%s': %v", name, err)
+		}
+		variables[name] = &variable{generator: g, buffer: buffer}
+	}
+	for _, p := range t.placeholders {
+		for _, name := range p.variables {
+			if _, ok := variables[name]; !ok {
+				return nil, fmt.Errorf("generator '$%s' used by template is not defined in 'variables'", name)
+			}
+		}
+	}
+
+	return &templateGenerator{
+		base:      base,
+		template:  t,
+		variables: variables,
+		now:       referenceDate(config),
+	}, nil
+}
+
+// Exists computes the value of the template, as it's null if one of
+// the placeholders is null
+func (g *templateGenerator) Exists() bool {
+	if !g.base.Exists() {
+		return false
+	}
+	if !g.null {
+		g.current = g.eval()
+		g.null = g.current == nil
+	}
+	return true
+}
+
+func (g *templateGenerator) eval() any {
+	doc := g.buffer.Bytes()
+	if len(doc) < 4 {
+		return nil
+	}
+	for _, v := range g.variables {
+		v.generated = false
+	}
+	return g.template.eval(&evalContext{doc: doc[4:], now: g.now, variables: g.variables})
+}
+
+func (g *templateGenerator) EncodeValue() {
+	s, _ := g.current.(string)
+	g.buffer.Write(int32Bytes(int32(len(s) + 1)))
+	g.buffer.WriteString(s)
+	g.buffer.WriteSingleByte(byte(0))
+}
+
+func (g *templateGenerator) EncodeValueAsString() {
+	g.buffer.WriteString(formatValue(g.eval()))
+}
@@ -0,0 +1,88 @@
+package generators
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/MichaelTJones/pcg"
+)
+
+// Generator for creating random bson timestamps. The time is within
+// [`StartDate`, `EndDate`], and the increment within [`Min`, `Max`]
+type timestampGenerator struct {
+	base
+	startDate    uint64
+	delta        uint64
+	minIncrement uint32
+	maxIncrement uint32
+	pcg64        *pcg.PCG64
+}
+
+func newTimestampGenerator(config *Config, base base, pcg64 *pcg.PCG64) (g Generator, err error) {
+
+	start, end := config.StartDate.Unix(), config.EndDate.Unix()
+	if start > end {
+		return nil, errors.New("make sure that 'startDate' < 'endDate'")
+	}
+	// the time of a timestamp is an uint32
+	if start < 0 || end > math.MaxUint32 {
+		return nil, errors.New("'startDate' and 'endDate' have to be between 1970-01-01T00:00:00Z and 2106-02-07T06:28:15Z")
+	}
+
+	min, max := uint64(1), uint64(1000)
+
+	if config.Min != "" {
+		min, err = strconv.ParseUint(string(config.Min), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse number '%s' as an uint32:\n%w", config.Min, err)
+		}
+	}
+
+	if config.Max != "" {
+		max, err = strconv.ParseUint(string(config.Max), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse number '%s' as an uint32:\n%w", config.Max, err)
+		}
+	}
+
+	if min > max {
+		return nil, errors.New("make sure that 'max' >= 'min'")
+	}
+
+	return &timestampGenerator{
+		base:         base,
+		startDate:    uint64(start),
+		delta:        uint64(end - start),
+		minIncrement: uint32(min),
+		maxIncrement: uint32(max),
+		pcg64:        pcg64,
+	}, nil
+}
+
+func (g *timestampGenerator) EncodeValue() {
+	t, i := g.nextTimestamp()
+	// increment first, as both are stored in a little endian uint64
+	g.buffer.Write(uint32Bytes(i))
+	g.buffer.Write(uint32Bytes(t))
+}
+
+func (g *timestampGenerator) EncodeValueAsString() {
+	t, i := g.nextTimestamp()
+	g.buffer.WriteString("Timestamp(")
+	g.buffer.WriteString(strconv.FormatUint(uint64(t), 10))
+	g.buffer.WriteString(", ")
+	g.buffer.WriteString(strconv.FormatUint(uint64(i), 10))
+	g.buffer.WriteSingleByte(')')
+}
+
+func (g *timestampGenerator) nextTimestamp() (t uint32, i uint32) {
+	t = uint32(g.pcg64.Bounded(g.delta+1) + g.startDate)
+	i = g.minIncrement
+	if g.minIncrement != g.maxIncrement {
+		// the range holds at most 2^32 values, which overflows an uint32
+		i = uint32(g.pcg64.Bounded(uint64(g.maxIncrement-g.minIncrement)+1)) + g.minIncrement
+	}
+	return t, i
+}
@@ -0,0 +1,323 @@
+package generators
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// values a tree generator can create for a node, see https://github.com/feliixx/mgodatagen/blob/master/README.md#tree for details
+const (
+	TreeParent    = "parent"
+	TreeAncestors = "ancestors"
+	TreeChildren  = "children"
+	TreePath      = "path"
+	TreeDepth     = "depth"
+	TreeLeft      = "left"
+	TreeRight     = "right"
+)
+
+var treeNodes = []string{TreeParent, TreeAncestors, TreeChildren, TreePath, TreeDepth, TreeLeft, TreeRight}
+
+// tree is the shape of a forest whose nodes are the documents of a collection, numbered
+// in breadth-first order: the roots are the first documents, followed by their children,
+// then by the children of their children, and so on. Each node has 'fanOut' children,
+// except the nodes of the last levels, so the parent, the children and the position of
+// a node are computed from its index, without storing anything
+type tree struct {
+	settings string
+	count    int
+	fanOut   int
+	roots    int
+}
+
+func newTree(config *Config, count int) (*tree, error) {
+
+	if config.FanOut < 1 {
+		return nil, errors.New("'fanOut' has to be >= 1")
+	}
+	if config.Depth < 0 {
+		return nil, errors.New("'depth' has to be positive")
+	}
+	if config.FanOut == 1 && config.Depth == 0 {
+		return nil, errors.New("with a 'fanOut' of 1, 'depth' is required, otherwise the tree is a single list of nodes")
+	}
+	t := &tree{
+		settings: fmt.Sprint(config.FanOut, config.Depth),
+		count:    count,
+		// a node can't have more children than the number of nodes
+		fanOut: config.FanOut,
+		roots:  1,
+	}
+	if t.fanOut > count {
+		t.fanOut = count
+	}
+	if config.Depth != 0 {
+		// use as many trees as needed to hold all the nodes
+		perTree, levelSize := 0, 1
+		for level := 0; level <= config.Depth && perTree < count; level++ {
+			perTree += levelSize
+			levelSize = saturatingMul(levelSize, t.fanOut)
+			if levelSize > count {
+				levelSize = count
+			}
+		}
+		t.roots = (count + perTree - 1) / perTree
+	}
+	return t, nil
+}
+
+func (t *tree) isRoot(i int) bool {
+	return i < t.roots
+}
+
+func (t *tree) parent(i int) int {
+	return (i - t.roots) / t.fanOut
+}
+
+func (t *tree) depth(i int) int {
+	d := 0
+	for !t.isRoot(i) {
+		i = t.parent(i)
+		d++
+	}
+	return d
+}
+
+// firstChild returns the index of the first child of i, or t.count
+// if i has no children
+func (t *tree) firstChild(i int) int {
+	if i >= (t.count-t.roots)/t.fanOut+1 {
+		return t.count
+	}
+	if c := t.roots + i*t.fanOut; c < t.count {
+		return c
+	}
+	return t.count
+}
+
+// size returns the number of nodes of the subtrees of the nodes from first to last,
+// which are consecutive nodes of the same level
+func (t *tree) size(first, last int) int {
+	n := 0
+	for first <= last && first < t.count {
+		if last >= t.count {
+			last = t.count - 1
+		}
+		n += last - first + 1
+		// the children of consecutive nodes are consecutive
+		first, last = t.firstChild(first), t.firstChild(last)+t.fanOut-1
+	}
+	return n
+}
+
+// preorder returns the position of i in a depth-first traversal of the forest
+func (t *tree) preorder(i int) int {
+	if t.isRoot(i) {
+		return t.size(0, i-1)
+	}
+	p := t.parent(i)
+	return t.preorder(p) + 1 + t.size(t.firstChild(p), i-1)
+}
+
+// left returns the left value of i in the nested sets model. Values are
+// given by a depth-first traversal of the forest, starting at 1, and
+// incremented when a node is entered and when it's left
+func (t *tree) left(i int) int {
+	return 2*t.preorder(i) - t.depth(i) + 1
+}
+
+func (t *tree) right(i int) int {
+	return t.left(i) + 2*t.size(i, i) - 1
+}
+
+// Generator for creating the fields of a tree whose nodes are the documents of the
+// collection, like the parent or the ancestors of the node. Ids of the nodes are the
+// values of a reference of the collection
+type treeGenerator struct {
+	base
+	tree      *tree
+	node      string
+	separator string
+	source    *valueSource
+	// cursors over the ids of the nodes, by depth. The ancestors of consecutive
+	// documents are consecutive documents at each depth, so this keeps
+	// the ids generated in order
+	ids []*valueCursor
+}
+
+func newTreeGenerator(config *Config, base base, ci *CollInfo) (Generator, error) {
+
+	if !contains(treeNodes, config.Node) {
+		return nil, fmt.Errorf("invalid 'node' '%s', must be one of %v", config.Node, treeNodes)
+	}
+	if ci.valuesPerDoc > 1 {
+		return nil, errors.New("a tree field can't be inside an array")
+	}
+	r, ok := ci.references[config.ID]
+	if !ok || r.values.ci != ci || !r.inAllDocuments {
+		return nil, fmt.Errorf("ids of the nodes have to be the values of a top level field of the collection with 'refContent' and 'id' %d, present in all its documents", config.ID)
+	}
+
+	t, ok := ci.trees[config.ID]
+	if !ok {
+		var err error
+		t, err = newTree(config, ci.Count)
+		if err != nil {
+			return nil, err
+		}
+		ci.trees[config.ID] = t
+	} else if t.settings != fmt.Sprint(config.FanOut, config.Depth) {
+		return nil, fmt.Errorf("all the tree fields using reference %d have to use the same 'fanOut' and 'depth'", config.ID)
+	}
+	if (config.Node == TreeLeft || config.Node == TreeRight) && ci.Count > math.MaxInt32/2 {
+		return nil, fmt.Errorf("'%s' can't be used with more than %d documents", config.Node, math.MaxInt32/2)
+	}
+
+	separator := config.Separator
+	if separator == "" {
+		separator = ","
+	}
+	return &treeGenerator{
+		base:      base,
+		tree:      t,
+		node:      config.Node,
+		separator: separator,
+		source:    r.values,
+	}, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// id returns the id of the node i at depth, with its bson type
+func (g *treeGenerator) id(i, depth int) []byte {
+	for len(g.ids) <= depth {
+		cursor, err := g.source.newCursor()
+		if err != nil {
+			// the generator of the values was created
+			// successfully with the same config
+			panic(err)
+		}
+		g.ids = append(g.ids, cursor)
+	}
+	return g.ids[depth].at(i)
+}
+
+// ancestors returns the ancestors of i, from the root to its parent
+func (g *treeGenerator) ancestors(i int) []int {
+	var ancestors []int
+	for !g.tree.isRoot(i) {
+		i = g.tree.parent(i)
+		ancestors = append(ancestors, i)
+	}
+	for l, r := 0, len(ancestors)-1; l < r; l, r = l+1, r-1 {
+		ancestors[l], ancestors[r] = ancestors[r], ancestors[l]
+	}
+	return ancestors
+}
+
+// IsNull returns true for the parent and the path of a root, as it has no ancestors
+func (g *treeGenerator) IsNull() bool {
+	if (g.node == TreeParent || g.node == TreePath) && g.tree.isRoot(g.buffer.index) {
+		return true
+	}
+	return g.base.IsNull()
+}
+
+func (g *treeGenerator) Type() bsontype.Type {
+	switch g.node {
+	case TreeParent:
+		i := g.buffer.index
+		return bsontype.Type(g.id(g.tree.parent(i), g.tree.depth(i)-1)[0])
+	case TreeAncestors, TreeChildren:
+		return bson.TypeArray
+	case TreePath:
+		return bson.TypeString
+	}
+	return bson.TypeInt32
+}
+
+func (g *treeGenerator) EncodeValue() {
+
+	i := g.buffer.index
+	switch g.node {
+
+	case TreeParent:
+		g.buffer.Write(g.id(g.tree.parent(i), g.tree.depth(i)-1)[1:])
+
+	case TreeAncestors:
+		ancestors := g.ancestors(i)
+		g.encodeArray(len(ancestors), func(n int) []byte { return g.id(ancestors[n], n) })
+
+	case TreeChildren:
+		first, depth := g.tree.firstChild(i), g.tree.depth(i)+1
+		nb := g.tree.count - first
+		if nb > g.tree.fanOut {
+			nb = g.tree.fanOut
+		}
+		g.encodeArray(nb, func(n int) []byte { return g.id(first+n, depth) })
+
+	case TreePath:
+		current := g.buffer.Len()
+		g.buffer.Reserve()
+		g.buffer.WriteString(g.separator)
+		for depth, a := range g.ancestors(i) {
+			id := g.id(a, depth)
+			g.buffer.WriteString(formatValue(goValue(bson.RawValue{Type: bsontype.Type(id[0]), Value: id[1:]})))
+			g.buffer.WriteString(g.separator)
+		}
+		g.buffer.WriteSingleByte(byte(0))
+		g.buffer.WriteAt(current, int32Bytes(int32(g.buffer.Len()-current-4)))
+
+	case TreeDepth:
+		g.buffer.Write(int32Bytes(int32(g.tree.depth(i))))
+
+	case TreeLeft:
+		g.buffer.Write(int32Bytes(int32(g.tree.left(i))))
+
+	case TreeRight:
+		g.buffer.Write(int32Bytes(int32(g.tree.right(i))))
+	}
+}
+
+// encodeArray writes an array of nb values, value(n) returning the n-th
+// value with its bson type
+func (g *treeGenerator) encodeArray(nb int, value func(n int) []byte) {
+	current := g.buffer.Len()
+	g.buffer.Reserve()
+	for n := 0; n < nb; n++ {
+		v := value(n)
+		g.buffer.WriteSingleByte(v[0])
+		if n < 10 {
+			g.buffer.WriteSingleByte(indexesBytes[n])
+		} else {
+			g.buffer.WriteString(strconv.Itoa(n))
+		}
+		g.buffer.WriteSingleByte(byte(0))
+		g.buffer.Write(v[1:])
+	}
+	g.buffer.WriteSingleByte(byte(0))
+	g.buffer.WriteAt(current, int32Bytes(int32(g.buffer.Len()-current)))
+}
+
+func (g *treeGenerator) EncodeValueAsString() {
+	switch g.node {
+	case TreeDepth:
+		g.buffer.WriteString(strconv.Itoa(g.tree.depth(g.buffer.index)))
+	case TreeLeft:
+		g.buffer.WriteString(strconv.Itoa(g.tree.left(g.buffer.index)))
+	case TreeRight:
+		g.buffer.WriteString(strconv.Itoa(g.tree.right(g.buffer.index)))
+	}
+}
@@ -0,0 +1,90 @@
+package generators
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/MichaelTJones/pcg"
+)
+
+// permutation is a seeded bijection of [0, size). It's used to generate unique
+// values without storing them: the n-th value is permutation.at(n), so two
+// documents never get the same value.
+//
+// It's a 4 rounds Feistel network on the smallest even number of bits holding
+// size. Results out of the range are encrypted again until they fit in it (cycle
+// walking). As 2^bits < 4*size, this takes less than 4 rounds on average
+type permutation struct {
+	size     uint64
+	halfBits uint
+	mask     uint64
+	keys     [4]uint64
+}
+
+func newPermutation(size uint64, pcg64 *pcg.PCG64) *permutation {
+	b := uint(bits.Len64(size - 1))
+	if b%2 == 1 {
+		b++
+	}
+	if b == 0 {
+		b = 2
+	}
+	p := &permutation{
+		size:     size,
+		halfBits: b / 2,
+		mask:     1<<(b/2) - 1,
+	}
+	for i := range p.keys {
+		p.keys[i] = pcg64.Random()
+	}
+	return p
+}
+
+func (p *permutation) at(n uint64) uint64 {
+	for {
+		n = p.encrypt(n)
+		if n < p.size {
+			return n
+		}
+	}
+}
+
+func (p *permutation) encrypt(n uint64) uint64 {
+	left, right := n>>p.halfBits, n&p.mask
+	for _, key := range p.keys {
+		left, right = right, left^(splitmix64(right^key)&p.mask)
+	}
+	return left<<p.halfBits | right
+}
+
+// uniqueSequence returns the values of a permutation in order. It's a
+// seeker, so the n-th document always gets the same values of the permutation,
+// no matter which DocumentGenerator creates it
+type uniqueSequence struct {
+	perm         *permutation
+	index        uint64
+	valuesPerDoc int
+}
+
+// newUniqueSequence returns a sequence of unique values in [0, size). count is
+// the number of documents, and valuesPerDoc the maximum number of values used
+// by a document, ie the product of the 'maxLength' of the arrays holding the field
+func newUniqueSequence(size uint64, count, valuesPerDoc int, pcg64 *pcg.PCG64) (*uniqueSequence, error) {
+	if valuesPerDoc == 1 && size < uint64(count) {
+		return nil, fmt.Errorf("with 'unique', the range of possible values has to be greater than the number of documents: can only generate %d unique values, but 'count' is %d", size, count)
+	}
+	if needed := saturatingMul(count, valuesPerDoc); size < uint64(needed) {
+		return nil, fmt.Errorf("with 'unique', the range of possible values has to be greater than the number of values to generate: can only generate %d unique values, but 'count' is %d and a document holds up to %d values", size, count, valuesPerDoc)
+	}
+	return &uniqueSequence{perm: newPermutation(size, pcg64), valuesPerDoc: valuesPerDoc}, nil
+}
+
+func (s *uniqueSequence) next() uint64 {
+	v := s.perm.at(s.index)
+	s.index++
+	return v
+}
+
+func (s *uniqueSequence) seek(n int) {
+	s.index = uint64(saturatingMul(n, s.valuesPerDoc))
+}
@@ -0,0 +1,241 @@
+package generators
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// maximum number of times the fields of a tuple are generated
+// again to get a combination not used by a previous document
+const maxUniqueFieldsAttempts = 1000
+
+// UniqueFieldsFilter makes sure that documents never share the same values for
+// a tuple of top level fields, like the fields of a compound unique index.
+//
+// Documents have to be passed to Filter() in order. When a document holds a
+// combination of values already used by a previous document, the fields of the
+// tuple are generated again, so the number of documents doesn't change
+type UniqueFieldsFilter struct {
+	tuples []uniqueTuple
+	// generators used to create new values for the fields
+	// of the tuples, by field name
+	generators map[string]Generator
+	// position of the fields in the content, used to keep
+	// the order of the fields when a missing field is added
+	order  map[string]int
+	buffer *DocBuffer
+	// number of documents filtered so far
+	index int
+}
+
+type uniqueTuple struct {
+	fields []string
+	// fields that can be generated again. Fields with unique values, like
+	// an autoincrement, would get values already used by another document
+	free []string
+	// hashes of the combinations already used
+	seen map[uint64]struct{}
+}
+
+// NewUniqueFieldsFilter returns a filter guaranteeing that the values of each tuple
+// of fields are unique. Fields have to be top level fields of content. It returns nil
+// if there is nothing to check, for example if a field of each tuple is already unique
+func (ci *CollInfo) NewUniqueFieldsFilter(content Content, tuples [][]string) (*UniqueFieldsFilter, error) {
+
+	f := &UniqueFieldsFilter{
+		generators: make(map[string]Generator),
+		order:      make(map[string]int, len(content)),
+		buffer:     NewDocBuffer(),
+	}
+	for i, field := range content {
+		f.order[field.Name] = i
+	}
+
+	ci.streams, ci.seekers = nil, nil
+	defer func() { ci.streams, ci.seekers = nil, nil }()
+
+	// fields generated again can't depend on other fields, nor be used by
+	// the condition or the expression of another field, as it wouldn't be
+	// evaluated again
+	usedByOthers := make(map[string]bool)
+	for i := range content {
+		for _, name := range dependencies(&content[i].Config) {
+			usedByOthers[name] = true
+		}
+	}
+
+Tuples:
+	for _, fields := range tuples {
+
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid 'uniqueFields': a tuple can't be empty")
+		}
+		tuple := uniqueTuple{fields: fields, seen: make(map[uint64]struct{}, ci.Count)}
+
+		for _, name := range fields {
+			config, ok := content.Get(name)
+			if !ok {
+				return nil, fmt.Errorf("invalid 'uniqueFields': field '%s' has to be a top level field of 'content'", name)
+			}
+			if alwaysUnique(&config) {
+				if config.NullPercentage == 0 && config.MissingPercentage == 0 && config.NullValuePercentage == 0 {
+					// combinations of this tuple can't be duplicated
+					continue Tuples
+				}
+				continue
+			}
+			if config.Type == TypeSwitch || len(dependencies(&config)) > 0 {
+				return nil, fmt.Errorf("invalid 'uniqueFields': field '%s' depends on other fields, so it can't be generated again to avoid duplicates", name)
+			}
+			if (config.Type == TypeReference || config.Type == TypeRef) && (hasCardinality(&config) || config.Fields != nil || config.Edge != "" || (config.RefContent == nil && ci.cardinalities[config.ID] != nil)) {
+				return nil, fmt.Errorf("invalid 'uniqueFields': field '%s' depends on the document it references, so it can't be generated again to avoid duplicates", name)
+			}
+			if config.Type == TypeTree {
+				return nil, fmt.Errorf("invalid 'uniqueFields': field '%s' depends on the position of the document in the tree, so it can't be generated again to avoid duplicates", name)
+			}
+			if usedByOthers[name] {
+				return nil, fmt.Errorf("invalid 'uniqueFields': field '%s' is used by another field, so it can't be generated again to avoid duplicates", name)
+			}
+			tuple.free = append(tuple.free, name)
+			for _, r := range ci.references {
+				if r.collection != nil && r.collection.ci == ci {
+					r.collection.regenerated[name] = true
+				}
+			}
+			if _, ok := f.generators[name]; ok {
+				continue
+			}
+			// use a dedicated path, so the new values don't depend on
+			// the values of the field
+			g, err := ci.newGenerator(f.buffer, name, name+".$unique", &config)
+			if err != nil {
+				return nil, fmt.Errorf("invalid generator for field '%s'\n  cause: %v", name, err)
+			}
+			f.generators[name] = g
+		}
+		if len(tuple.free) == 0 {
+			return nil, fmt.Errorf("invalid 'uniqueFields': fields %v can't be generated again to avoid duplicates, because their values are already unique", fields)
+		}
+		f.tuples = append(f.tuples, tuple)
+	}
+	if len(f.tuples) == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// alwaysUnique returns true if the values of a field are unique
+// whenever the field is present
+func alwaysUnique(config *Config) bool {
+	if config.MaxDistinctValue != 0 {
+		return false
+	}
+	return config.Unique || config.Type == TypeObjectID || config.Type == TypeAutoincrement
+}
+
+// Filter returns doc if its combinations of values are new, or a copy of doc where
+// the fields of the duplicated tuples have new values otherwise. An error is returned
+// if no new combination can be found, ie if the fields can't take enough distinct values
+func (f *UniqueFieldsFilter) Filter(doc []byte) ([]byte, error) {
+
+	f.index++
+
+	for attempt := 0; attempt < maxUniqueFieldsAttempts; attempt++ {
+
+		hashes := make([]uint64, len(f.tuples))
+		var duplicated []string
+		for i := range f.tuples {
+			hashes[i] = tupleHash(doc, f.tuples[i].fields)
+			if _, ok := f.tuples[i].seen[hashes[i]]; ok {
+				duplicated = append(duplicated, f.tuples[i].free...)
+			}
+		}
+		if len(duplicated) == 0 {
+			for i := range f.tuples {
+				f.tuples[i].seen[hashes[i]] = struct{}{}
+			}
+			return doc, nil
+		}
+		doc = f.regenerate(doc, duplicated)
+	}
+
+	var fields []string
+	for _, t := range f.tuples {
+		fields = append(fields, "["+strings.Join(t.fields, ", ")+"]")
+	}
+	return nil, fmt.Errorf("couldn't find a new combination of values for 'uniqueFields' %s in document %d after %d attempts. Make sure that the fields can take enough distinct values", strings.Join(fields, ", "), f.index, maxUniqueFieldsAttempts)
+}
+
+// tupleHash returns a hash of the values of fields in doc. Missing fields
+// are considered as null, like in a unique index
+func tupleHash(doc bson.Raw, fields []string) uint64 {
+	h := fnv.New64a()
+	for _, name := range fields {
+		v, err := doc.LookupErr(name)
+		if err != nil {
+			v = bson.RawValue{Type: bson.TypeNull}
+		}
+		h.Write([]byte{byte(v.Type)})
+		h.Write(int32Bytes(int32(len(v.Value))))
+		h.Write(v.Value)
+	}
+	return h.Sum64()
+}
+
+// regenerate returns a copy of doc where fields have new values. As
+// generators may skip a field because of its missingPercentage, fields
+// may be removed from or added to the document
+func (f *UniqueFieldsFilter) regenerate(doc bson.Raw, fields []string) []byte {
+
+	newElements := make(map[string][]byte, len(fields))
+	for _, name := range fields {
+		if _, ok := newElements[name]; ok {
+			continue
+		}
+		g := f.generators[name]
+		f.buffer.Truncate(0)
+		encodeElement(f.buffer, g)
+		newElements[name] = append([]byte(nil), f.buffer.Bytes()...)
+	}
+
+	elements, _ := doc.Elements()
+	result := make([]byte, 4, len(doc)+32)
+
+	// fields of the document follow the order of the content, so a field
+	// added back is written before the first field declared after it
+	sorted := make([]string, 0, len(newElements))
+	for name := range newElements {
+		sorted = append(sorted, name)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return f.order[sorted[i]] < f.order[sorted[j]] })
+
+	written := make(map[string]bool, len(newElements))
+	writeUntil := func(position int) {
+		for _, name := range sorted {
+			if !written[name] && f.order[name] < position {
+				result = append(result, newElements[name]...)
+				written[name] = true
+			}
+		}
+	}
+	for _, e := range elements {
+		key := e.Key()
+		position, ok := f.order[key]
+		if !ok {
+			position = len(f.order)
+		}
+		writeUntil(position)
+		if _, replaced := newElements[key]; !replaced {
+			result = append(result, e...)
+		}
+	}
+	writeUntil(len(f.order) + 1)
+
+	result = append(result, byte(0))
+	copy(result, int32Bytes(int32(len(result))))
+	return result
+}
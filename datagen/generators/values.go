@@ -0,0 +1,269 @@
+package generators
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/MichaelTJones/pcg"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// valueSource describes the values of a reference, or of a field with 'maxDistinctValue'.
+//
+// Values aren't stored: the random streams of the generator are reset before each value,
+// so the n-th value only depends on the seed and on n, and is generated again when needed.
+// This keeps the memory used independent of the number of values, and a value picked at
+// random can be generated alone.
+//
+// Each value is stored with its bson type, as the values of some generators, like
+// an enum, can have different types
+type valueSource struct {
+	// CollInfo of the collection the values belong to
+	ci     *CollInfo
+	key    string
+	path   string
+	config Config
+	size   int
+}
+
+// newValueSource returns the source of size values generated from config
+func (ci *CollInfo) newValueSource(key, path string, config *Config, size int) (*valueSource, error) {
+
+	if size < 0 {
+		return nil, errors.New("maxDistinctValue can't be negative")
+	}
+	s := &valueSource{
+		ci:     ci,
+		key:    key,
+		path:   path,
+		config: *config,
+		size:   size,
+	}
+	// each value is generated once, whether the field
+	// holding it exists or not
+	s.config.NullPercentage, s.config.MissingPercentage, s.config.NullValuePercentage = 0, 0, 0
+	s.config.Condition = nil
+	cursor, err := s.newCursor()
+	if err != nil {
+		return nil, fmt.Errorf("error while creating base array: %v", err)
+	}
+	if size > 1 && bytes.Equal(cursor.at(0), cursor.at(1)) {
+		return nil, errors.New("couldn't generate enough unique values")
+	}
+	return s, nil
+}
+
+// newCursor returns a cursor over the values of s, with its own generator
+func (s *valueSource) newCursor() (*valueCursor, error) {
+
+	buffer := NewDocBuffer()
+	tmpCi := NewCollInfo(s.ci.Count, s.ci.Version, s.ci.Seed, s.ci.references)
+	tmpCi.Namespace = s.ci.Namespace
+	// use a dedicated path so the values don't depend on the stream
+	// of the field itself. Field names can't start with '$', so this
+	// path can't be the one of another field
+	g, err := tmpCi.newGenerator(buffer, s.key, s.path+".$values", &s.config)
+	if err != nil {
+		return nil, err
+	}
+	streams, seekers := tmpCi.streams, tmpCi.seekers
+
+	generate := func(i int, data []byte) []byte {
+		for _, st := range streams {
+			st.reset(s.ci.Seed, uint64(i))
+		}
+		for _, sk := range seekers {
+			sk.seek(i)
+		}
+		buffer.Truncate(0)
+		buffer.index = i
+		g.EncodeValue()
+		value := buffer.Bytes()
+		if g.Type() == bson.TypeNull {
+			// the generator writes the type and the key of the
+			// element itself, like a constant. Remove the key
+			data = append(data, value[0])
+			value = value[2+len(g.Key()):]
+		} else {
+			data = append(data, byte(g.Type()))
+		}
+		return append(data, value...)
+	}
+
+	cursor := newValueCursor(s.size, func(chunk int, data []byte, ends []int) ([]byte, []int) {
+		end := (chunk + 1) * ChunkSize
+		if end > s.size {
+			end = s.size
+		}
+		for i := chunk * ChunkSize; i < end; i++ {
+			data = generate(i, data)
+			ends = append(ends, len(data))
+		}
+		return data, ends
+	})
+	cursor.generate = generate
+	return cursor, nil
+}
+
+// valueCursor gives the values of a collection of values generated by chunks, like
+// the values of a valueSource. It keeps the values of the last chunk used, so values
+// used in order are only generated once. A cursor can't be used concurrently, so
+// each generator has its own cursor
+type valueCursor struct {
+	size int
+	// fill appends the values of chunk to data, and the end of each value to ends
+	fill  func(chunk int, data []byte, ends []int) ([]byte, []int)
+	chunk int
+	data  []byte
+	ends  []int
+	// generate appends the i-th value to data, or is nil if the values can only
+	// be generated by chunks. It's used for the values picked at random
+	generate func(i int, data []byte) []byte
+	// last value generated alone, and its index
+	value []byte
+	index int
+}
+
+func newValueCursor(size int, fill func(chunk int, data []byte, ends []int) ([]byte, []int)) *valueCursor {
+	return &valueCursor{
+		size:  size,
+		fill:  fill,
+		chunk: -1,
+		index: -1,
+	}
+}
+
+// at returns the i-th value. The slice is only valid until the next call
+func (c *valueCursor) at(i int) []byte {
+	if chunk := i / ChunkSize; chunk != c.chunk {
+		c.data, c.ends = c.fill(chunk, c.data[:0], c.ends[:0])
+		c.chunk = chunk
+	}
+	j := i % ChunkSize
+	start := 0
+	if j > 0 {
+		start = c.ends[j-1]
+	}
+	return c.data[start:c.ends[j]]
+}
+
+// one returns the i-th value, like at. If the values can be generated alone, and the
+// i-th value isn't in the chunk kept by the cursor, only this value is generated, so
+// values picked at random don't generate the other values of their chunk
+func (c *valueCursor) one(i int) []byte {
+	if c.generate == nil || i/ChunkSize == c.chunk {
+		return c.at(i)
+	}
+	if i != c.index {
+		c.value = c.generate(i, c.value[:0])
+		c.index = i
+	}
+	return c.value
+}
+
+// Generator for creating the values of a valueSource in turn. It's used for
+// the field with the 'refContent' of a reference, for the references with
+// 'inOrder', and for fields with 'maxDistinctValue'
+type valuesGenerator struct {
+	base
+	cursor *valueCursor
+	index  int
+}
+
+func newValuesGenerator(base base, source *valueSource) (Generator, error) {
+	cursor, err := source.newCursor()
+	if err != nil {
+		return nil, err
+	}
+	return &valuesGenerator{
+		base:   base,
+		cursor: cursor,
+	}, nil
+}
+
+// Type returns the type of the next value
+func (g *valuesGenerator) Type() bsontype.Type {
+	return bsontype.Type(g.cursor.at(g.index)[0])
+}
+
+func (g *valuesGenerator) EncodeValue() {
+	g.buffer.Write(g.cursor.at(g.index)[1:])
+	g.index++
+	if g.index == g.cursor.size {
+		g.index = 0
+	}
+}
+
+// EncodeValueAsString does nothing, as values are bson encoded
+func (g *valuesGenerator) EncodeValueAsString() {}
+
+func (g *valuesGenerator) seek(n int) {
+	g.index = n % g.cursor.size
+}
+
+// Generator for creating values of a valueSource picked at random. It's used
+// by default for the references without 'refContent'.
+//
+// Outside of arrays, the value is picked like the parent of a referenceGenerator,
+// so all the fields using the same reference in a document get the same value.
+// Inside arrays, each element draws its value from the stream of the field, and
+// only this value is generated
+type randomValuesGenerator struct {
+	base
+	cursor *valueCursor
+	// parents of the documents, or nil inside arrays
+	parents *randomParents
+	pcg64   *pcg.PCG64
+	// index of the value of the current element
+	index int
+}
+
+func newRandomValuesGenerator(base base, source *valueSource, parents *randomParents, pcg64 *pcg.PCG64) (Generator, error) {
+	cursor, err := source.newCursor()
+	if err != nil {
+		return nil, err
+	}
+	return &randomValuesGenerator{
+		base:    base,
+		cursor:  cursor,
+		parents: parents,
+		pcg64:   pcg64,
+	}, nil
+}
+
+// Exists picks the value of the element, so its type is known
+// before the value is written
+func (g *randomValuesGenerator) Exists() bool {
+	if !g.base.Exists() {
+		return false
+	}
+	if g.null {
+		return true
+	}
+	if g.parents != nil {
+		g.index = g.parents.parent(g.buffer.index, g.cursor.size)
+	} else {
+		g.index = int(g.pcg64.Bounded(uint64(g.cursor.size)))
+	}
+	return true
+}
+
+func (g *randomValuesGenerator) value() []byte {
+	if g.parents != nil {
+		return g.cursor.at(g.index)
+	}
+	return g.cursor.one(g.index)
+}
+
+func (g *randomValuesGenerator) Type() bsontype.Type {
+	return bsontype.Type(g.value()[0])
+}
+
+func (g *randomValuesGenerator) EncodeValue() {
+	g.buffer.Write(g.value()[1:])
+}
+
+// EncodeValueAsString does nothing, as values are bson encoded
+func (g *randomValuesGenerator) EncodeValueAsString() {}
@@ -0,0 +1,116 @@
+package generators
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Variant is one of the shapes of the documents of a collection, for collections
+// holding documents with different schemas, like several versions of a document
+type Variant struct {
+	// Percentage of the documents created from this variant
+	Percentage int `json:"percentage"`
+	// Value written in the version field of the documents created from
+	// this variant. Required if a version field is set
+	Version any `json:"version"`
+	// Fields of the documents created from this variant. They are
+	// added after the fields common to all variants
+	Content Content `json:"content"`
+}
+
+// NewVariantsDocumentGenerator creates a generator of documents holding the fields of
+// content, followed by the fields of one of the variants. The variant is picked at
+// random for each document, with a probability given by its percentage.
+//
+// If versionField isn't empty, the version of the variant is written in this field,
+// right after the fields of content
+func (ci *CollInfo) NewVariantsDocumentGenerator(content Content, variants []Variant, versionField string) (*DocumentGenerator, error) {
+
+	if len(variants) == 0 {
+		return nil, errors.New("'variants' can't be null or empty")
+	}
+
+	total := 0
+	weights := make([]float64, 0, len(variants))
+	for i, v := range variants {
+		if v.Percentage < 0 || v.Percentage > 100 {
+			return nil, fmt.Errorf("invalid variant %d: percentage has to be between 0 and 100", i)
+		}
+		total += v.Percentage
+		weights = append(weights, float64(v.Percentage))
+	}
+	if total != 100 {
+		return nil, fmt.Errorf("the sum of the percentages of the variants has to be 100, got %d", total)
+	}
+	cumulatedWeights, err := cumulateWeights(weights, len(weights))
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := ci.newDocumentGenerator(NewDocBuffer(), content, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	d.cumulatedWeights = cumulatedWeights
+	d.pcg32 = ci.newStream("$variants").pcg32
+	d.streams, d.seekers = ci.streams, ci.seekers
+
+	commonFields := make([]string, 0, len(content))
+	for _, f := range content {
+		commonFields = append(commonFields, f.Name)
+	}
+	for i, v := range variants {
+
+		variantContent, err := fieldsOfVariant(content, v, versionField)
+		if err != nil {
+			return nil, fmt.Errorf("invalid variant %d: %v", i, err)
+		}
+		// fields of different variants can share a name, so they need distinct
+		// paths to get distinct random streams and pregenerated values
+		ci.variableValues = true
+		variant, err := ci.newDocumentGenerator(d.Buffer, variantContent, fmt.Sprintf("$%d.", i), commonFields)
+		ci.variableValues = false
+		if err != nil {
+			return nil, fmt.Errorf("invalid variant %d: %v", i, err)
+		}
+		variant.streams, variant.seekers = ci.streams, ci.seekers
+		d.variants = append(d.variants, variant)
+	}
+	ci.streams, ci.seekers = nil, nil
+
+	ci.registerReferencedCollections(content, func() (*DocumentGenerator, error) {
+		return ci.NewVariantsDocumentGenerator(content, variants, versionField)
+	})
+	return d, nil
+}
+
+// fieldsOfVariant returns the fields specific to variant v, starting
+// with the version field if any
+func fieldsOfVariant(common Content, v Variant, versionField string) (Content, error) {
+
+	fields := make(Content, 0, len(v.Content)+1)
+	if versionField != "" {
+		if v.Version == nil {
+			return nil, errors.New("'version' can't be null when 'versionField' is set")
+		}
+		fields = append(fields, Field{Name: versionField, Config: Config{Type: TypeConstant, ConstVal: v.Version}})
+	}
+
+	for _, f := range v.Content {
+		switch f.Config.Type {
+		case TypeCountAggregator, TypeValueAggregator, TypeBoundAggregator:
+			return nil, fmt.Errorf("aggregator '%s' has to be declared in the content common to all variants", f.Name)
+		}
+		if _, ok := fields.Get(f.Name); ok {
+			return nil, fmt.Errorf("field '%s' is already used as version field", f.Name)
+		}
+		fields = append(fields, f)
+	}
+
+	for _, f := range fields {
+		if _, ok := common.Get(f.Name); ok {
+			return nil, fmt.Errorf("field '%s' is already declared in the content common to all variants", f.Name)
+		}
+	}
+	return fields, nil
+}
@@ -1,6 +1,8 @@
 package datagen
 
 import (
+	"fmt"
+
 	"github.com/iancoleman/orderedmap"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -106,3 +108,89 @@ func (idx *Index) ConvertToIndexModel() mongo.IndexModel {
 		Options: opts,
 	}
 }
+
+// metadata returns the index as a document of the 'indexes' array of the
+// metadata.json file written by mongodump
+func (idx *Index) metadata() bson.D {
+
+	key := bson.D{}
+	name := idx.Name
+	defaultName := name == ""
+	for i, k := range idx.Key.Keys() {
+		v, _ := idx.Key.Get(k)
+		// numbers are decoded as float64, but mongodump writes
+		// index directions as int, like {"field": 1}
+		if f, ok := v.(float64); ok && f == float64(int32(f)) {
+			v = int32(f)
+		}
+		key = append(key, bson.E{Key: k, Value: v})
+		// same default name as mongodb, like 'field1_1_field2_-1'
+		if defaultName {
+			if i > 0 {
+				name += "_"
+			}
+			name += fmt.Sprintf("%s_%v", k, v)
+		}
+	}
+
+	doc := bson.D{
+		{Key: "v", Value: int32(2)},
+		{Key: "key", Value: key},
+		{Key: "name", Value: name},
+	}
+	add := func(k string, v any) {
+		doc = append(doc, bson.E{Key: k, Value: v})
+	}
+	if idx.ExpireAfter != 0 {
+		add("expireAfterSeconds", idx.ExpireAfter)
+	}
+	if idx.Sparse {
+		add("sparse", true)
+	}
+	if idx.Unique {
+		add("unique", true)
+	}
+	if idx.TextIndexVersion != 0 {
+		add("textIndexVersion", idx.TextIndexVersion)
+	}
+	if idx.DefaultLanguage != "" {
+		add("default_language", idx.DefaultLanguage)
+	}
+	if idx.LanguageOverride != "" {
+		add("language_override", idx.LanguageOverride)
+	}
+	if idx.Weights != nil {
+		add("weights", idx.Weights)
+	}
+	if idx.Bits != 0 {
+		add("bits", idx.Bits)
+	}
+	if idx.Max != 0 {
+		add("max", idx.Max)
+	}
+	if idx.Min != 0 {
+		add("min", idx.Min)
+	}
+	if idx.BucketSize != 0 {
+		add("bucketSize", idx.BucketSize)
+	}
+	if idx.PartialFilterExpression != nil {
+		add("partialFilterExpression", idx.PartialFilterExpression)
+	}
+	if idx.Collation.Locale != "" {
+		add("collation", idx.Collation.ToDocument())
+	}
+	if idx.SphereIndexVersion != 0 {
+		add("2dsphereIndexVersion", idx.SphereIndexVersion)
+	}
+	if idx.Hidden {
+		add("hidden", true)
+	}
+	if idx.StorageEngine != nil {
+		add("storageEngine", idx.StorageEngine)
+	}
+	if idx.WildcardProjection != nil {
+		add("wildcardProjection", idx.WildcardProjection)
+	}
+	return doc
+}
@@ -17,7 +17,6 @@ import (
 	"github.com/gosuri/uiprogress/util/strutil"
 	"github.com/olekukonko/tablewriter"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/bsontype"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -42,10 +41,10 @@ func newMongoWriter(options *Options, logger io.Writer) (writer, error) {
 
 	return &mongoWriter{
 		baseWriter: &baseWriter{
-			batchSize:  options.BatchSize,
-			mapRef:     make(map[int][][]byte),
-			mapRefType: make(map[int]bsontype.Type),
-			logger:     logger,
+			batchSize:    options.BatchSize,
+			numGenerator: options.NumGenerator,
+			references:   make(generators.References),
+			logger:       logger,
 		},
 		session:    session,
 		version:    version,
@@ -66,9 +65,24 @@ func (w *mongoWriter) write(collections []Collection, seed uint64) (err error) {
 	// wait for the n-1 first collections to be generated to get the error
 	for i := 0; i < len(collections); i++ {
 
-		ci := generators.NewCollInfo(collections[i].Count, w.version, seed, w.mapRef, w.mapRefType)
+		ci := generators.NewCollInfo(collections[i].Count, w.version, seed, w.references)
+		ci.Namespace = collections[i].DB + "." + collections[i].Name
 
-		collections[i].docGenerator, err = ci.NewDocumentGenerator(collections[i].Content)
+		// if "_id" is missing, the driver would add an ObjectId based on current
+		// time, and the documents wouldn't be reproducible from the seed. When
+		// appending documents, keep the ObjectIds of the driver: the ones derived
+		// from the seed would be the same as in the previous run, and the insert
+		// would fail with a duplicate key error
+		if !w.append {
+			addIDGeneratorIfMissing(&collections[i])
+		}
+
+		collections[i].collInfo = ci
+		collections[i].docGenerators, err = w.newDocumentGenerators(ci, &collections[i])
+		if err != nil {
+			return fmt.Errorf("fail to create DocumentGenerator for collection '%s'\n%v", collections[i].Name, err)
+		}
+		collections[i].uniqueFilter, err = ci.NewUniqueFieldsFilter(collections[i].Content, collections[i].UniqueFields)
 		if err != nil {
 			return fmt.Errorf("fail to create DocumentGenerator for collection '%s'\n%v", collections[i].Name, err)
 		}
@@ -85,6 +99,7 @@ func (w *mongoWriter) write(collections []Collection, seed uint64) (err error) {
 		}
 	}
 	w.printStats(collections)
+	w.printGraphStats(collections)
 
 	return nil
 }
@@ -235,7 +250,7 @@ func (w *mongoWriter) fillCollection(coll *Collection) error {
 		wg.Add(1)
 		go w.insertDocumentFromChannel(ctx, cancel, &wg, coll, tasks, errs)
 	}
-	w.generateDocument(ctx, tasks, coll.Count, coll.docGenerator)
+	genErr := w.generateDocument(ctx, tasks, coll)
 
 	wg.Wait()
 
@@ -246,7 +261,7 @@ func (w *mongoWriter) fillCollection(coll *Collection) error {
 		}
 	default:
 	}
-	return nil
+	return genErr
 }
 
 func (w *mongoWriter) insertDocumentFromChannel(ctx context.Context, cancel context.CancelFunc, wg *sync.WaitGroup, coll *Collection, tasks <-chan *rawChunk, errs chan error) {
@@ -258,11 +273,10 @@ func (w *mongoWriter) insertDocumentFromChannel(ctx context.Context, cancel cont
 	insertOpts := options.InsertMany()
 	// if indexfirst mode is set, specify that writes are unordered so failed
 	// insert will not block the process. This is useful in the case of an
-	// index with 'unique' constraint on two or more fields. There is currently
-	// no way to specify 'maxDistinctValue' on a combination of top level fields,
-	// and we can't guarantee that there will be no duplicate in generated collection,
-	// so the only option left is to ignore insert that fail because of duplicates
-	// writes
+	// index with 'unique' constraint on two or more fields that are not declared
+	// in the 'uniqueFields' of the collection: we can't guarantee that there will
+	// be no duplicate in generated collection, so the only option left is to ignore
+	// insert that fail because of duplicates writes
 	if w.indexFirst {
 		insertOpts.SetOrdered(false)
 	}
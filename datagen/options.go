@@ -29,9 +29,15 @@ type Configuration struct {
 	IndexOnly       bool   `short:"i" long:"indexonly" description:"if present, mgodatagen will just try to rebuild index"`
 	IndexFirst      bool   `short:"x" long:"indexfirst" description:"if present, mgodatagen will create index before\n inserting documents"`
 	NumInsertWorker int    `short:"n" long:"numWorker" value-name:"<nb>" description:"number of concurrent workers inserting documents\n in database. Default is number of CPU"`
+	NumGenerator    int    `long:"numGenerator" value-name:"<nb>" description:"number of concurrent workers generating documents.\n Default is number of CPU"`
 	BatchSize       int    `short:"b" long:"batchsize" value-name:"<size>" description:"bulk insert batch size" default:"1000"`
 	Seed            uint64 `short:"s" long:"seed" value-name:"<seed>" description:"specific seed to use. Passing the same seed garentees\n the same output for evey run with the same config.\n Has to be in [1, 18446744073709551615]"`
-	Output          string `short:"o" long:"output" value-name:"<output>" description:"where documents should be written. Options are:\n - mongodb (default)\n - stdout\n - filename"`
+	Output          string `short:"o" long:"output" value-name:"<output>" description:"where documents should be written. Options are:\n - mongodb (default)\n - stdout\n - filename. {db} and {collection} in the filename are\n replaced by the database and the collection name, to\n write each collection in its own file"`
+	OutputFormat    string `long:"outputFormat" value-name:"<format>" description:"format of the documents for stdout or file output. Options are:\n - json (default): a json object per collection\n - ndjson: one document per line, as expected by mongoimport\n - bson: raw bson documents. Output is a directory, with the\n same layout as with mongodump\n - csv, tsv: one row per document, embedded documents are\n flattened in dotted columns like 'field.subfield'"`
+	Archive         bool   `long:"archive" description:"if present, write a single archive in the format of\n 'mongodump --archive' to the output file or to stdout.\n Implies --outputFormat=bson"`
+	ArrayMode       string `long:"arrayMode" value-name:"<mode>" description:"how arrays are written with csv or tsv format. Options are:\n - json (default): a single column holding the array as json\n - explode: one row per element of the array\n - index: one column per element, like 'field.0', 'field.1'"`
+	JSONFormat      string `long:"jsonFormat" value-name:"<type>" description:"extended JSON format for stdout or file output. Options are:\n - canonical (default)\n - relaxed"`
+	Compress        string `long:"compress" value-name:"<type>" description:"compression of stdout or file output. Options are:\n - gzip\n - zstd\n - none\n Default is guessed from the extension of the output\n file, ie '.gz' for gzip and '.zst' for zstd"`
 	PrettyPrint     bool   `long:"prettyprint" description:"if present, indent the output. Only for stdout or file\n output"`
 }
 
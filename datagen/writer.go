@@ -2,14 +2,17 @@ package datagen
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"os"
+	"runtime"
+	"strconv"
 	"sync"
 
 	"github.com/feliixx/mgodatagen/datagen/generators"
 
 	"github.com/gosuri/uiprogress"
-	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"github.com/olekukonko/tablewriter"
 )
 
 const (
@@ -17,6 +20,46 @@ const (
 	stdoutOutput  = "stdout"
 )
 
+// available formats for stdout or file output
+const (
+	jsonFormat   = "json"
+	ndjsonFormat = "ndjson"
+	bsonFormat   = "bson"
+	csvFormat    = "csv"
+	tsvFormat    = "tsv"
+)
+
+// available policies to write arrays in csv or tsv format
+const (
+	// the array is written in a single column as relaxed extended JSON
+	jsonArrayMode = "json"
+	// a row is written for each element of the array
+	explodeArrayMode = "explode"
+	// each element of the array is written in its own column, like 'field.0',
+	// 'field.1'... The number of columns is given by the 'maxLength' of the array
+	indexArrayMode = "index"
+)
+
+// available extended JSON formats
+const (
+	canonicalJSON = "canonical"
+	relaxedJSON   = "relaxed"
+)
+
+// available compressions for stdout or file output
+const (
+	noCompression   = "none"
+	gzipCompression = "gzip"
+	zstdCompression = "zstd"
+)
+
+// placeholders that can be used in the output file name
+// to write each collection in its own file
+const (
+	dbPlaceholder         = "{db}"
+	collectionPlaceholder = "{collection}"
+)
+
 type writer interface {
 	write(collections []Collection, seed uint64) error
 }
@@ -28,6 +71,10 @@ func newWriter(options *Options, logger io.Writer) (writer, error) {
 	case stdoutOutput:
 		return newFileWriter(options, logger, os.Stdout), nil
 	default:
+		// files are created later, once the collections are known
+		if hasFileNamePlaceholder(options.Output) || (options.OutputFormat == bsonFormat && !options.Archive) {
+			return newFileWriter(options, logger, nil), nil
+		}
 		f, err := tryToCreateFile(options.Output)
 		if err != nil {
 			return nil, err
@@ -36,6 +83,24 @@ func newWriter(options *Options, logger io.Writer) (writer, error) {
 	}
 }
 
+// addIDGeneratorIfMissing adds an objectId generator for the "_id" field
+// if the user hasn't specified one. Like in mongodb, "_id" is then the first
+// field of the document
+func addIDGeneratorIfMissing(coll *Collection) {
+	_, hasID := coll.Content.Get("_id")
+	for _, v := range coll.Variants {
+		if _, ok := v.Content.Get("_id"); ok {
+			hasID = true
+		}
+	}
+	if !hasID {
+		coll.Content = append(generators.Content{{
+			Name:   "_id",
+			Config: generators.Config{Type: generators.TypeObjectID},
+		}}, coll.Content...)
+	}
+}
+
 type rawChunk struct {
 	documents  [][]byte
 	nbToInsert int
@@ -72,37 +137,209 @@ type baseWriter struct {
 	progressBar *uiprogress.Bar
 	logger      io.Writer
 
-	batchSize  int
-	mapRef     map[int][][]byte
-	mapRefType map[int]bsontype.Type
+	batchSize    int
+	numGenerator int
+	references   generators.References
+}
+
+// newDocumentGenerators creates the DocumentGenerators used to generate the documents
+// of a collection concurrently. There is no point in having more generators than chunks
+// of documents to generate
+func (b *baseWriter) newDocumentGenerators(ci *generators.CollInfo, coll *Collection) ([]*generators.DocumentGenerator, error) {
+
+	nb := runtime.NumCPU()
+	if b.numGenerator > 0 {
+		nb = b.numGenerator
+	}
+	if nbChunk := (ci.Count + generators.ChunkSize - 1) / generators.ChunkSize; nb > nbChunk {
+		nb = nbChunk
+	}
+
+	docGenerators := make([]*generators.DocumentGenerator, 0, nb)
+	for i := 0; i < nb; i++ {
+		var g *generators.DocumentGenerator
+		var err error
+		if len(coll.Variants) > 0 || coll.VersionField != "" {
+			g, err = ci.NewVariantsDocumentGenerator(coll.Content, coll.Variants, coll.VersionField)
+		} else {
+			g, err = ci.NewDocumentGenerator(coll.Content)
+		}
+		if err != nil {
+			return nil, err
+		}
+		docGenerators = append(docGenerators, g)
+	}
+	return docGenerators, nil
 }
 
-func (b *baseWriter) generateDocument(ctx context.Context, tasks chan<- *rawChunk, nbDoc int, docGenerator *generators.DocumentGenerator) {
+// printGraphStats prints the number of edges per node of the collections
+// holding the edges of a graph
+func (b *baseWriter) printGraphStats(collections []Collection) {
+
+	if b.logger == io.Discard {
+		return
+	}
+
+	var rows [][]string
+	for _, coll := range collections {
+		for _, stats := range coll.collInfo.GraphStats() {
+			rows = append(rows, []string{
+				coll.Name,
+				strconv.Itoa(stats.Nodes),
+				strconv.Itoa(stats.Edges),
+				strconv.FormatFloat(stats.Reciprocity, 'f', 2, 64),
+				formatDegrees(stats.OutDegree),
+				formatDegrees(stats.InDegree),
+			})
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b.logger, "\n")
+	table := tablewriter.NewWriter(b.logger)
+	table.SetHeader([]string{"collection", "nodes", "edges", "reciprocity", "out degree", "in degree"})
+	table.SetAutoWrapText(false)
+	table.AppendBulk(rows)
+	table.Render()
+}
+
+func formatDegrees(stats generators.DegreeStats) string {
+	return fmt.Sprintf("min  %d\nmedian  %d\np99  %d\nmax  %d\nmean  %.2f", stats.Min, stats.Median, stats.P99, stats.Max, stats.Mean)
+}
+
+// generateDocument generates the documents of a collection and sends them to tasks in batches.
+//
+// Documents are generated by chunks of generators.ChunkSize documents, each chunk
+// being generated by one of the docGenerators of the collection. Batches are sent to
+// tasks in the same order as with a single generator, so the output doesn't depend on
+// the number of generators. For the same reason, duplicated combinations of
+// 'uniqueFields' are replaced here, in the order of the documents
+func (b *baseWriter) generateDocument(ctx context.Context, tasks chan<- *rawChunk, coll *Collection) error {
+
+	defer close(tasks)
+
+	nbDoc, docGenerators := coll.Count, coll.docGenerators
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	// generate a document, and use it's length to adjust the initial
-	// size of the slices in the pool 
-	docBytes := docGenerator.Generate()
-	setPoolSliceSize(len(docBytes))
+	// size of the slices in the pool
+	setPoolSliceSize(len(docGenerators[0].Generate()))
+
+	batchPerChunk := (generators.ChunkSize + b.batchSize - 1) / b.batchSize
+
+	// 'chunks' receives the output channel of each chunk in order, and limits
+	// the number of chunks generated ahead of the one being consumed
+	jobs := make(chan chunkJob)
+	chunks := make(chan chan *rawChunk, 2*len(docGenerators))
+	go func() {
+		defer close(jobs)
+		defer close(chunks)
+
+		for start := 0; start < nbDoc; start += generators.ChunkSize {
+			// buffer a whole chunk so a generator never blocks
+			out := make(chan *rawChunk, batchPerChunk)
+			select {
+			case chunks <- out:
+			case <-ctx.Done():
+				return
+			}
+			end := start + generators.ChunkSize
+			if end > nbDoc {
+				end = nbDoc
+			}
+			jobs <- chunkJob{start: start, end: end, out: out}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for _, docGenerator := range docGenerators {
+		wg.Add(1)
+		go func(docGenerator *generators.DocumentGenerator) {
+			defer wg.Done()
+			for job := range jobs {
+				b.generateChunk(job, docGenerator)
+			}
+		}(docGenerator)
+	}
 
-	count := 0
+	var err error
 Loop:
-	for count < nbDoc {
+	for out := range chunks {
+		for rc := range out {
 
-		select {
-		case <-ctx.Done(): // if an error occurred in one of the 'inserting' goroutines, close the channel
-			break Loop
-		default:
+			select {
+			case <-ctx.Done(): // if an error occurred in one of the 'inserting' goroutines, stop sending documents
+				break Loop
+			default:
+			}
+
+			if coll.uniqueFilter != nil {
+				err = filterDocuments(rc, coll.uniqueFilter)
+				if err != nil {
+					break Loop
+				}
+			}
+
+			if b.progressBar != nil {
+				b.progressBar.Set(b.progressBar.Current() + rc.nbToInsert)
+			}
+			tasks <- rc
 		}
+	}
+	cancel()
+	wg.Wait()
+	return err
+}
+
+// filterDocuments replaces the documents of rc holding a combination
+// of 'uniqueFields' already used by a previous document
+func filterDocuments(rc *rawChunk, filter *generators.UniqueFieldsFilter) error {
+	for i, doc := range rc.documents[:rc.nbToInsert] {
+		filtered, err := filter.Filter(doc)
+		if err != nil {
+			return err
+		}
+		if len(filtered) > cap(doc) {
+			rc.documents[i] = make([]byte, len(filtered))
+		} else {
+			rc.documents[i] = doc[:len(filtered)]
+		}
+		copy(rc.documents[i], filtered)
+	}
+	return nil
+}
+
+// chunkJob holds the range of documents of a chunk, and the channel
+// where the batches of documents have to be sent
+type chunkJob struct {
+	start int
+	end   int
+	out   chan<- *rawChunk
+}
+
+func (b *baseWriter) generateChunk(job chunkJob, docGenerator *generators.DocumentGenerator) {
+
+	defer close(job.out)
+
+	docGenerator.Seek(job.start)
+
+	for count := job.start; count < job.end; count += b.batchSize {
 
 		rc := pool.Get().(*rawChunk)
 
 		rc.nbToInsert = b.batchSize
-		if nbDoc-count < b.batchSize {
-			rc.nbToInsert = nbDoc - count
+		if job.end-count < b.batchSize {
+			rc.nbToInsert = job.end - count
 		}
 
 		for i := 0; i < rc.nbToInsert; i++ {
 
+			docBytes := docGenerator.Generate()
+
 			// if doc is not large enough, allocate a new one.
 			// Otherwise, reslice it.
 			// Checking the cap of the slice instead of its length
@@ -113,16 +350,7 @@ Loop:
 				rc.documents[i] = rc.documents[i][:len(docBytes)]
 			}
 			copy(rc.documents[i], docBytes)
-
-			docBytes = docGenerator.Generate()
-		}
-
-		count += rc.nbToInsert
-		if b.progressBar != nil {
-			b.progressBar.Set(b.progressBar.Current() + rc.nbToInsert)
 		}
-
-		tasks <- rc
+		job.out <- rc
 	}
-	close(tasks)
 }
@@ -12,14 +12,15 @@ require (
 	github.com/gosuri/uiprogress v0.0.1
 	github.com/iancoleman/orderedmap v0.3.0
 	github.com/jessevdk/go-flags v1.4.0
+	github.com/klauspost/compress v1.13.6
 	github.com/olekukonko/tablewriter v0.0.5
 	go.mongodb.org/mongo-driver v1.15.0
+	golang.org/x/text v0.14.0
 )
 
 require (
 	github.com/golang/snappy v0.0.1 // indirect
 	github.com/gosuri/uilive v0.0.4 // indirect
-	github.com/klauspost/compress v1.13.6 // indirect
 	github.com/mattn/go-isatty v0.0.14 // indirect
 	github.com/mattn/go-runewidth v0.0.9 // indirect
 	github.com/montanaflynn/stats v0.0.0-20171201202039-1bf9dbcd8cbe // indirect
@@ -30,5 +31,4 @@ require (
 	golang.org/x/crypto v0.17.0 // indirect
 	golang.org/x/sync v0.1.0 // indirect
 	golang.org/x/sys v0.15.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
 )